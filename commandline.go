@@ -0,0 +1,95 @@
+package construct
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pierrec/construct/internal/structs"
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+)
+
+// CommandLine returns the command line flags reproducing the current values of config,
+// e.g. "--host x --port 9090". config must implement FromFlags.
+//
+// Only the flags which values differ from the zero value of config are returned,
+// in lexicographical order. Slice flags are repeated for each of their items.
+// Map flags define all their items at once.
+// Values are quoted for use in a shell if required. Subcommands are not included.
+func CommandLine(config Config, options ...Option) (string, error) {
+	if _, ok := config.(FromFlags); !ok {
+		return "", errors.Errorf("%T does not implement FromFlags", config)
+	}
+	conf, err := newFlagsConfig(config, options)
+	if err != nil {
+		return "", err
+	}
+	zero := reflect.New(reflect.TypeOf(config).Elem()).Interface().(Config)
+	zconf, err := newFlagsConfig(zero, options)
+	if err != nil {
+		return "", err
+	}
+
+	var args []string
+	conf.fs.VisitAll(func(f *flag.Flag) {
+		if zf := zconf.fs.Lookup(f.Name); zf != nil && zf.DefValue == f.DefValue {
+			return
+		}
+		name := "--" + f.Name
+		switch v := f.Value.(type) {
+		case *compositeValue:
+			if v.value.Kind() != reflect.Slice {
+				break
+			}
+			seps := v.seps[1:]
+			for i, n := 0, v.value.Len(); i < n; i++ {
+				item, err := structs.MarshalValue(v.value.Index(i).Interface(), seps)
+				if err != nil {
+					item = ""
+				}
+				args = append(args, name, shellQuote(fmt.Sprintf("%v", item)))
+			}
+			return
+		}
+		if f.Value.Type() == "bool" {
+			if f.DefValue == "true" && f.NoOptDefVal == "true" {
+				args = append(args, name)
+				return
+			}
+			args = append(args, name+"="+f.DefValue)
+			return
+		}
+		args = append(args, name, shellQuote(f.DefValue))
+	})
+	return strings.Join(args, " "), nil
+}
+
+// newFlagsConfig returns the config with its flags set to its current values.
+func newFlagsConfig(config Config, options []Option) (*config, error) {
+	conf, err := newConfig(config, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := conf.buildKeys(conf.root.Fields(), "", false); err != nil {
+		return nil, err
+	}
+	if err := conf.buildFlags("", conf.root); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// shellQuote quotes s if it contains characters interpreted by shells.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return false
+		}
+		return !strings.ContainsRune("_-+=.,:/@%", r)
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,59 @@
+package construct_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pierrec/construct"
+)
+
+type cfgCommandLine struct {
+	Host    string
+	Port    int
+	Debug   bool
+	Timeout time.Duration
+	Tags    []string
+	Unset   string
+}
+
+func (*cfgCommandLine) Init() error                                            { return nil }
+func (*cfgCommandLine) Usage(name string) string                               { return "" }
+func (*cfgCommandLine) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgCommandLine) FlagsShort(name string) string                          { return "" }
+
+func TestCommandLine(t *testing.T) {
+	c := cfgCommandLine{
+		Host:    "x",
+		Port:    9090,
+		Debug:   true,
+		Timeout: time.Minute,
+		Tags:    []string{"a", "b"},
+	}
+	s, err := construct.CommandLine(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--debug --host x --port 9090 --tags a --tags b --timeout 1m0s"
+	if s != want {
+		t.Errorf("got %q; expected %q", s, want)
+	}
+
+	var got cfgCommandLine
+	if err := construct.LoadArgs(&got, strings.Fields(s)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("got %+v; expected %+v", got, c)
+	}
+
+	c = cfgCommandLine{Host: "a b'c"}
+	s, err = construct.CommandLine(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `--host 'a b'\''c'`; s != want {
+		t.Errorf("got %s; expected %s", s, want)
+	}
+}
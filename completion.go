@@ -0,0 +1,333 @@
+package construct
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+)
+
+// Enumerator is implemented by config item types restricted to a set of values.
+// The values are validated once the config is loaded, listed in the usage and
+// used as shell completion candidates.
+type Enumerator interface {
+	Enum() []string
+}
+
+// Completion writes the shell completion script for the flags and subcommands of config to w.
+// Supported shells are bash, zsh and fish. config must implement FromFlags.
+//
+// Flag values are completed according to their type:
+//  - bool flags do not take any value
+//  - enums, i.e. types implementing Enumerator or fields with the choices tag flag, complete with their values
+//  - string flags complete with file names
+//  - other types, such as time.Duration, only show their type as a hint when supported by the shell
+//
+// The command name is the program name, see OptionProgramName.
+// Hidden flags and subcommands, i.e. with an empty usage, are not completed.
+func Completion(config Config, shell string, w io.Writer, options ...Option) error {
+	if _, ok := config.(FromFlags); !ok {
+		return errors.Errorf("%T does not implement FromFlags", config)
+	}
+	var write func(io.Writer, *completionCommand) error
+	switch shell {
+	case "bash":
+		write = writeBashCompletion
+	case "zsh":
+		write = writeZshCompletion
+	case "fish":
+		write = writeFishCompletion
+	default:
+		return errors.Errorf("unsupported shell %q", shell)
+	}
+
+	conf, err := newFlagsConfig(config, options)
+	if err != nil {
+		return err
+	}
+	name := conf.options.prog
+	cmd, err := conf.newCompletionCommand(name, "")
+	if err != nil {
+		return err
+	}
+	return write(w, cmd)
+}
+
+// completionCommand describes the completion of a command and its subcommands.
+type completionCommand struct {
+	name  string
+	path  string // Subcommands path from the main command, separated and started by a slash.
+	usage string
+	flags []completionFlag
+	cmds  []*completionCommand
+}
+
+// completionFlag describes the completion of a flag.
+type completionFlag struct {
+	name    string
+	short   string
+	usage   string
+	hint    string // Type of the value, empty if the flag does not take one.
+	choices []string
+	files   bool
+}
+
+// newCompletionCommand returns the completion of the flags of c, its flags being built,
+// and of its subcommands.
+func (c *config) newCompletionCommand(name, path string) (*completionCommand, error) {
+	cmd := &completionCommand{name: name, path: path, usage: c.raw.Usage("")}
+	c.fs.VisitAll(func(f *flag.Flag) {
+		if f.Usage == "" || f.Deprecated != "" || f.Hidden {
+			// Hidden flag.
+			return
+		}
+		cmd.flags = append(cmd.flags, c.completionFlag(f))
+	})
+
+	for _, field := range c.root.Fields() {
+		s, sc := getCommand(field)
+		if _, ok := field.Flag("hidden"); s == nil || ok || sc.Usage("") == "" {
+			continue
+		}
+		sub := newConfigFromStruct(s, sc, c)
+		if err := sub.buildKeys(s.Fields(), "", false); err != nil {
+			return nil, err
+		}
+		if err := sub.buildFlags("", s); err != nil {
+			return nil, err
+		}
+		subname := strings.ToLower(s.Name())
+		subcmd, err := sub.newCompletionCommand(subname, path+"/"+subname)
+		if err != nil {
+			return nil, err
+		}
+		cmd.cmds = append(cmd.cmds, subcmd)
+	}
+	sort.Slice(cmd.cmds, func(i, j int) bool { return cmd.cmds[i].name < cmd.cmds[j].name })
+
+	return cmd, nil
+}
+
+func (c *config) completionFlag(f *flag.Flag) completionFlag {
+	cf := completionFlag{name: f.Name, short: f.Shorthand, usage: f.Usage}
+	if f.Value.Type() == "bool" && f.NoOptDefVal != "" || f.Value.Type() == "count" {
+		return cf
+	}
+	cf.hint = f.Value.Type()
+
+	var v interface{}
+	if field := c.root.Lookup(c.fromNameAll(c.flagItem(f.Name), c.options.gsep)...); field != nil {
+		v = field.Indirect()
+		cf.choices = enumValues(field)
+	}
+	if len(cf.choices) > 0 {
+		return cf
+	}
+	switch v := v.(type) {
+	case bool:
+		cf.choices = []string{"true", "false"}
+	case time.Duration:
+		cf.hint = "duration"
+	default:
+		cf.files = reflect.TypeOf(v) != nil && reflect.TypeOf(v).Kind() == reflect.String
+	}
+	return cf
+}
+
+// walk calls fn on cmd and all its subcommands.
+func (cmd *completionCommand) walk(fn func(*completionCommand)) {
+	fn(cmd)
+	for _, sub := range cmd.cmds {
+		sub.walk(fn)
+	}
+}
+
+// funcName returns the name of the shell function for the command.
+func (cmd *completionCommand) funcName(root string) string {
+	name := "_" + root + strings.Replace(cmd.path, "/", "_", -1)
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func writeBashCompletion(w io.Writer, root *completionCommand) error {
+	var paths []string
+	root.walk(func(cmd *completionCommand) {
+		if cmd.path != "" {
+			paths = append(paths, shellQuote(cmd.path))
+		}
+	})
+
+	fname := root.funcName(root.name) + "_complete"
+	fmt.Fprintf(w, "# bash completion for %s\n", root.name)
+	fmt.Fprintf(w, "%s() {\n", fname)
+	fmt.Fprintf(w, "\tlocal cur prev cmd w\n")
+	fmt.Fprintf(w, "\tcur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(w, "\tprev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "\tcmd=\"\"\n")
+	if len(paths) > 0 {
+		fmt.Fprintf(w, "\tfor w in \"${COMP_WORDS[@]:1:COMP_CWORD-1}\"; do\n")
+		fmt.Fprintf(w, "\t\tcase \"$cmd/$w\" in\n")
+		fmt.Fprintf(w, "\t\t%s) cmd=\"$cmd/$w\" ;;\n", strings.Join(paths, "|"))
+		fmt.Fprintf(w, "\t\tesac\n")
+		fmt.Fprintf(w, "\tdone\n")
+	}
+	fmt.Fprintf(w, "\tcase \"$cmd\" in\n")
+	root.walk(func(cmd *completionCommand) {
+		fmt.Fprintf(w, "\t%s)\n", shellQuote(cmd.path))
+		var words, nofiles []string
+		for _, f := range cmd.flags {
+			words = append(words, "--"+f.name)
+			if f.short != "" {
+				words = append(words, "-"+f.short)
+			}
+			if f.hint == "" {
+				continue
+			}
+			opts := "--" + f.name
+			if f.short != "" {
+				opts += "|-" + f.short
+			}
+			switch {
+			case f.choices != nil:
+				fmt.Fprintf(w, "\t\tcase \"$prev\" in %s) COMPREPLY=($(compgen -W %s -- \"$cur\")); return ;; esac\n",
+					opts, shellQuote(strings.Join(f.choices, " ")))
+			case f.files:
+				fmt.Fprintf(w, "\t\tcase \"$prev\" in %s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;; esac\n", opts)
+			default:
+				nofiles = append(nofiles, opts)
+			}
+		}
+		if nofiles != nil {
+			fmt.Fprintf(w, "\t\tcase \"$prev\" in %s) COMPREPLY=(); return ;; esac\n", strings.Join(nofiles, "|"))
+		}
+		for _, sub := range cmd.cmds {
+			words = append(words, sub.name)
+		}
+		fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(words, " ")))
+		fmt.Fprintf(w, "\t\t;;\n")
+	})
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "}\n")
+	_, err := fmt.Fprintf(w, "complete -F %s %s\n", fname, root.name)
+	return err
+}
+
+// zshEscape escapes s for use in a zsh _arguments specification.
+func zshEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`, "'", `'\''`).Replace(s)
+	return strings.Replace(s, "\n", " ", -1)
+}
+
+func writeZshCompletion(w io.Writer, root *completionCommand) error {
+	fmt.Fprintf(w, "#compdef %s\n", root.name)
+	root.walk(func(cmd *completionCommand) {
+		fmt.Fprintf(w, "\n%s() {\n", cmd.funcName(root.name))
+		fmt.Fprintf(w, "\tlocal state\n")
+		fmt.Fprintf(w, "\t_arguments -s")
+		for _, f := range cmd.flags {
+			spec := fmt.Sprintf("'--%s", f.name)
+			if f.short != "" {
+				spec = fmt.Sprintf("'(-%[1]s --%[2]s)'{-%[1]s,--%[2]s}'", f.short, f.name)
+			}
+			spec += "[" + zshEscape(f.usage) + "]"
+			switch {
+			case f.hint == "":
+			case f.choices != nil:
+				spec += fmt.Sprintf(":%s:(%s)", f.hint, zshEscape(strings.Join(f.choices, " ")))
+			case f.files:
+				spec += fmt.Sprintf(":%s:_files", f.hint)
+			default:
+				spec += fmt.Sprintf(":%s: ", f.hint)
+			}
+			fmt.Fprintf(w, " \\\n\t\t%s'", spec)
+		}
+		if len(cmd.cmds) == 0 {
+			fmt.Fprintf(w, "\n}\n")
+			return
+		}
+		var subs []string
+		for _, sub := range cmd.cmds {
+			subs = append(subs, fmt.Sprintf(`%s\:"%s"`, sub.name, strings.Replace(zshEscape(sub.usage), `"`, `\"`, -1)))
+		}
+		fmt.Fprintf(w, " \\\n\t\t'1:command:((%s))'", strings.Join(subs, " "))
+		fmt.Fprintf(w, " \\\n\t\t'*::arg:->args'\n")
+		fmt.Fprintf(w, "\tcase $state in\n")
+		fmt.Fprintf(w, "\targs)\n")
+		fmt.Fprintf(w, "\t\tcase $words[1] in\n")
+		for _, sub := range cmd.cmds {
+			fmt.Fprintf(w, "\t\t%s) %s ;;\n", sub.name, sub.funcName(root.name))
+		}
+		fmt.Fprintf(w, "\t\tesac\n")
+		fmt.Fprintf(w, "\t\t;;\n")
+		fmt.Fprintf(w, "\tesac\n")
+		fmt.Fprintf(w, "}\n")
+	})
+	_, err := fmt.Fprintf(w, "\ncompdef %s %s\n", root.funcName(root.name), root.name)
+	return err
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`, "\n", " ").Replace(s) + "'"
+}
+
+func writeFishCompletion(w io.Writer, root *completionCommand) error {
+	fname := root.funcName(root.name) + "_command"
+	var paths []string
+	root.walk(func(cmd *completionCommand) {
+		if cmd.path != "" {
+			paths = append(paths, fishQuote(cmd.path))
+		}
+	})
+
+	fmt.Fprintf(w, "# fish completion for %s\n", root.name)
+	fmt.Fprintf(w, "function %s\n", fname)
+	fmt.Fprintf(w, "\tset -l cmd ''\n")
+	fmt.Fprintf(w, "\tset -l words (commandline -opc)\n")
+	fmt.Fprintf(w, "\tset -e words[1]\n")
+	if len(paths) > 0 {
+		fmt.Fprintf(w, "\tfor w in $words\n")
+		fmt.Fprintf(w, "\t\tswitch \"$cmd/$w\"\n")
+		fmt.Fprintf(w, "\t\t\tcase %s\n", strings.Join(paths, " "))
+		fmt.Fprintf(w, "\t\t\t\tset cmd \"$cmd/$w\"\n")
+		fmt.Fprintf(w, "\t\tend\n")
+		fmt.Fprintf(w, "\tend\n")
+	}
+	fmt.Fprintf(w, "\ttest \"$cmd\" = \"$argv[1]\"\n")
+	fmt.Fprintf(w, "end\n\n")
+	fmt.Fprintf(w, "complete -c %s -f\n", root.name)
+
+	root.walk(func(cmd *completionCommand) {
+		cond := fmt.Sprintf("complete -c %s -n %s", root.name, fishQuote(fname+" "+shellQuote(cmd.path)))
+		for _, f := range cmd.flags {
+			fmt.Fprintf(w, "%s -l %s", cond, f.name)
+			if f.short != "" {
+				fmt.Fprintf(w, " -s %s", f.short)
+			}
+			switch {
+			case f.hint == "":
+			case f.choices != nil:
+				fmt.Fprintf(w, " -x -a %s", fishQuote(strings.Join(f.choices, " ")))
+			case f.files:
+				fmt.Fprintf(w, " -r -F")
+			default:
+				fmt.Fprintf(w, " -x")
+			}
+			fmt.Fprintf(w, " -d %s\n", fishQuote(f.usage))
+		}
+		for _, sub := range cmd.cmds {
+			fmt.Fprintf(w, "%s -a %s -d %s\n", cond, sub.name, fishQuote(sub.usage))
+		}
+	})
+	return nil
+}
@@ -0,0 +1,126 @@
+package construct_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pierrec/construct"
+)
+
+type logLevel string
+
+func (logLevel) Enum() []string { return []string{"debug", "info", "error"} }
+
+func (l logLevel) MarshalText() ([]byte, error) { return []byte(l), nil }
+
+func (l *logLevel) UnmarshalText(text []byte) error {
+	*l = logLevel(text)
+	return nil
+}
+
+type Deploy struct {
+	Force bool
+}
+
+func (*Deploy) Init() error { return nil }
+func (*Deploy) Usage(name string) string {
+	switch name {
+	case "":
+		return "deploy the app"
+	case "Force":
+		return "force the deployment"
+	}
+	return ""
+}
+func (*Deploy) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*Deploy) FlagsShort(name string) string                          { return "" }
+
+type cfgCompletion struct {
+	Config  string
+	Level   logLevel
+	Timeout time.Duration
+	Verbose bool
+	Deploy
+}
+
+func (*cfgCompletion) Init() error { return nil }
+func (*cfgCompletion) Usage(name string) string {
+	switch name {
+	case "Config":
+		return "config file"
+	case "Level":
+		return "log level"
+	case "Timeout":
+		return "timeout"
+	case "Verbose":
+		return "verbose mode"
+	}
+	return ""
+}
+func (*cfgCompletion) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgCompletion) FlagsShort(name string) string {
+	if name == "Verbose" {
+		return "v"
+	}
+	return ""
+}
+
+func TestCompletion(t *testing.T) {
+	name := filepath.Base(os.Args[0])
+	for _, tc := range []struct {
+		shell string
+		want  []string
+	}{
+		{"bash", []string{
+			"complete -F _" + strings.Replace(name, ".", "_", -1) + "_complete " + name,
+			"--level) COMPREPLY=($(compgen -W 'debug info error' -- \"$cur\"))",
+			"--config) COMPREPLY=($(compgen -f -- \"$cur\"))",
+			"--timeout) COMPREPLY=(); return",
+			"'--config --level --timeout --verbose -v deploy'",
+			"/deploy)",
+			"-W --force --",
+		}},
+		{"zsh", []string{
+			"#compdef " + name,
+			"'--level[log level]:string:(debug info error)'",
+			"'--config[config file]:string:_files'",
+			"'--timeout[timeout]:duration: '",
+			"'(-v --verbose)'{-v,--verbose}'[verbose mode]'",
+			`'1:command:((deploy\:"deploy the app"))'`,
+			"'--force[force the deployment]'",
+		}},
+		{"fish", []string{
+			"-l level -x -a 'debug info error' -d 'log level'",
+			"-l config -r -F -d 'config file'",
+			"-l verbose -s v -d 'verbose mode'",
+			"-a deploy -d 'deploy the app'",
+			"'/deploy'",
+		}},
+	} {
+		var buf bytes.Buffer
+		if err := construct.Completion(&cfgCompletion{}, tc.shell, &buf); err != nil {
+			t.Fatal(err)
+		}
+		s := buf.String()
+		for _, want := range tc.want {
+			if !strings.Contains(s, want) {
+				t.Errorf("%s: missing %q in:\n%s", tc.shell, want, s)
+			}
+		}
+		if sh, err := exec.LookPath(tc.shell); err == nil {
+			// Check the syntax of the script.
+			if out, err := exec.Command(sh, "-n", "-c", s).CombinedOutput(); err != nil {
+				t.Errorf("%s: invalid script: %v\n%s", tc.shell, err, out)
+			}
+		}
+	}
+
+	if err := construct.Completion(&cfgCompletion{}, "csh", &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for an unsupported shell")
+	}
+}
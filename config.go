@@ -1,10 +1,16 @@
 package construct
 
 import (
+	"context"
+	stdflag "flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/pierrec/construct/internal/structs"
 	"github.com/pkg/errors"
@@ -15,6 +21,7 @@ const (
 	// TagID is the struct tag name used to annotate struct fields.
 	// Struct fields with tag cfg:"-" are discarded.
 	// Embedded structs with tag cfg:"name" are renamed with the given name.
+	// It can be changed with OptionTagID.
 	TagID = "cfg"
 
 	// TagSepID is the struct tag name used to specify separators for slice or map struct fields.
@@ -22,11 +29,18 @@ const (
 	//  - a map has 2 runes: one to identify the map items, the other to identify the key within an item
 	//  - a slice has 1 rune to identify the slice items
 	//
+	// Nested slices and maps use additional runes, ordered from the outermost
+	// type to the innermost one. The tag must define the runes for all levels,
+	// otherwise the struct is rejected.
+	// If not set, runes default to the first unused one in ",;|/" for items and in ":=#" for map keys.
+	//
 	// e.g. for a field defined as
 	//      Field map[int][]string `...sep=" :,"...`
 	//
 	//  map items are separated by a space, its key by a ':' and the slice items by a ','
 	//  so that `key1:a,b key2:x,y` is deserialized as [key1:["a","b"] key2:["x","y"]].
+	//
+	// It can be changed with OptionSepTagID.
 	TagSepID = "sep"
 )
 
@@ -34,13 +48,13 @@ const (
 // Any embedded struct is processed specifically depending on the interfaces it implements:
 //  - Config interface: it defines a group of config items with a prefix set to the embedded type name
 //  - Config and FromFlags interfaces: it defines a subcommand, which is automatically loaded from flags.
-//    Subcommands are not case sensitive.
+//    Subcommands are not case sensitive by default (see OptionCommandMatch).
 //
 // The embedded type names and field names can be overriden by a struct tag specifying the name to be used.
 type Config interface {
 	// Init initializes the Config struct.
 	// It is automatically invoked on Config and recursively on its non subcommand embedded
-	// structs until an error is encountered.
+	// structs until an error is encountered (see InitPrioritizer for the order).
 	Init() error
 
 	// Usage provides the usage message for the given config item name.
@@ -50,6 +64,27 @@ type Config interface {
 	Usage(name string) string
 }
 
+// InitPrioritizer is optionally implemented by Config structs to change the order
+// in which their Init method is invoked relative to the struct embedding them and
+// its other embedded structs.
+// Structs with a higher priority are initialized first, the ones with the same
+// priority in declaration order, starting with the embedding struct which always
+// has a priority of 0 relative to its embedded structs.
+// The default priority is 0.
+type InitPrioritizer interface {
+	InitPriority() int
+}
+
+// InitContexter is optionally implemented by Config structs which initialization
+// depends on the context the config is loaded with, see LoadContext.
+// InitContext is then invoked instead of Init.
+//
+// Like any method, InitContext is promoted from embedded structs: a Config struct
+// embedding one implementing InitContexter must implement it too.
+type InitContexter interface {
+	InitContext(ctx context.Context) error
+}
+
 // FromFlags defines the interface to set values from command line flags.
 type FromFlags interface {
 	// FlagsDone is called once the flags have been processed
@@ -60,6 +95,35 @@ type FromFlags interface {
 	FlagsShort(name string) string
 }
 
+// FlagsNamer is optionally implemented by Configs implementing FromFlags to
+// name their command line flags instead of deriving their names from the
+// config items names and the group separator.
+type FlagsNamer interface {
+	// FlagName returns the flag name, without dashes, of the config item
+	// identified by keys: its group names followed by its name.
+	// Return an empty value to use the default name.
+	FlagName(keys []string) string
+}
+
+// PreRunner is optionally implemented by commands, main or subcommands, to run
+// code before the FlagsDone method of the invoked command, e.g. to check credentials
+// or start profiling once for all of their subcommands.
+type PreRunner interface {
+	// PreRun is invoked with the commands, from the main one to the invoked one,
+	// and the remaining arguments. Returning an error prevents FlagsDone from
+	// being invoked.
+	PreRun(cmds []Config, args []string) error
+}
+
+// PostRunner is optionally implemented by commands, main or subcommands, to run
+// code after the FlagsDone method of the invoked command.
+type PostRunner interface {
+	// PostRun is invoked with the commands, from the main one to the invoked one,
+	// and the error returned by FlagsDone or the PreRun methods.
+	// The returned error replaces it.
+	PostRun(cmds []Config, err error) error
+}
+
 // FromEnv defines the interface to set values from environment variables.
 type FromEnv interface {
 	// Env returns the name of the environment variable used for the given config item.
@@ -74,6 +138,7 @@ type FromIO interface {
 	Load() (io.ReadCloser, error)
 
 	// Save returns the destination for the data.
+	// It is invoked by Load once the config is loaded, unless OptionNoSave is used.
 	Save() (io.WriteCloser, error)
 
 	// New returns a new instance of Store.
@@ -87,24 +152,54 @@ type FromIO interface {
 // in order of priority:
 //  - cli value: provided by the FromFlags interface
 //  - env value: provided by the FromEnv interface
-//  - ini value: provided by the FromIO interface
+//  - remote value: provided by the FromRemote interface
+//  - ini value: provided by the FromIO or FromIOMulti interface
 //  - default value: values initially set in config
 func Load(config Config, options ...Option) error {
-	args := os.Args[1:]
+	return LoadArgs(config, osArgs(), options...)
+}
+
+// osArgs returns the command line arguments without the executable.
+func osArgs() []string {
 	if flag.Parsed() {
 		// Arguments may have been parsed already, typically from go test binary.
-		args = flag.Args()
+		return flag.Args()
 	}
-	return LoadArgs(config, args)
+	return os.Args[1:]
 }
 
 // LoadArgs is equivalent to Load using the given arguments.
 // The first argument must be the real one, not the executable.
+//
+// Combined with OptionEnv and an in-memory FromIO source such as
+// constructs.MemoryStore, it loads the config independently of the process
+// arguments, environment and files, e.g. in parallel tests.
 func LoadArgs(config Config, args []string, options ...Option) error {
+	return loadArgs(context.Background(), config, args, options, nil)
+}
+
+// LoadContext is equivalent to Load using the given context.
+//
+// Loading stops with the context error as soon as the context is done.
+// The context is passed to the FromIO sources implementing FromIOContext,
+// the remote clients implementing RemoteClientContext and the Config structs
+// implementing InitContexter.
+func LoadContext(ctx context.Context, config Config, options ...Option) error {
+	return loadArgs(ctx, config, osArgs(), options, nil)
+}
+
+// LoadArgsContext is equivalent to LoadContext using the given arguments.
+func LoadArgsContext(ctx context.Context, config Config, args []string, options ...Option) error {
+	return loadArgs(ctx, config, args, options, nil)
+}
+
+func loadArgs(ctx context.Context, config Config, args []string, options []Option, handle *Handle) error {
 	conf, err := newConfig(config, options)
 	if err != nil {
 		return err
 	}
+	conf.ctx = ctx
+	conf.handle = handle
 
 	for _, s := range args {
 		switch s {
@@ -114,7 +209,24 @@ func LoadArgs(config Config, args []string, options ...Option) error {
 		}
 	}
 
-	return conf.Load(args)
+	h := conf.options.holder
+	var load uint64
+	if h != nil {
+		load = h.prepare(config, args, options)
+	}
+	if err := conf.Load(args); err != nil {
+		return err
+	}
+	if err := conf.checkSources(); err != nil {
+		return err
+	}
+	if err := conf.snapshot(); err != nil {
+		return err
+	}
+	if h != nil {
+		h.store(config, load)
+	}
+	return nil
 }
 
 type config struct {
@@ -126,28 +238,92 @@ type config struct {
 	// The map keys are the normalized names for flags and the value the untouched names.
 	// keys will be removed as they are set in order of highest priority first.
 	trans map[string]string
+	// Source of the config items values, by their untouched names.
+	sources map[string]Source
+	// Raw values of the config items as read from their source, by their untouched names.
+	raws map[string]string
+	// Config items not set from environment variables, by their untouched names.
+	noenv map[string]bool
+	// Config items set by the command line flag of their group, by their normalized names.
+	mains map[string]bool
+	// Config items merging the values of their sources once set, see items().
+	merges map[string]string
+	// Set if the FromIO source provided data.
+	ioLoaded bool
+	// FromIO values before environment variables expansion, by their untouched names.
+	unexpanded map[string]interface{}
+	// References of the resolved secrets, by their untouched names.
+	secretrefs map[string]string
 
 	// Current subcommands.
 	subs []string
 
-	fs   *flag.FlagSet
-	refs map[string]interface{} // Holds pointers of flags values.
-	prev []Config               // Previous Config items.
+	fs     *flag.FlagSet
+	args   []string               // Positional arguments left once the flags are parsed.
+	refs   map[string]interface{} // Holds pointers of flags values.
+	negs   map[string]string      // Negative bool flags names to the names of the flags they negate.
+	fitems map[string]string      // Normalized names of the config items by flag name.
+	prev   []Config               // Previous Config items.
+
+	parent  *config  // Config of the parent command, if any.
+	cmds    []string // Path of the current command.
+	iostore Store    // Data loaded from the FromIO sources, reused by the subcommands.
+	iosub   LookupFn // Lookup of the subcommand items loaded from iostore, if any.
+
+	handle *Handle         // Handle on the loaded config, if any.
+	ctx    context.Context // Context the config is loaded with.
+	errs   []error         // Errors collected with OptionCollectErrors.
+	save   func() error    // Saves the FromIO sources, if any.
 
 	options struct {
-		fout   io.Writer                                // Flags usage output.
-		gsep   string                                   // Grouped config items separator.
-		envsep string                                   // Environment variables separator.
-		fusage func(error, func(io.Writer) error) error // Called upon flags parsing error or help requested.
+		fout      io.Writer                                // Flags usage output.
+		gsep      string                                   // Grouped config items separator.
+		envsep    string                                   // Environment variables separator.
+		fusage    func(error, func(io.Writer) error) error // Called upon flags parsing error or help requested.
+		utmpl     *template.Template                       // Flags usage template.
+		cmatch    CommandMatch                             // Subcommands matching mode.
+		sfilter   func(Store) error                        // Called on the Store before it is used.
+		ssource   bool                                     // Save the config items source as comments.
+		holder    *Holder                                  // Holder of the loaded config.
+		snap      struct{ path, format string }            // Snapshot of the loaded config.
+		rsources  []Source                                 // Required sources.
+		sources   []Source                                 // Sources in decreasing priority order.
+		envfiles  []envFile                                // Files defining environment variables.
+		envs      map[string]string                        // Environment variables from envfiles.
+		envfunc   func(string) (string, bool)              // Environment variables lookup.
+		winterval time.Duration                            // Polling interval of Watch.
+		strictio  bool                                     // Fail on unknown keys in the FromIO source.
+		iowarn    func(error)                              // Called on unknown keys instead of failing.
+		envauto   bool                                     // Derive environment variables names.
+		envprefix string                                   // Prefix of the derived environment variables.
+		negflags  bool                                     // Define negative bool flags.
+		dout      io.Writer                                // Deprecation warnings output.
+		expand    bool                                     // Expand environment variables in FromIO values.
+		xstrict   bool                                     // Fail on undefined expanded environment variables.
+		collect   bool                                     // Collect the errors of all the config items.
+		nosave    bool                                     // Do not save the FromIO sources when loading.
+		reload    bool                                     // Do not run the commands when loading.
+		resolvers map[string]Resolver                      // Secrets resolvers by scheme.
+		respfiles bool                                     // Expand the @file arguments.
+		mixflags  *bool                                    // Allow flags after the positional arguments.
+		fexit     bool                                     // Exit after writing the flags usage.
+		stdflags  bool                                     // Parse the flags with the flag package.
+		fcase     NameCase                                 // Case of the flags names.
+		iocase    NameCase                                 // Case of the FromIO sources keys.
+		scmds     bool                                     // Fail on arguments not matching a subcommand.
+		incl      string                                   // Key of the FromIO included files.
+		tagid     string                                   // Struct tag of the config items.
+		septagid  string                                   // Struct tag of the separators.
+		nametags  []string                                 // Struct tags the names fall back to.
+		prog      string                                   // Program name.
 	}
 }
 
 func newConfig(c Config, options []Option) (*config, error) {
-	root, err := structs.NewStruct(c, TagID, TagSepID)
-	if err != nil {
-		return nil, err
-	}
-	conf := newConfigFromStruct(root, c, nil)
+	conf := newConfigFromStruct(nil, c, nil)
+	conf.options.iocase = CasePreserve
+	conf.options.tagid = TagID
+	conf.options.septagid = TagSepID
 
 	// User defined options.
 	for _, o := range options {
@@ -157,25 +333,48 @@ func newConfig(c Config, options []Option) (*config, error) {
 		}
 	}
 
+	// The struct tags to honor are set by the options.
+	root, err := structs.NewStruct(c, conf.options.tagid, conf.options.septagid, conf.options.nametags...)
+	if err != nil {
+		return nil, err
+	}
+	conf.root = root
+
 	// Default options.
 	if conf.options.fout == nil {
 		conf.options.fout = os.Stderr
 	}
+	if conf.options.dout == nil {
+		conf.options.dout = os.Stderr
+	}
 	if conf.options.gsep == "" {
 		conf.options.gsep = "-"
 	}
 	if conf.options.envsep == "" {
 		conf.options.envsep = "_"
 	}
+	if conf.options.prog == "" {
+		conf.options.prog = filepath.Base(os.Args[0])
+	}
+	if err := conf.loadEnvFiles(); err != nil {
+		return nil, err
+	}
 	if conf.options.fusage == nil {
 		out := conf.options.fout
 		conf.options.fusage = func(err error, usage func(io.Writer) error) error {
 			if err != nil {
 				fmt.Fprintln(out, err)
 			}
-			usage(out)
-			os.Exit(2)
-			return nil
+			if err := usage(out); err != nil {
+				return err
+			}
+			if conf.options.fexit {
+				os.Exit(2)
+			}
+			if err == nil {
+				return ErrHelp
+			}
+			return err
 		}
 	}
 
@@ -184,25 +383,48 @@ func newConfig(c Config, options []Option) (*config, error) {
 
 func newConfigFromStruct(s *structs.StructStruct, c Config, conf *config) *config {
 	nconf := &config{
-		raw:   c,
-		root:  s,
-		trans: make(map[string]string),
+		raw:     c,
+		root:    s,
+		trans:   make(map[string]string),
+		sources: make(map[string]Source),
+		noenv:   make(map[string]bool),
+		mains:   make(map[string]bool),
+		raws:    make(map[string]string),
+		ctx:     context.Background(),
 	}
 	if conf != nil {
+		nconf.ctx = conf.ctx
 		nconf.options = conf.options
 		nconf.prev = append(conf.prev, conf.raw)
+		nconf.parent = conf
+		nconf.cmds = append(conf.cmds[:len(conf.cmds):len(conf.cmds)], s.Name())
+		nconf.handle = conf.handle
 	}
 	return nconf
 }
 
 // Build the mapping of flags normalized names with their real names.
-func (c *config) buildKeys(fields []*structs.StructField, section string) error {
+// Config items in a noenv group are not set from environment variables.
+func (c *config) buildKeys(fields []*structs.StructField, section string, noenv bool) error {
 	for _, field := range fields {
+		if s, _ := getCommand(field); s != nil {
+			// Subcommands config items are set when they are invoked.
+			continue
+		}
+		_, fnoenv := field.Flag("noenv")
+		fnoenv = fnoenv || noenv
 		if emb := field.Embedded(); emb != nil {
 			section := c.toSection(section, emb)
-			if err := c.buildKeys(emb.Fields(), section); err != nil {
+			if err := c.buildKeys(emb.Fields(), section, fnoenv); err != nil {
 				return errors.Errorf("%s: %v", field.Name(), err)
 			}
+			if main, ok := field.Flag("main"); ok && section != "" {
+				lname := strings.ToLower(section + c.options.gsep + main)
+				if _, ok := c.trans[lname]; !ok {
+					return errors.Errorf("%s: unknown main field %s", field.Name(), main)
+				}
+				c.mains[lname] = true
+			}
 			continue
 		}
 		name := c.toName(section, field)
@@ -211,40 +433,62 @@ func (c *config) buildKeys(fields []*structs.StructField, section string) error
 			return errors.Errorf("duplicate config name: %s", lname)
 		}
 		c.trans[lname] = name
+		if by, ok := field.Flag("deprecated"); ok && c.deprecatedBy(name, by) == nil {
+			return errors.Errorf("%s: unknown field %s replacing deprecated field", name, by)
+		}
+		if fnoenv {
+			c.noenv[name] = true
+		}
 	}
 	return nil
 }
 
 // Load initializes the config.
 func (c *config) Load(args []string) (err error) {
-	if err := c.buildKeys(c.root.Fields(), ""); err != nil {
+	if err := c.buildKeys(c.root.Fields(), "", false); err != nil {
 		return err
 	}
+	if c.handle != nil {
+		c.handle.confs = append(c.handle.confs, c)
+	}
 
 	if from, ok := c.raw.(FromFlags); ok {
 		// Update the config with the cli values.
 		if err := c.buildFlags("", c.root); err != nil {
 			return err
 		}
+		if c.options.respfiles {
+			if args, err = c.expandResponseFiles(args); err != nil {
+				return err
+			}
+		}
 		// Prepare for the callback on the last command only.
 		lastCommand := true
 		defer func() {
-			if err != nil || !lastCommand {
+			if err != nil || !lastCommand || c.options.reload {
 				return
 			}
-			err = from.FlagsDone(c.prev, c.fs.Args())
+			err = c.run(from)
 		}()
 
-		if err := c.fs.Parse(args); err != nil {
-			if err == flag.ErrHelp {
+		if c.options.stdflags {
+			err = c.parseStdlib(args)
+		} else if err = c.fs.Parse(args); err == nil {
+			c.args = c.fs.Args()
+		}
+		if err != nil {
+			if err == flag.ErrHelp || err == stdflag.ErrHelp {
 				err = nil
+			} else {
+				err = c.flagsError(err)
 			}
 			usage := c.buildFlagsUsage()
 			return c.options.fusage(err, usage)
 		}
-
-		if err := c.updateFlags(); err != nil {
-			return err
+		if args := c.args; len(args) > 0 && args[0] == "help" && c.hasHelpCommand() {
+			lastCommand = false
+			c.helpRequested = true
+			return c.helpCommand(args[1:])
 		}
 
 		// Process any subcommand.
@@ -252,77 +496,368 @@ func (c *config) Load(args []string) (err error) {
 			if err != nil {
 				return
 			}
-			args := c.fs.Args()
+			args := c.args
 			if len(args) == 0 {
 				return
 			}
 			// Maybe a new subcommand.
-			sub := args[0]
-			field := c.root.Lookup(sub)
-			if field == nil {
+			var emb *structs.StructStruct
+			var conf Config
+			emb, conf, err = c.lookupCommand(args[0])
+			if err != nil {
 				return
 			}
-			emb := field.Embedded()
 			if emb == nil {
+				// Positional arguments are passed to FlagsDone, unless
+				// only subcommands are expected.
+				if c.options.scmds && c.hasCommands() {
+					err = c.options.fusage(c.unknownCommand(args[0]), c.buildFlagsUsage())
+				}
 				return
 			}
-			// A subcommand must be a Config and Flags.
-			conf, okc := emb.Interface().(Config)
-			_, okf := emb.Interface().(FromFlags)
-			if okc && okf {
-				lastCommand = false
-				err = newConfigFromStruct(emb, conf, c).Load(args[1:])
-			}
+			lastCommand = false
+			err = newConfigFromStruct(emb, conf, c).Load(args[1:])
 		}()
 	}
 
-	if from, ok := c.raw.(FromEnv); ok {
-		// Update the config with the env values.
-		for _, name := range c.trans {
-			envvar := from.Env(name)
-			if envvar == "" {
-				continue
+	var save func() error
+	for _, src := range c.sourcesOrder() {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		switch src {
+		case SourceFlags:
+			if c.fs != nil {
+				err = c.updateFlags()
 			}
-			v, ok := os.LookupEnv(envvar)
-			if !ok {
-				continue
+		case SourceEnv:
+			err = c.loadEnv()
+		case SourceRemote:
+			if from, prefix := c.fromRemote(); from != nil {
+				err = c.updateRemote(from, prefix)
 			}
-			names := c.fromNameAll(name, c.options.envsep)
-			field := c.root.Lookup(names...)
+		case SourceFile:
+			save, err = c.loadIO()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := c.resolve(); err != nil {
+		return err
+	}
+	if len(c.errs) > 0 {
+		// Report the validation failures along with the collected errors.
+		if err := c.validate(); err != nil {
+			c.errs = append(c.errs, err)
+		}
+		return &MultiError{c.errs}
+	}
+	c.save = save
+	if save != nil && !c.options.nosave {
+		if err := save(); err != nil {
+			return err
+		}
+	}
+
+	return c.init()
+}
 
-			if err := field.Set(v); err != nil {
-				return errors.Errorf("env %s: %v", envvar, err)
+// sourcesOrder returns the sources of the config items in decreasing priority order.
+func (c *config) sourcesOrder() []Source {
+	if c.options.sources != nil {
+		return c.options.sources
+	}
+	return []Source{SourceFlags, SourceEnv, SourceRemote, SourceFile}
+}
+
+// loadEnv updates the config items from the environment variables.
+func (c *config) loadEnv() error {
+	from, prefix := c.fromEnv()
+	if from == nil {
+		return nil
+	}
+	for lname, name := range c.items() {
+		if c.noenv[name] {
+			continue
+		}
+		envvar := from.Env(strings.Join(append(prefix, name), c.options.gsep))
+		if envvar == "" {
+			continue
+		}
+		v, ok := c.lookupEnv(envvar)
+		if !ok {
+			continue
+		}
+		field := c.root.Lookup(strings.Split(name, c.options.gsep)...)
+
+		if err := c.setItem(lname, name, field, v, SourceEnv, v); err != nil {
+			if err := c.collect(c.fieldError(name, SourceEnv, envvar, err)); err != nil {
+				return err
 			}
-			delete(c.trans, name)
 		}
 	}
+	return nil
+}
 
-	if from, ok := c.raw.(FromIO); ok {
-		// Load the values from the ini source.
-		lookup := func(keys ...string) []rune {
-			field := c.root.Lookup(keys...)
-			if field == nil {
+// loadIO updates the config items from the FromIO sources.
+// It returns the function saving the config to the sources once it is fully loaded, if any.
+func (c *config) loadIO() (func() error, error) {
+	owner, froms, prefix := c.fromIO()
+	if len(froms) == 0 {
+		return nil, nil
+	}
+	lookup := c.lookup
+	if len(prefix) > 0 {
+		lookup = func(keys ...string) []rune {
+			if len(keys) <= len(prefix) || !equalKeys(keys[:len(prefix)], prefix) {
 				return nil
 			}
-			return field.Separators()
+			return c.lookup(keys[len(prefix):]...)
 		}
-
-		store, err := ioLoad(from, lookup)
+	}
+	lookup = c.ioLookup(lookup, prefix)
+
+	var store Store
+	var stores []Store
+	secure := true
+	if owner != c {
+		// The sources were loaded by the parent command: the subcommand
+		// items are looked up in its store.
+		owner.iosub = lookup
+		store = owner.iostore
+		c.ioLoaded = owner.ioLoaded
+		for _, from := range froms {
+			secure = secure && isSecure(from)
+		}
+	} else {
+		// The items of the subcommand reusing the store are looked up first.
+		base := lookup
+		lookup = func(keys ...string) []rune {
+			if c.iosub != nil {
+				if seps := c.iosub(keys...); seps != nil {
+					return seps
+				}
+			}
+			return base(keys...)
+		}
+		stores = make([]Store, len(froms))
+		for i, from := range froms {
+			s, err := ioLoad(c.ctx, from, lookup, c.options.incl)
+			if err != nil {
+				return nil, err
+			}
+			stores[i] = s
+			secure = secure && isSecure(from)
+		}
+		var err error
+		store, err = layerStores(froms, stores, lookup)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		c.ioLoaded = store != nil
+		if store == nil && c.options.sfilter != nil {
+			store = froms[0].New(lookup)
+		}
+		c.iostore = store
+	}
+	store = c.ioStore(store, prefix)
+	if filter := c.options.sfilter; filter != nil && owner == c {
+		if err := filter(store); err != nil {
+			return nil, err
 		}
+	}
 
-		// Merge the file data with the current config items.
-		if err := c.updateIO(store); err != nil {
-			return err
+	if c.options.strictio && store != nil {
+		if err := c.checkIOKeys(store, prefix); err != nil {
+			if c.options.iowarn != nil {
+				c.options.iowarn(err)
+			} else if err := c.collect(err); err != nil {
+				return nil, err
+			}
 		}
+	}
 
-		if err := c.ioSave(store, from, lookup); err != nil {
-			return err
+	// Merge the file data with the current config items.
+	if err := c.updateIO(store, secure, prefix); err != nil {
+		return nil, err
+	}
+
+	if prefix != nil {
+		// The sources are saved by the command they belong to.
+		return nil, nil
+	}
+	if len(froms) == 1 {
+		// The filtered store is saved.
+		stores[0] = store
+	}
+	return func() error {
+		for i, from := range froms {
+			if err := c.ioSave(stores[i], from, lookup); err != nil {
+				return err
+			}
 		}
+		return nil
+	}, nil
+}
+
+// fromEnv returns the FromEnv source of the config and the keys prefix of its items.
+// Subcommands not implementing FromEnv use the one of their closest parent.
+// With OptionEnvPrefix, the variables names are derived from the config items names,
+// unless set by the FromEnv source.
+func (c *config) fromEnv() (FromEnv, []string) {
+	from, prefix := c.fromEnvSource()
+	if !c.options.envauto {
+		return from, prefix
+	}
+	if from == nil {
+		prefix = c.cmds
 	}
+	return &envPrefix{from, c.options.envprefix, c.options.gsep, c.options.envsep}, prefix
+}
 
-	return c.init()
+func (c *config) fromEnvSource() (FromEnv, []string) {
+	if from, ok := c.raw.(FromEnv); ok {
+		return from, nil
+	}
+	for p := c.parent; p != nil; p = p.parent {
+		if from, ok := p.raw.(FromEnv); ok {
+			return from, c.cmds[len(p.cmds):]
+		}
+	}
+	return nil, nil
+}
+
+// fromRemote returns the FromRemote source of the config and the keys prefix of its items.
+// Subcommands not implementing FromRemote use the one of their closest parent.
+func (c *config) fromRemote() (FromRemote, []string) {
+	if from, ok := c.raw.(FromRemote); ok {
+		return from, nil
+	}
+	for p := c.parent; p != nil; p = p.parent {
+		if from, ok := p.raw.(FromRemote); ok {
+			return from, c.cmds[len(p.cmds):]
+		}
+	}
+	return nil, nil
+}
+
+// fromIO returns the config owning the FromIO sources, the sources and the keys prefix of its items.
+// Subcommands not implementing FromIO or FromIOMulti use the ones of their closest parent.
+func (c *config) fromIO() (*config, []FromIO, []string) {
+	if froms := ioSources(c.raw); froms != nil {
+		return c, froms, nil
+	}
+	for p := c.parent; p != nil; p = p.parent {
+		if froms := ioSources(p.raw); froms != nil {
+			return p, froms, c.cmds[len(p.cmds):]
+		}
+	}
+	return nil, nil, nil
+}
+
+// ioSources returns the FromIO sources of the config, if any.
+func ioSources(config Config) []FromIO {
+	if m, ok := config.(FromIOMulti); ok {
+		var froms []FromIO
+		for _, from := range m.IOSources() {
+			if from != nil {
+				froms = append(froms, from)
+			}
+		}
+		return froms
+	}
+	if from, ok := config.(FromIO); ok {
+		return []FromIO{from}
+	}
+	return nil
+}
+
+// checkSources makes sure that the required sources provided data.
+func (c *config) checkSources() error {
+	if c.helpRequested {
+		return nil
+	}
+	for _, src := range c.options.rsources {
+		ok := src == SourceDefault || src == SourceFile && c.ioLoaded
+		for _, s := range c.sources {
+			if ok {
+				break
+			}
+			ok = s == src
+		}
+		if !ok {
+			return errors.Errorf("missing required %s source", src)
+		}
+	}
+	return nil
+}
+
+// lookupCommand returns the subcommand matching name according to the
+// command matching mode, or nil if there is none.
+func (c *config) lookupCommand(name string) (*structs.StructStruct, Config, error) {
+	var candidates []string
+	var emb *structs.StructStruct
+	var conf Config
+	for _, field := range c.root.Fields() {
+		s, sc := getCommand(field)
+		if s == nil {
+			continue
+		}
+		cmd := s.Name()
+		switch c.options.cmatch {
+		case CommandMatchExact:
+			// Subcommands are displayed lower cased.
+			if strings.ToLower(cmd) == name {
+				return s, sc, nil
+			}
+			continue
+		case CommandMatchPrefix:
+			lcmd, lname := strings.ToLower(cmd), strings.ToLower(name)
+			if lcmd == lname {
+				return s, sc, nil
+			}
+			if !strings.HasPrefix(lcmd, lname) {
+				continue
+			}
+		default:
+			if strings.EqualFold(cmd, name) {
+				return s, sc, nil
+			}
+			continue
+		}
+		candidates = append(candidates, strings.ToLower(cmd))
+		emb, conf = s, sc
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, nil, nil
+	case 1:
+		return emb, conf, nil
+	}
+	return nil, nil, errors.Errorf("ambiguous command %s: %s", name, strings.Join(candidates, ", "))
+}
+
+// lookup returns the separators for the config item identified by keys.
+func (c *config) lookup(keys ...string) []rune {
+	field := c.root.Lookup(keys...)
+	if field == nil {
+		return nil
+	}
+	return field.Separators()
+}
+
+// equalKeys reports whether the keys are equal, ignoring case.
+func equalKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 // fromNameAll splits a concatenated name into all its names.
@@ -331,23 +866,40 @@ func (c *config) fromNameAll(name string, sep string) []string {
 	return strings.Split(c.trans[name], sep)
 }
 
-// init invokes the Init method recursively on the main type
-// and all the embedded ones. It stops at the first error encountered.
+// init validates the config items and invokes the Init method recursively
+// on the main type and all the embedded ones. It stops at the first error encountered.
 func (c *config) init() error {
 	if c.helpRequested {
 		// Skip init if help is requested.
 		return nil
 	}
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.applyDeprecated(); err != nil {
+		return err
+	}
+	if err := c.validate(); err != nil {
+		return err
+	}
 
 	// Make sure to skip the embedded structs implementing Config (aka subcommands)
 	// as they only get initialized if the subcommand is actually invoked.
-	res, ok := callUntil(c.root, "Init", nil, callInitConfig)
+	res, ok := callUntil(c.root, c.callInit, callInitConfig)
 	if !ok {
 		return nil
 	}
 	return res[0].(error)
 }
 
+// callInit invokes the InitContext method of s if implemented, its Init method otherwise.
+func (c *config) callInit(s *structs.StructStruct) ([]interface{}, bool) {
+	if ic, ok := s.Interface().(InitContexter); ok {
+		return []interface{}{ic.InitContext(c.ctx)}, true
+	}
+	return s.Call("Init", nil)
+}
+
 // callInitConfig detects an error returned by the Init method.
 func callInitConfig(in []interface{}) bool {
 	err, ok := in[0].(error)
@@ -375,15 +927,20 @@ func (c *config) toSection(section string, s *structs.StructStruct) string {
 	return section + c.options.gsep + name
 }
 
-// callUntil recursively calls the given method m with arguments args
-// on the StructStructs until the until function returns true.
+// callUntil recursively calls fn on the StructStructs until the until function returns true.
 // Fields matching the Config interface are ignored.
-func callUntil(s *structs.StructStruct, m string, args []interface{},
+//
+// fn is invoked on the StructStruct and its embedded structs in
+// decreasing order of their InitPriority, then in declaration order.
+func callUntil(s *structs.StructStruct, fn func(*structs.StructStruct) ([]interface{}, bool),
 	until func([]interface{}) bool) ([]interface{}, bool) {
-	res, ok := s.Call(m, args)
-	if ok && until(res) {
-		return res, true
+	type call struct {
+		s        *structs.StructStruct
+		self     bool
+		priority int
 	}
+	// The struct own priority only applies relative to its siblings.
+	calls := []call{{s, true, 0}}
 	for _, field := range s.Fields() {
 		if c, _ := getCommand(field); c != nil {
 			continue
@@ -395,7 +952,20 @@ func callUntil(s *structs.StructStruct, m string, args []interface{},
 		if _, ok := emb.Interface().(Config); !ok {
 			continue
 		}
-		res, ok := callUntil(emb, m, args, until)
+		calls = append(calls, call{emb, false, initPriority(emb)})
+	}
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].priority > calls[j].priority
+	})
+
+	for _, call := range calls {
+		var res []interface{}
+		var ok bool
+		if call.self {
+			res, ok = fn(s)
+		} else {
+			res, ok = callUntil(call.s, fn, until)
+		}
 		if ok && until(res) {
 			return res, true
 		}
@@ -403,6 +973,14 @@ func callUntil(s *structs.StructStruct, m string, args []interface{},
 	return nil, false
 }
 
+// initPriority returns the InitPriority of the struct, if any.
+func initPriority(s *structs.StructStruct) int {
+	if p, ok := s.Interface().(InitPrioritizer); ok {
+		return p.InitPriority()
+	}
+	return 0
+}
+
 // getCommand returns the struct implementing the Config and FromFlags interfaces, if any.
 func getCommand(field *structs.StructField) (*structs.StructStruct, Config) {
 	emb := field.Embedded()
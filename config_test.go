@@ -1,7 +1,15 @@
 package construct_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/pierrec/construct"
@@ -10,9 +18,8 @@ import (
 
 type invalid int
 
-func (invalid) DoConfig()                      {}
-func (invalid) InitConfig() error              { return nil }
-func (invalid) UsageConfig(name string) string { return "" }
+func (invalid) Init() error              { return nil }
+func (invalid) Usage(name string) string { return "" }
 
 // Invalid input: not a pointer to a struct.
 func TestInvalid(t *testing.T) {
@@ -34,22 +41,23 @@ type cfg struct {
 	IN   int    `cfg:"myint"`
 }
 
-func (*cfg) DoConfig()                      {}
-func (*cfg) InitConfig() error              { return nil }
-func (*cfg) UsageConfig(name string) string { return "" }
+func (*cfg) Init() error              { return nil }
+func (*cfg) Usage(name string) string { return "" }
 
 type cfgFlags struct {
 	cfg
 }
 
-func (*cfgFlags) FlagsUsageConfig() io.Writer { return nil }
+func (*cfgFlags) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgFlags) FlagsShort(name string) string                          { return "" }
 
 type cfgIO struct {
 	constructs.ConfigFileINI
 	cfg
 }
 
-func (*cfgIO) UsageConfig(name string) string { return "" }
+func (*cfgIO) Init() error              { return nil }
+func (*cfgIO) Usage(name string) string { return "" }
 
 func _TestLoadNoEmbedded(t *testing.T) {
 	c := cfg{
@@ -78,24 +86,23 @@ type Group struct {
 	V int
 }
 
-func (c *Group) InitConfig() error {
+func (c *Group) Init() error {
 	c.V *= 100
 	return nil
 }
 
-func (c *Group) UsageConfig(name string) string { return "" }
+func (c *Group) Usage(name string) string { return "" }
 
 type cfgEmb struct {
 	Group
 	V int
 }
 
-func (*cfgEmb) DoConfig() {}
-func (c *cfgEmb) InitConfig() error {
+func (c *cfgEmb) Init() error {
 	c.V *= 10
 	return nil
 }
-func (c *cfgEmb) UsageConfig(name string) string { return "" }
+func (c *cfgEmb) Usage(name string) string { return "" }
 
 func TestLoadEmbedded(t *testing.T) {
 	c := cfgEmb{
@@ -107,7 +114,7 @@ func TestLoadEmbedded(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Check that InitConfig() is called on embedded types.
+	// Check that Init() is called on embedded types.
 	w := cfgEmb{Group{12300}, 4560}
 	if got, want := c, w; got != want {
 		t.Errorf("got %v; expected %v", got, want)
@@ -120,18 +127,19 @@ type ConfigGroup struct {
 	Group
 }
 
-func (*ConfigGroup) DoConfig() {}
+func (*ConfigGroup) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*ConfigGroup) FlagsShort(name string) string                          { return "" }
 
 type cfgEmbConfig struct {
 	ConfigGroup
 	V int
 }
 
-func (c *cfgEmbConfig) InitConfig() error {
+func (c *cfgEmbConfig) Init() error {
 	c.V *= 10
 	return nil
 }
-func (c *cfgEmbConfig) UsageConfig(name string) string { return "" }
+func (c *cfgEmbConfig) Usage(name string) string { return "" }
 
 func TestLoadEmbeddedConfig(t *testing.T) {
 	c := cfgEmbConfig{
@@ -142,7 +150,7 @@ func TestLoadEmbeddedConfig(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Check that InitConfig() is NOT called on Config embedded types.
+	// Check that Init() is NOT called on Config embedded types.
 	w := cfgEmbConfig{
 		ConfigGroup{Group{123}},
 		4560}
@@ -150,3 +158,854 @@ func TestLoadEmbeddedConfig(t *testing.T) {
 		t.Errorf("got %v; expected %v", got, want)
 	}
 }
+
+type Install struct {
+	Force bool
+	done  bool
+}
+
+func (*Install) Init() error              { return nil }
+func (*Install) Usage(name string) string { return "" }
+func (c *Install) FlagsDone(cmds []construct.Config, args []string) error {
+	c.done = true
+	return nil
+}
+func (*Install) FlagsShort(name string) string { return "" }
+
+type Inspect struct {
+	Deep bool
+	done bool
+}
+
+func (*Inspect) Init() error              { return nil }
+func (*Inspect) Usage(name string) string { return "" }
+func (c *Inspect) FlagsDone(cmds []construct.Config, args []string) error {
+	c.done = true
+	return nil
+}
+func (*Inspect) FlagsShort(name string) string { return "" }
+
+type cfgCmds struct {
+	Install
+	Inspect
+	V int
+}
+
+func (*cfgCmds) Init() error                                            { return nil }
+func (*cfgCmds) Usage(name string) string                               { return "" }
+func (*cfgCmds) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgCmds) FlagsShort(name string) string                          { return "" }
+
+func TestCommandMatch(t *testing.T) {
+	var c cfgCmds
+	if err := construct.LoadArgs(&c, []string{"INSTALL", "--force"}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Install.done || !c.Force {
+		t.Errorf("install command not invoked: %+v", c)
+	}
+
+	c = cfgCmds{}
+	opt := construct.OptionCommandMatch(construct.CommandMatchExact)
+	if err := construct.LoadArgs(&c, []string{"install"}, opt); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Install.done {
+		t.Errorf("install command not invoked: %+v", c)
+	}
+
+	c = cfgCmds{}
+	if err := construct.LoadArgs(&c, []string{"Install"}, opt); err != nil {
+		t.Fatal(err)
+	}
+	if c.Install.done {
+		t.Errorf("unexpected install command invoked")
+	}
+}
+
+func TestCommandMatchPrefix(t *testing.T) {
+	opt := construct.OptionCommandMatch(construct.CommandMatchPrefix)
+
+	var c cfgCmds
+	if err := construct.LoadArgs(&c, []string{"insp", "--deep"}, opt); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Inspect.done || !c.Deep {
+		t.Errorf("inspect command not invoked: %+v", c)
+	}
+	if c.Install.done {
+		t.Errorf("unexpected install command invoked")
+	}
+
+	c = cfgCmds{}
+	err := construct.LoadArgs(&c, []string{"ins"}, opt)
+	if err == nil {
+		t.Fatal("expected error on ambiguous command")
+	}
+	if got, want := err.Error(), "ambiguous command ins: install, inspect"; got != want {
+		t.Errorf("got %q; expected %q", got, want)
+	}
+}
+
+type cfgFilter struct {
+	constructs.ConfigFileINI
+	Host string
+	Port int
+}
+
+func (*cfgFilter) Init() error              { return nil }
+func (*cfgFilter) Usage(name string) string { return "" }
+
+func TestStoreFilter(t *testing.T) {
+	var c cfgFilter
+	c.Name = filepath.Join(t.TempDir(), "config.ini")
+	c.ToSave = true
+
+	filter := func(store construct.Store) error {
+		if store.Has("Port") {
+			t.Errorf("unexpected Port key in store")
+		}
+		return store.Set(9090, "Port")
+	}
+	if err := construct.LoadArgs(&c, nil, construct.OptionStoreFilter(filter)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Port, 9090; got != want {
+		t.Errorf("got %d; expected %d", got, want)
+	}
+}
+
+type cfgSensitive struct {
+	constructs.ConfigFileINI
+	Host   string
+	APIKey string `cfg:"apikey,sensitive"`
+}
+
+func (*cfgSensitive) Init() error              { return nil }
+func (*cfgSensitive) Usage(name string) string { return "" }
+func (*cfgSensitive) Env(name string) string   { return "APP_" + strings.ToUpper(name) }
+
+func TestSensitive(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.ini")
+	if err := os.WriteFile(name, []byte("Host = localhost\napikey = secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var c cfgSensitive
+	c.Name = name
+	if err := construct.LoadArgs(&c, nil); err == nil {
+		t.Fatal("expected error on sensitive item set from file")
+	}
+
+	if err := os.WriteFile(name, []byte("Host = localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("APP_APIKEY", "secret")
+	c = cfgSensitive{}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.APIKey, "secret"; got != want {
+		t.Errorf("got %q; expected %q", got, want)
+	}
+
+	// The sensitive item must not be saved.
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("sensitive item saved:\n%s", data)
+	}
+}
+
+var initOrder []string
+
+type GroupLow struct{ V int }
+
+func (*GroupLow) Init() error {
+	initOrder = append(initOrder, "low")
+	return nil
+}
+func (*GroupLow) Usage(name string) string { return "" }
+
+type GroupHigh struct{ V int }
+
+func (*GroupHigh) Init() error {
+	initOrder = append(initOrder, "high")
+	return nil
+}
+func (*GroupHigh) Usage(name string) string { return "" }
+func (*GroupHigh) InitPriority() int        { return 10 }
+
+type cfgInitOrder struct {
+	GroupLow
+	GroupHigh
+}
+
+func (*cfgInitOrder) Init() error {
+	initOrder = append(initOrder, "root")
+	return nil
+}
+func (*cfgInitOrder) Usage(name string) string { return "" }
+
+func TestInitPriority(t *testing.T) {
+	initOrder = nil
+	var c cfgInitOrder
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(initOrder, ","), "high,root,low"; got != want {
+		t.Errorf("got %s; expected %s", got, want)
+	}
+}
+
+type cfgSources struct {
+	constructs.ConfigFileINI
+	Host string
+	Port int
+}
+
+func (*cfgSources) Init() error { return nil }
+func (*cfgSources) Usage(name string) string {
+	switch name {
+	case "Port":
+		return "listening port"
+	}
+	return ""
+}
+func (*cfgSources) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgSources) FlagsShort(name string) string                          { return "" }
+
+func TestSaveSources(t *testing.T) {
+	var c cfgSources
+	c.Name = filepath.Join(t.TempDir(), "config.ini")
+	c.ToSave = true
+	args := []string{"--port", "9090"}
+	if err := construct.LoadArgs(&c, args, construct.OptionSaveSources(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(c.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# listening port\n# source: flag\nPort = 9090",
+		"# source: default\nHost = ",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("missing %q in saved config:\n%s", want, data)
+		}
+	}
+}
+
+type cfgSnapshot struct {
+	constructs.ConfigFileINI
+	Host   string
+	Port   int
+	Debug  bool
+	APIKey string `cfg:",sensitive"`
+}
+
+func (*cfgSnapshot) Init() error                                            { return nil }
+func (*cfgSnapshot) Usage(name string) string                               { return "" }
+func (*cfgSnapshot) Env(name string) string                                 { return "SNAP_" + strings.ToUpper(name) }
+func (*cfgSnapshot) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgSnapshot) FlagsShort(name string) string                          { return "" }
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	var c cfgSnapshot
+	c.Name = filepath.Join(dir, "config.ini")
+	if err := os.WriteFile(c.Name, []byte("Host = filehost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SNAP_PORT", "8080")
+	t.Setenv("SNAP_APIKEY", "secret")
+
+	snap := filepath.Join(dir, "snapshot.json")
+	opt := construct.OptionSnapshot(snap, "json")
+	if err := construct.LoadArgs(&c, []string{"--debug"}, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"Host":   "filehost",
+		"Port":   8080.0,
+		"Debug":  true,
+		"APIKey": construct.RedactedValue,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; expected %v", got, want)
+	}
+}
+
+func TestRequireSource(t *testing.T) {
+	dir := t.TempDir()
+	opt := construct.OptionRequireSource(construct.SourceFile)
+
+	var c cfgIO
+	c.Name = filepath.Join(dir, "missing.ini")
+	if err := construct.LoadArgs(&c, nil, opt); err == nil {
+		t.Error("expected error on missing required file")
+	}
+
+	// An empty file is not missing.
+	c = cfgIO{}
+	c.Name = filepath.Join(dir, "empty.ini")
+	if err := os.WriteFile(c.Name, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := construct.LoadArgs(&c, nil, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	var cf cfgCmds
+	opt = construct.OptionRequireSource(construct.SourceFlags)
+	if err := construct.LoadArgs(&cf, nil, opt); err == nil {
+		t.Error("expected error on missing required flags")
+	}
+	if err := construct.LoadArgs(&cf, []string{"--v", "1"}, opt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type Serve struct {
+	Port int
+}
+
+func (*Serve) Init() error                                            { return nil }
+func (*Serve) Usage(name string) string                               { return "" }
+func (*Serve) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*Serve) FlagsShort(name string) string                          { return "" }
+
+type Admin struct {
+	Port int
+}
+
+func (*Admin) Init() error                                            { return nil }
+func (*Admin) Usage(name string) string                               { return "" }
+func (*Admin) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*Admin) FlagsShort(name string) string                          { return "" }
+
+type cfgNamespace struct {
+	constructs.ConfigFileTOML
+	Serve
+	Admin
+}
+
+func (*cfgNamespace) Init() error                                            { return nil }
+func (*cfgNamespace) Usage(name string) string                               { return "" }
+func (*cfgNamespace) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgNamespace) FlagsShort(name string) string                          { return "" }
+func (*cfgNamespace) Env(name string) string {
+	return "NS_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+func TestCommandNamespace(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.toml")
+	data := "[Serve]\nPort = 8080\n\n[Admin]\nPort = 9090\n"
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		args       []string
+		serve, adm int
+	}{
+		{[]string{"serve"}, 8080, 0},
+		{[]string{"admin"}, 0, 9090},
+		{[]string{"admin", "--port", "1"}, 0, 1},
+	} {
+		var c cfgNamespace
+		c.Name = name
+		if err := construct.LoadArgs(&c, tc.args); err != nil {
+			t.Fatal(err)
+		}
+		if c.Serve.Port != tc.serve || c.Admin.Port != tc.adm {
+			t.Errorf("%v: got serve=%d admin=%d; expected serve=%d admin=%d",
+				tc.args, c.Serve.Port, c.Admin.Port, tc.serve, tc.adm)
+		}
+	}
+
+	t.Setenv("NS_ADMIN_PORT", "7070")
+	var c cfgNamespace
+	c.Name = name
+	if err := construct.LoadArgs(&c, []string{"admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Admin.Port != 7070 {
+		t.Errorf("got admin=%d; expected 7070", c.Admin.Port)
+	}
+}
+
+// CountedINI counts the times its source is loaded.
+type CountedINI struct {
+	constructs.ConfigFileINI
+	loads int
+}
+
+func (c *CountedINI) LoadAll() ([]io.ReadCloser, error) {
+	c.loads++
+	return c.ConfigFileINI.LoadAll()
+}
+
+type Fetch struct {
+	Hosts []string
+}
+
+func (*Fetch) Init() error                                            { return nil }
+func (*Fetch) Usage(name string) string                               { return "" }
+func (*Fetch) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*Fetch) FlagsShort(name string) string                          { return "" }
+
+type cfgLoadOnce struct {
+	CountedINI
+	Hosts []string
+	Fetch
+}
+
+func (*cfgLoadOnce) Init() error                                            { return nil }
+func (*cfgLoadOnce) Usage(name string) string                               { return "" }
+func (*cfgLoadOnce) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgLoadOnce) FlagsShort(name string) string                          { return "" }
+
+func TestCommandLoadOnce(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.ini")
+	data := "hosts = a,b\n\n[Fetch]\nhosts = c,d,e\n"
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var c cfgLoadOnce
+	c.Name = name
+	if err := construct.LoadArgs(&c, []string{"fetch"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.loads, 1; got != want {
+		t.Errorf("source loaded %d times; expected %d", got, want)
+	}
+	if got, want := c.Hosts, []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; expected %v", got, want)
+	}
+	if got, want := c.Fetch.Hosts, []string{"c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got fetch %v; expected %v", got, want)
+	}
+}
+
+type Secrets struct {
+	Key string
+}
+
+func (*Secrets) Init() error              { return nil }
+func (*Secrets) Usage(name string) string { return "" }
+
+type cfgNoEnv struct {
+	Secrets `cfg:"secrets,noenv"`
+	Host    string
+}
+
+func (*cfgNoEnv) Init() error              { return nil }
+func (*cfgNoEnv) Usage(name string) string { return "" }
+func (*cfgNoEnv) Env(name string) string {
+	return "NOENV_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+func TestNoEnv(t *testing.T) {
+	t.Setenv("NOENV_SECRETS_KEY", "fromenv")
+	t.Setenv("NOENV_HOST", "envhost")
+
+	c := cfgNoEnv{Secrets{"default"}, "host"}
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Key != "default" {
+		t.Errorf("noenv group set from env: %s", c.Key)
+	}
+	if c.Host != "envhost" {
+		t.Errorf("got %s; expected envhost", c.Host)
+	}
+}
+
+type PtrDB struct {
+	Host string
+	Port int
+}
+
+type cfgPointers struct {
+	constructs.ConfigFileJSON
+	Debug   *bool
+	Workers *int
+	Name    *string
+	Rate    *float64
+	Unset   *int
+	DB      *PtrDB
+}
+
+func (*cfgPointers) Init() error                                            { return nil }
+func (*cfgPointers) Usage(name string) string                               { return "" }
+func (*cfgPointers) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgPointers) FlagsShort(name string) string                          { return "" }
+func (*cfgPointers) Env(name string) string {
+	if name == "Name" {
+		return "PTR_NAME"
+	}
+	return ""
+}
+
+func TestPointerFields(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	data := `{"Workers": 0, "DB": {"Host": "db", "Port": 5432}}`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PTR_NAME", "env")
+
+	var c cfgPointers
+	c.ConfigFileJSON.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(&c, []string{"--debug=false", "--rate", "0.5"}); err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case c.Debug == nil || *c.Debug:
+		t.Errorf("Debug: got %v; expected false", c.Debug)
+	case c.Workers == nil || *c.Workers != 0:
+		t.Errorf("Workers: got %v; expected 0", c.Workers)
+	case c.Name == nil || *c.Name != "env":
+		t.Errorf("Name: got %v; expected env", c.Name)
+	case c.Rate == nil || *c.Rate != 0.5:
+		t.Errorf("Rate: got %v; expected 0.5", c.Rate)
+	case c.Unset != nil:
+		t.Errorf("Unset: got %v; expected nil", *c.Unset)
+	case c.DB == nil || *c.DB != (PtrDB{"db", 5432}):
+		t.Errorf("DB: got %v; expected {db 5432}", c.DB)
+	}
+
+	// Unset items are not saved.
+	saved, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(saved); strings.Contains(s, "Unset") || !strings.Contains(s, `"Debug": false`) {
+		t.Errorf("unexpected saved config:\n%s", s)
+	}
+}
+
+type ctxKey struct{}
+
+type ctxClient struct{}
+
+func (ctxClient) Get(key string) (string, bool, error) { return "", false, nil }
+func (ctxClient) GetContext(ctx context.Context, key string) (string, bool, error) {
+	v, ok := ctx.Value(ctxKey{}).(string)
+	return v, ok && key == "Level", nil
+}
+
+type cfgContext struct {
+	Level string
+	ctx   context.Context
+}
+
+func (*cfgContext) Init() error              { return errors.New("Init called") }
+func (*cfgContext) Usage(name string) string { return "" }
+func (c *cfgContext) InitContext(ctx context.Context) error {
+	c.ctx = ctx
+	return nil
+}
+func (*cfgContext) Remote() (construct.RemoteClient, string) { return ctxClient{}, "" }
+
+func TestLoadContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "remote")
+	var c cfgContext
+	if err := construct.LoadArgsContext(ctx, &c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != "remote" {
+		t.Errorf("got %q; expected remote", c.Level)
+	}
+	if c.ctx != ctx {
+		t.Error("InitContext not called with the load context")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	c = cfgContext{}
+	if err := construct.LoadArgsContext(ctx, &c, nil); err != context.Canceled {
+		t.Errorf("got %v; expected %v", err, context.Canceled)
+	}
+}
+
+type cfgSecret struct {
+	constructs.ConfigFileINI
+	User     string
+	Password string `cfg:",secret"`
+	Token    string `cfg:",secret"`
+}
+
+func (*cfgSecret) Init() error                                            { return nil }
+func (*cfgSecret) Usage(name string) string                               { return "" }
+func (*cfgSecret) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgSecret) FlagsShort(name string) string                          { return "" }
+
+func TestSecret(t *testing.T) {
+	var c cfgSecret
+	c.Name = filepath.Join(t.TempDir(), "config.ini")
+	c.ToSave = true
+	if err := os.WriteFile(c.Name, []byte("Token = filetoken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"--user", "bob", "--password", "flagsecret"}
+	h, err := construct.LoadHandle(&c, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Password != "flagsecret" || c.Token != "filetoken" {
+		t.Fatalf("secrets not loaded: %+v", c)
+	}
+
+	data, err := os.ReadFile(c.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); strings.Contains(s, "flagsecret") || !strings.Contains(s, "Token = filetoken") {
+		t.Errorf("invalid saved config:\n%s", s)
+	}
+
+	r, err := h.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range r.Items {
+		if item.Key == "Password" && item.Raw != construct.RedactedValue {
+			t.Errorf("secret disclosed in report: %+v", item)
+		}
+	}
+}
+
+type cfgDeprecated struct {
+	constructs.ConfigFileJSON
+	Addr     string `cfg:",deprecated=Listen"`
+	Listen   string
+	Verbose  bool `cfg:",deprecated=LogLevel"`
+	LogLevel bool
+}
+
+func (*cfgDeprecated) Init() error                                            { return nil }
+func (*cfgDeprecated) Usage(name string) string                               { return name }
+func (*cfgDeprecated) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgDeprecated) FlagsShort(name string) string                          { return "" }
+
+func TestDeprecated(t *testing.T) {
+	var c cfgDeprecated
+	c.Name = filepath.Join(t.TempDir(), "config.json")
+	c.ToSave = true
+	if err := os.WriteFile(c.Name, []byte(`{"Addr": ":80"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	opt := construct.OptionDeprecatedWriter(&buf)
+	if err := construct.LoadArgs(&c, []string{"--verbose", "--loglevel=false"}, opt); err != nil {
+		t.Fatal(err)
+	}
+	if c.Listen != ":80" || c.LogLevel {
+		t.Errorf("deprecated items not applied: %+v", c)
+	}
+	want := "Addr (file) is deprecated, use Listen\nVerbose (flag) is deprecated, use LogLevel\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q; expected %q", got, want)
+	}
+
+	data, err := os.ReadFile(c.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "Verbose") {
+		t.Errorf("deprecated item saved:\n%s", data)
+	}
+
+	var usage bytes.Buffer
+	uopt := construct.OptionFlagsUsage(func(err error, fn func(io.Writer) error) error {
+		return fn(&usage)
+	})
+	c = cfgDeprecated{}
+	if err := construct.LoadArgs(&c, []string{"--help"}, uopt); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(usage.String(), "--addr") {
+		t.Errorf("deprecated flag in usage:\n%s", usage.String())
+	}
+}
+
+type Endpoint struct {
+	Host string
+	Port int
+}
+
+func (e *Endpoint) Init() error {
+	if e.Port == 0 {
+		e.Port = 80
+	}
+	return nil
+}
+func (*Endpoint) Usage(name string) string { return "endpoint " + strings.ToLower(name) }
+
+type cfgNamedGroups struct {
+	constructs.ConfigFileTOML
+	Primary Endpoint
+	Replica Endpoint `cfg:"secondary"`
+}
+
+func (*cfgNamedGroups) Init() error                                            { return nil }
+func (*cfgNamedGroups) Usage(name string) string                               { return "" }
+func (*cfgNamedGroups) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgNamedGroups) FlagsShort(name string) string                          { return "" }
+func (*cfgNamedGroups) Env(name string) string {
+	return "NG_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+func TestNamedGroups(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.toml")
+	data := "[Primary]\nHost = \"db1\"\n\n[secondary]\nHost = \"db2\"\nPort = 5433\n"
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NG_SECONDARY_HOST", "db3")
+
+	var c cfgNamedGroups
+	c.Name = name
+	if err := construct.LoadArgs(&c, []string{"--primary-port", "5432"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Endpoint{"db1", 5432}); c.Primary != want {
+		t.Errorf("got primary %+v; expected %+v", c.Primary, want)
+	}
+	if want := (Endpoint{"db3", 5433}); c.Replica != want {
+		t.Errorf("got replica %+v; expected %+v", c.Replica, want)
+	}
+
+	// Init is invoked on every group.
+	c = cfgNamedGroups{}
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Primary.Port != 80 || c.Replica.Port != 80 {
+		t.Errorf("got ports %d and %d; expected 80", c.Primary.Port, c.Replica.Port)
+	}
+}
+
+type cfgSourcesOrder struct {
+	constructs.ConfigFileJSON
+	Host   string
+	Port   int
+	Secret string
+}
+
+func (*cfgSourcesOrder) Init() error                                            { return nil }
+func (*cfgSourcesOrder) Usage(name string) string                               { return "" }
+func (*cfgSourcesOrder) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgSourcesOrder) FlagsShort(name string) string                          { return "" }
+
+func TestOptionSources(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(name, []byte(`{"Host": "file", "Port": 80}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOST", "env")
+	t.Setenv("SECRET", "s3cr3t")
+	args := []string{"--port", "8080"}
+
+	for _, tc := range []struct {
+		sources []construct.Source
+		want    cfgSourcesOrder
+	}{
+		{nil, cfgSourcesOrder{Host: "env", Port: 8080, Secret: "s3cr3t"}},
+		{[]construct.Source{construct.SourceFile, construct.SourceEnv, construct.SourceFlags},
+			cfgSourcesOrder{Host: "file", Port: 80, Secret: "s3cr3t"}},
+		{[]construct.Source{construct.SourceFlags, construct.SourceEnv},
+			cfgSourcesOrder{Host: "env", Port: 8080, Secret: "s3cr3t"}},
+		{[]construct.Source{construct.SourceFile},
+			cfgSourcesOrder{Host: "file", Port: 80}},
+	} {
+		var c cfgSourcesOrder
+		c.Name = name
+		options := []construct.Option{construct.OptionEnvPrefix("")}
+		if tc.sources != nil {
+			options = append(options, construct.OptionSources(tc.sources...))
+		}
+		if err := construct.LoadArgs(&c, args, options...); err != nil {
+			t.Fatal(err)
+		}
+		if c.Host != tc.want.Host || c.Port != tc.want.Port || c.Secret != tc.want.Secret {
+			t.Errorf("%v: got %s:%d %s; expected %s:%d %s", tc.sources,
+				c.Host, c.Port, c.Secret, tc.want.Host, tc.want.Port, tc.want.Secret)
+		}
+	}
+
+	for _, sources := range [][]construct.Source{
+		{construct.SourceDefault},
+		{construct.SourceEnv, construct.SourceEnv},
+	} {
+		var c cfgSourcesOrder
+		if err := construct.LoadArgs(&c, nil, construct.OptionSources(sources...)); err == nil {
+			t.Errorf("%v: expected an error", sources)
+		}
+	}
+}
+
+type cfgTagID struct {
+	Skip  int      `conf:"-"`
+	Host  string   `conf:"addr" json:"host"`
+	Port  int      `json:"listen,omitempty" cfg:"-"`
+	Names []string `conf:",required" cfg:"-" split:";"`
+	Other int      `json:"-"`
+}
+
+func (*cfgTagID) Init() error                                            { return nil }
+func (*cfgTagID) Usage(name string) string                               { return "" }
+func (*cfgTagID) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgTagID) FlagsShort(name string) string                          { return "" }
+
+func TestTagID(t *testing.T) {
+	var c cfgTagID
+	args := []string{"--addr", "h", "--port", "80", "--names", "a;b", "--other", "2"}
+	err := construct.LoadArgs(&c, args,
+		construct.OptionTagID("conf"),
+		construct.OptionSepTagID("split"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := cfgTagID{0, "h", 80, []string{"a", "b"}, 2}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v; want %+v", c, want)
+	}
+
+	// Fall back to the json names.
+	c = cfgTagID{}
+	args = []string{"--addr", "h", "--listen", "80", "--names", "a;b"}
+	err = construct.LoadArgs(&c, args,
+		construct.OptionTagID("conf"),
+		construct.OptionSepTagID("split"),
+		construct.OptionNameTags("json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = cfgTagID{0, "h", 80, []string{"a", "b"}, 0}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v; want %+v", c, want)
+	}
+
+	if err := construct.LoadArgs(&c, nil, construct.OptionTagID("")); err == nil {
+		t.Error("error expected")
+	}
+}
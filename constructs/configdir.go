@@ -0,0 +1,74 @@
+package constructs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pierrec/construct"
+)
+
+var _ construct.Config = (*ConfigDir)(nil)
+
+// ConfigDir implements the FromRemote interface for config items stored in
+// a directory, one file per config item: the file name is the key and its
+// content the value. This is the layout of the Kubernetes ConfigMap and Secret
+// volumes.
+//
+// The items of a group are read from files in its subdirectory, e.g. DB/Host
+// for the Host item of the DB group, or from files named after their
+// flattened path, e.g. DB.Host, as ConfigMap keys cannot contain slashes.
+// A trailing newline is removed from the values.
+type ConfigDir struct {
+	// Dir is the directory holding the config items files.
+	// If no directory is specified, the config items are not loaded from it.
+	Dir string `cfg:",noio"`
+	// Sensitive config items are only loaded from the directory if set,
+	// e.g. for a Secret volume.
+	Sensitive bool `cfg:"-"`
+}
+
+var _ construct.FromRemote = (*ConfigDir)(nil)
+var _ construct.SecureIO = (*ConfigDir)(nil)
+
+// Init initializes the ConfigDir.
+func (*ConfigDir) Init() error { return nil }
+
+// Usage returns the ConfigDir usage for each of its options.
+func (c *ConfigDir) Usage(name string) string {
+	switch name {
+	case "Dir":
+		return "Config directory"
+	}
+	return ""
+}
+
+// Remote returns the client reading the config items files.
+func (c *ConfigDir) Remote() (construct.RemoteClient, string) {
+	if c.Dir == "" {
+		return nil, ""
+	}
+	return dirClient(c.Dir), ""
+}
+
+// Secure returns whether or not sensitive config items are loaded from the directory.
+func (c *ConfigDir) Secure() bool { return c.Sensitive }
+
+// dirClient reads the config items values from the files of a directory.
+type dirClient string
+
+func (dir dirClient) Get(key string) (string, bool, error) {
+	for _, name := range []string{filepath.FromSlash(key), strings.Replace(key, "/", ".", -1)} {
+		name = filepath.Join(string(dir), name)
+		if fi, err := os.Stat(name); err != nil || fi.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return "", false, err
+		}
+		return strings.TrimSuffix(string(data), "\n"), true, nil
+	}
+	return "", false, nil
+}
@@ -0,0 +1,62 @@
+package constructs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type DirDB struct {
+	Host     string
+	Port     int
+	Password string `cfg:",sensitive"`
+}
+
+func (*DirDB) Init() error              { return nil }
+func (*DirDB) Usage(name string) string { return "" }
+
+type dirConfig struct {
+	constructs.ConfigDir
+	Level string
+	DB    DirDB
+}
+
+func (*dirConfig) Init() error              { return nil }
+func (*dirConfig) Usage(name string) string { return "" }
+
+func TestConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"Level":       "debug\n",
+		"DB/Host":     "dbhost",
+		"DB.Port":     "5432\n",
+		"DB/Password": "secret",
+	} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sensitive := range []bool{false, true} {
+		c := &dirConfig{}
+		c.Dir = dir
+		c.Sensitive = sensitive
+		if err := construct.LoadArgs(c, nil); err != nil {
+			t.Fatal(err)
+		}
+		want := DirDB{Host: "dbhost", Port: 5432}
+		if sensitive {
+			want.Password = "secret"
+		}
+		if c.Level != "debug" || c.DB != want {
+			t.Errorf("sensitive=%v: unexpected config: %+v", sensitive, c)
+		}
+	}
+}
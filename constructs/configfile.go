@@ -3,6 +3,7 @@ package constructs
 import (
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/pierrec/construct"
 )
@@ -15,13 +16,37 @@ type ConfigFile struct {
 	// Name of the config file.
 	// If no name is specified, the file is not loaded by LoadConfig()
 	// and stdout is used if Save is true.
-	Name string `ini:"-" toml:"-" json:"-" yaml:"-"`
+	Name string `cfg:",noio"`
 	// Backup file extension.
 	// The config file is first copied before being overwritten using this value.
 	// Leave empty to disable.
-	Backup string `ini:"-" toml:"-" json:"-" yaml:"-"`
+	Backup string `cfg:",noio"`
 	// ToSave the config file once the whole config has been loaded.
-	ToSave bool `cfg:"Save" ini:"-" toml:"-" json:"-" yaml:"-"`
+	ToSave bool `cfg:"Save,noio"`
+	// Paths lists the directories in which the config file is searched, in order,
+	// if its name is relative. The first file found is loaded and saved, see Path.
+	// If none is found, no config file is loaded.
+	// See SearchPaths for the standard locations.
+	Paths []string `cfg:"-"`
+	// Merge loads all the config files found in Paths instead of the first one,
+	// the values of the ones found first overriding the others.
+	Merge bool `cfg:"-"`
+
+	path string // Config file found in Paths.
+}
+
+// SearchPaths returns the standard locations of the config files of the
+// named application, by decreasing priority:
+// the current directory, $XDG_CONFIG_HOME/app (defaulting to $HOME/.config/app)
+// and /etc/app.
+func SearchPaths(app string) []string {
+	paths := []string{"."}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		paths = append(paths, filepath.Join(dir, app))
+	} else if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".config", app))
+	}
+	return append(paths, filepath.Join("/etc", app))
 }
 
 // Init initializes the ConfigFile.
@@ -31,21 +56,40 @@ func (*ConfigFile) Init() error { return nil }
 func (c *ConfigFile) Usage(name string) string {
 	switch name {
 	case "Name":
-		return "Config file name (default=stdout)"
+		return "Config file name (stdout if empty)"
 	case "Save":
 		return "Save the config to file"
 	case "Backup":
-		return "Config file backup extension (default=" + c.Backup + ")"
+		return "Config file backup extension"
 	}
 	return ""
 }
 
+// Path returns the name of the config file found in Paths by the last
+// Load or LoadAll, or Name if it was not searched or not found.
+func (c *ConfigFile) Path() string {
+	if c.path != "" {
+		return c.path
+	}
+	return c.Name
+}
+
 // Load returns an io.ReadCloser if the Name is set and the file exists.
+// Non regular files such as named pipes or /dev/fd/N are read until EOF.
+// If Paths is set, the first config file found in them is returned.
 func (c *ConfigFile) Load() (io.ReadCloser, error) {
+	c.path = ""
 	if c.Name == "" {
 		return nil, nil
 	}
-	f, err := os.Open(c.Name)
+	if c.searched() {
+		names := c.find()
+		if len(names) == 0 {
+			return nil, nil
+		}
+		c.path = names[0]
+	}
+	f, err := os.Open(c.Path())
 	if err != nil {
 		if os.IsNotExist(err) && c.ToSave {
 			return nil, nil
@@ -55,10 +99,58 @@ func (c *ConfigFile) Load() (io.ReadCloser, error) {
 	return f, nil
 }
 
+// LoadAll returns all the config files found in Paths if Merge is set, by
+// increasing priority, or the one returned by Load otherwise.
+func (c *ConfigFile) LoadAll() ([]io.ReadCloser, error) {
+	if !c.Merge || !c.searched() {
+		src, err := c.Load()
+		if err != nil || src == nil {
+			return nil, err
+		}
+		return []io.ReadCloser{src}, nil
+	}
+	c.path = ""
+	names := c.find()
+	srcs := make([]io.ReadCloser, 0, len(names))
+	for i := len(names) - 1; i >= 0; i-- {
+		f, err := os.Open(names[i])
+		if err != nil {
+			for _, src := range srcs {
+				src.Close()
+			}
+			return nil, err
+		}
+		srcs = append(srcs, f)
+	}
+	if len(names) > 0 {
+		c.path = names[0]
+	}
+	return srcs, nil
+}
+
+// searched returns whether the config file is searched in Paths.
+func (c *ConfigFile) searched() bool {
+	return len(c.Paths) > 0 && c.Name != "" && !filepath.IsAbs(c.Name)
+}
+
+// find returns the config files found in Paths, in order.
+func (c *ConfigFile) find() []string {
+	var names []string
+	for _, dir := range c.Paths {
+		name := filepath.Join(dir, c.Name)
+		if fi, err := os.Stat(name); err == nil && !fi.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 // Save returns an io.WriteCloser if the Save flag is set to true.
-// If the Name is empty, it defaults to stdout.
+// The config file is written to Path. If the Name is empty, it defaults to stdout.
 // If the backup extension is set, the file is first renamed with it,
 // then a new one is created and returned.
+// Non regular files such as named pipes are opened for writing as is,
+// without any backup.
 func (c *ConfigFile) Save() (io.WriteCloser, error) {
 	if !c.ToSave {
 		return nil, nil
@@ -67,15 +159,19 @@ func (c *ConfigFile) Save() (io.WriteCloser, error) {
 	if c.Name == "" {
 		return &nopCloser{os.Stdout}, nil
 	}
+	name := c.Path()
+	if fi, err := os.Stat(name); err == nil && !fi.Mode().IsRegular() {
+		return os.OpenFile(name, os.O_WRONLY, 0)
+	}
 	if c.Backup != "" {
-		bname := c.Name + c.Backup
-		if err := os.Rename(c.Name, bname); err != nil {
+		bname := name + c.Backup
+		if err := os.Rename(name, bname); err != nil {
 			if !os.IsNotExist(err) {
 				return nil, err
 			}
 		}
 	}
-	return os.Create(c.Name)
+	return os.Create(name)
 }
 
 // Wrap the given Writer with a no-op Close method.
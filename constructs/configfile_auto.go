@@ -0,0 +1,149 @@
+package constructs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pierrec/construct"
+)
+
+func init() {
+	construct.RegisterStore("ini", NewStoreINI)
+	construct.RegisterStore("toml", NewStoreTOML)
+	construct.RegisterStore("json", NewStoreJSON)
+	construct.RegisterStore("json5", NewStoreJSON5)
+	construct.RegisterStore("jsonc", NewStoreJSON5)
+	construct.RegisterStore("hcl", NewStoreHCL)
+	construct.RegisterStore("env", NewStoreDotenv)
+	construct.RegisterStore("yaml", NewStoreYAML)
+	construct.RegisterStore("yml", NewStoreYAML)
+	construct.RegisterStore("cue", NewStoreCUE)
+	construct.RegisterStore("gob", NewStoreGob)
+}
+
+var _ construct.Config = (*ConfigFileAuto)(nil)
+
+// ConfigFileAuto implements the FromIO interface for files in any of
+// the formats registered with construct.RegisterStore.
+//
+// The config file name is set with the command line flag named after the
+// ConfigFileAuto, e.g. --config and --config-format for its format when it is
+// embedded with the cfg:"config" struct tag.
+type ConfigFileAuto struct {
+	ConfigFile `cfg:",inline,main=Name"`
+	// Format of the config file.
+	// If no format is specified, it is derived from the file name extension.
+	Format string `cfg:",noio"`
+}
+
+var _ construct.FromIO = (*ConfigFileAuto)(nil)
+
+// ConfigFromEnv returns a ConfigFileAuto set from the environment variables
+// <prefix>_CONFIG for the file name and <prefix>_CONFIG_FORMAT for its format.
+// The format defaults to the file name extension.
+//
+// e.g. with APP_CONFIG=/etc/app.yaml, ConfigFromEnv("APP") loads /etc/app.yaml
+// as a YAML file.
+func ConfigFromEnv(prefix string) ConfigFileAuto {
+	envvar := "CONFIG"
+	if prefix != "" {
+		envvar = prefix + "_" + envvar
+	}
+	return ConfigFileAuto{
+		ConfigFile: ConfigFile{Name: os.Getenv(envvar)},
+		Format:     os.Getenv(envvar + "_FORMAT"),
+	}
+}
+
+// Usage returns the ConfigFileAuto usage for each of its options.
+func (c *ConfigFileAuto) Usage(name string) string {
+	switch name {
+	case "Format":
+		formats := construct.Stores()
+		return fmt.Sprintf("Config file format (one of %v, file extension if empty)", formats)
+	}
+	return c.ConfigFile.Usage(name)
+}
+
+// format returns the format to be used for the config file.
+func (c *ConfigFileAuto) format() (string, error) {
+	format := c.Format
+	if format == "" {
+		ext := filepath.Ext(c.Name)
+		format = strings.ToLower(strings.TrimPrefix(ext, "."))
+	}
+	for _, f := range construct.Stores() {
+		if f == format {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("%s: unknown config file format %q (one of %v)",
+		c.Name, format, construct.Stores())
+}
+
+// Load returns an io.ReadCloser if the Name is set and the file exists.
+// It fails if the format cannot be determined.
+func (c *ConfigFileAuto) Load() (io.ReadCloser, error) {
+	if c.Name == "" {
+		return nil, nil
+	}
+	if _, err := c.format(); err != nil {
+		return nil, err
+	}
+	return c.ConfigFile.Load()
+}
+
+// LoadAll returns the config files to be loaded, see ConfigFile.LoadAll.
+// It fails if the format cannot be determined.
+func (c *ConfigFileAuto) LoadAll() ([]io.ReadCloser, error) {
+	if c.Name == "" {
+		return nil, nil
+	}
+	if _, err := c.format(); err != nil {
+		return nil, err
+	}
+	return c.ConfigFile.LoadAll()
+}
+
+// Save returns an io.WriteCloser if the Save flag is set to true.
+// It fails if the format cannot be determined.
+func (c *ConfigFileAuto) Save() (io.WriteCloser, error) {
+	if !c.ToSave {
+		return nil, nil
+	}
+	if _, err := c.format(); err != nil {
+		return nil, err
+	}
+	return c.ConfigFile.Save()
+}
+
+// New returns the Store for the config file format.
+// If the format cannot be determined, the Store fails reading and writing
+// with the format error.
+func (c *ConfigFileAuto) New(lookup construct.LookupFn) construct.Store {
+	format, err := c.format()
+	if err != nil {
+		return &formatErrStore{NewStoreJSON(lookup).(*jsonStore), err}
+	}
+	store, _ := construct.NewStore(format, lookup)
+	return store
+}
+
+// formatErrStore is the Store of a ConfigFileAuto with an unknown format.
+type formatErrStore struct {
+	*jsonStore
+	err error
+}
+
+// ReadFrom returns the format error.
+func (store *formatErrStore) ReadFrom(r io.Reader) (int64, error) {
+	return 0, store.err
+}
+
+// WriteTo returns the format error.
+func (store *formatErrStore) WriteTo(w io.Writer) (int64, error) {
+	return 0, store.err
+}
@@ -0,0 +1,134 @@
+package constructs_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type autoServer struct {
+	constructs.ConfigFileAuto
+	Host string
+	Port int
+}
+
+func (*autoServer) Init() error              { return nil }
+func (*autoServer) Usage(name string) string { return "" }
+
+func TestConfigFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		file, format, data string
+	}{
+		{"app.yaml", "", "Host: yamlhost\nPort: 8080\n"},
+		{"app.conf", "json", `{"Host": "jsonhost", "Port": 8080}`},
+	} {
+		name := filepath.Join(dir, tc.file)
+		if err := os.WriteFile(name, []byte(tc.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("APP_CONFIG", name)
+		t.Setenv("APP_CONFIG_FORMAT", tc.format)
+
+		c := &autoServer{ConfigFileAuto: constructs.ConfigFromEnv("APP")}
+		if got, want := c.Name, name; got != want {
+			t.Fatalf("got %q; expected %q", got, want)
+		}
+		if err := construct.LoadArgs(c, nil); err != nil {
+			t.Fatal(err)
+		}
+		if c.Host == "" || c.Port != 8080 {
+			t.Errorf("%s: config not loaded: %+v", tc.file, c)
+		}
+	}
+}
+
+func TestConfigFileAutoUnknownFormat(t *testing.T) {
+	c := &autoServer{}
+	c.Name = filepath.Join(t.TempDir(), "app.txt")
+	if err := construct.LoadArgs(c, nil); err == nil {
+		t.Error("expected error on unknown format")
+	}
+
+	// The store reports the format error.
+	store := c.New(nil)
+	if store == nil {
+		t.Fatal("nil store on unknown format")
+	}
+	if _, err := store.ReadFrom(strings.NewReader("")); err == nil || !strings.Contains(err.Error(), "unknown config file format") {
+		t.Errorf("got %v; expected the format error", err)
+	}
+	if _, err := store.WriteTo(io.Discard); err == nil {
+		t.Error("expected error on unknown format")
+	}
+}
+
+type autoFlagsServer struct {
+	constructs.ConfigFileAuto `cfg:"config"`
+	Host                      string
+}
+
+func (*autoFlagsServer) Init() error                                            { return nil }
+func (*autoFlagsServer) Usage(name string) string                               { return "" }
+func (*autoFlagsServer) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*autoFlagsServer) FlagsShort(name string) string                          { return "" }
+
+func TestConfigFileAutoFormatFlag(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data.txt")
+	if err := os.WriteFile(name, []byte("Host: yamlhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &autoFlagsServer{}
+	args := []string{"--config", name, "--config-format", "yaml"}
+	if err := construct.LoadArgs(c, args); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Host, "yamlhost"; got != want {
+		t.Errorf("got %q; expected %q", got, want)
+	}
+
+	c = &autoFlagsServer{}
+	args = []string{"--config", name, "--config-format", "txt"}
+	if err := construct.LoadArgs(c, args); err == nil {
+		t.Error("expected error on invalid format")
+	}
+}
+
+type autoNamerServer struct {
+	constructs.ConfigFileAuto `cfg:"conf"`
+	Host                      string
+}
+
+func (*autoNamerServer) Init() error                                            { return nil }
+func (*autoNamerServer) Usage(name string) string                               { return "" }
+func (*autoNamerServer) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*autoNamerServer) FlagsShort(name string) string                          { return "" }
+func (*autoNamerServer) FlagName(keys []string) string {
+	if len(keys) == 1 && keys[0] == "Host" {
+		return "addr"
+	}
+	return ""
+}
+
+func TestConfigFileAutoNamer(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data.txt")
+	if err := os.WriteFile(name, []byte("Host: yamlhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The config file flag is named after the group, along with the FlagsNamer names.
+	c := &autoNamerServer{}
+	args := []string{"--conf", name, "--conf-format", "yaml", "--addr", "flaghost"}
+	if err := construct.LoadArgs(c, args); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != name || c.Host != "flaghost" {
+		t.Errorf("got %q %q; expected %q flaghost", c.Name, c.Host, name)
+	}
+}
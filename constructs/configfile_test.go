@@ -0,0 +1,98 @@
+package constructs_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type searchServer struct {
+	constructs.ConfigFileJSON
+	Host string
+	Port int
+}
+
+func (*searchServer) Init() error              { return nil }
+func (*searchServer) Usage(name string) string { return "" }
+
+func TestConfigFilePaths(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user")
+	system := filepath.Join(dir, "system")
+	for name, data := range map[string]string{
+		filepath.Join(user, "app.json"):   `{"Host": "user"}`,
+		filepath.Join(system, "app.json"): `{"Host": "system", "Port": 80}`,
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths := []string{filepath.Join(dir, "missing"), user, system}
+
+	for _, tc := range []struct {
+		merge bool
+		host  string
+		port  int
+	}{
+		{false, "user", 0},
+		{true, "user", 80},
+	} {
+		c := &searchServer{}
+		c.Name = "app.json"
+		c.Paths = paths
+		c.Merge = tc.merge
+		if err := construct.LoadArgs(c, nil); err != nil {
+			t.Fatal(err)
+		}
+		if c.Host != tc.host || c.Port != tc.port {
+			t.Errorf("merge=%v: got %s:%d; expected %s:%d", tc.merge, c.Host, c.Port, tc.host, tc.port)
+		}
+		if want := filepath.Join(user, "app.json"); c.Path() != want {
+			t.Errorf("merge=%v: got %s; expected %s", tc.merge, c.Path(), want)
+		}
+		if c.Name != "app.json" {
+			t.Errorf("merge=%v: name changed to %s", tc.merge, c.Name)
+		}
+	}
+
+	// Relative paths are searched again when reloading.
+	t.Chdir(dir)
+	c := &searchServer{}
+	c.Name = "app.json"
+	c.Paths = []string{"user", "system"}
+	for i := 0; i < 2; i++ {
+		c.Host = ""
+		if err := construct.LoadArgs(c, nil); err != nil {
+			t.Fatal(err)
+		}
+		if c.Host != "user" {
+			t.Errorf("load #%d: got %s; expected user", i+1, c.Host)
+		}
+	}
+
+	// No config file found.
+	c = &searchServer{Host: "default"}
+	c.Name = "app.json"
+	c.Paths = paths[:1]
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "default" {
+		t.Errorf("got %s; expected default", c.Host)
+	}
+}
+
+func TestSearchPaths(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	want := []string{".", "/xdg/app", "/etc/app"}
+	if got := constructs.SearchPaths("app"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; expected %v", got, want)
+	}
+}
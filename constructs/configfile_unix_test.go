@@ -0,0 +1,64 @@
+//go:build !windows
+// +build !windows
+
+package constructs_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+func TestConfigFilePipe(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.fifo")
+	if err := syscall.Mkfifo(name, 0600); err != nil {
+		t.Skip(err)
+	}
+
+	go func() {
+		f, err := os.OpenFile(name, os.O_WRONLY, 0)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		_, _ = io.WriteString(f, "Host: pipehost\nPort: 8080\n")
+	}()
+
+	config := &autoServer{}
+	config.ConfigFileAuto.Format = "yaml"
+	config.Backup = ".bak"
+	config.Name = name
+	if err := construct.LoadArgs(config, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Host, "pipehost"; got != want {
+		t.Errorf("got %s; expected %s", got, want)
+	}
+
+	// Saving to a pipe must not perform the backup.
+	go func() {
+		f, err := os.Open(name)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		_, _ = io.Copy(io.Discard, f)
+	}()
+	c := &constructs.ConfigFile{Name: name, Backup: ".bak", ToSave: true}
+	w, err := c.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+	if _, err := os.Stat(name + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("unexpected backup for named pipe: %v", err)
+	}
+	if fi, err := os.Stat(name); err != nil || fi.Mode()&os.ModeNamedPipe == 0 {
+		t.Errorf("named pipe was replaced: %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package constructs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/pierrec/construct"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var _ construct.Config = (*ConfigGRPC)(nil)
+
+// GRPCClient is the interface implemented by clients of a gRPC config distribution service,
+// such as the one returned by NewGRPCClient or a thin wrapper around the client generated
+// for another service.
+type GRPCClient interface {
+	// Fetch returns the config document.
+	Fetch(ctx context.Context) ([]byte, error)
+}
+
+// GRPCWatcher is optionally implemented by a GRPCClient supporting
+// streamed config updates.
+type GRPCWatcher interface {
+	// Watch returns a channel receiving the new config documents.
+	// The channel is closed when the stream ends or the context is done.
+	Watch(ctx context.Context) (<-chan []byte, error)
+}
+
+// ConfigGRPC implements the FromIO interface for config documents fetched
+// from a gRPC service.
+// The config is read only: it is never saved.
+//
+// If the Client implements GRPCWatcher, Watch reloads the config whenever
+// the service streams an update.
+type ConfigGRPC struct {
+	// Client used to fetch the config document.
+	Client GRPCClient `cfg:"-"`
+	// Format of the config document, as registered with construct.RegisterStore.
+	Format string `cfg:",noio"`
+	// Timeout for fetching the config document.
+	// Leave empty to disable.
+	Timeout time.Duration `cfg:",noio"`
+}
+
+var _ construct.FromIO = (*ConfigGRPC)(nil)
+
+// Init initializes the ConfigGRPC.
+func (*ConfigGRPC) Init() error { return nil }
+
+// Usage returns the ConfigGRPC usage for each of its options.
+func (c *ConfigGRPC) Usage(name string) string {
+	switch name {
+	case "Format":
+		return "Config format"
+	case "Timeout":
+		return "Timeout for fetching the config"
+	}
+	return ""
+}
+
+// Load fetches the config document.
+func (c *ConfigGRPC) Load() (io.ReadCloser, error) {
+	return c.LoadContext(context.Background())
+}
+
+// LoadContext fetches the config document within the given context.
+// It fails if the Format is not registered.
+func (c *ConfigGRPC) LoadContext(ctx context.Context) (io.ReadCloser, error) {
+	if c.Client == nil {
+		return nil, nil
+	}
+	// The Store is created by New, which cannot fail.
+	if _, err := construct.NewStore(c.Format, nil); err != nil {
+		return nil, fmt.Errorf("grpc: %v", err)
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	data, err := c.Client.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Watch returns a channel receiving the config documents as they are updated.
+// It fails if the Client does not implement GRPCWatcher.
+func (c *ConfigGRPC) Watch(ctx context.Context) (<-chan []byte, error) {
+	w, ok := c.Client.(GRPCWatcher)
+	if !ok {
+		return nil, errors.New("grpc: config watch not supported by client")
+	}
+	return w.Watch(ctx)
+}
+
+// Notify returns a channel receiving a value whenever the config document is updated,
+// if the Client implements GRPCWatcher.
+// The channel is closed when the stream ends or the context is done.
+func (c *ConfigGRPC) Notify(ctx context.Context) (<-chan struct{}, error) {
+	w, ok := c.Client.(GRPCWatcher)
+	if !ok {
+		return nil, nil
+	}
+	updates, err := w.Watch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	notify := make(chan struct{}, 1)
+	go func() {
+		defer close(notify)
+		for range updates {
+			select {
+			case notify <- struct{}{}:
+			default:
+				// A notification is already pending.
+			}
+		}
+	}()
+	return notify, nil
+}
+
+// Save is a no-op as the config cannot be saved.
+func (c *ConfigGRPC) Save() (io.WriteCloser, error) { return nil, nil }
+
+// New returns the Store for the config document format.
+func (c *ConfigGRPC) New(lookup construct.LookupFn) construct.Store {
+	store, _ := construct.NewStore(c.Format, lookup)
+	return store
+}
+
+// GRPCService is the name of the gRPC config distribution service used by the
+// clients returned by NewGRPCClient. It is defined with the protobuf well-known types:
+//  service ConfigService {
+//    // Fetch returns the config document with the given name.
+//    rpc Fetch(google.protobuf.StringValue) returns (google.protobuf.BytesValue);
+//    // Watch streams the config document with the given name whenever it is updated.
+//    rpc Watch(google.protobuf.StringValue) returns (stream google.protobuf.BytesValue);
+//  }
+const GRPCService = "construct.ConfigService"
+
+var (
+	_ GRPCClient  = (*GRPCServiceClient)(nil)
+	_ GRPCWatcher = (*GRPCServiceClient)(nil)
+)
+
+// GRPCServiceClient implements GRPCClient and GRPCWatcher for the GRPCService.
+type GRPCServiceClient struct {
+	conn grpc.ClientConnInterface
+	name string
+}
+
+// NewGRPCClient returns the client of the config document named name
+// served by the GRPCService over conn.
+func NewGRPCClient(conn grpc.ClientConnInterface, name string) *GRPCServiceClient {
+	return &GRPCServiceClient{conn, name}
+}
+
+// Fetch returns the config document.
+func (c *GRPCServiceClient) Fetch(ctx context.Context) ([]byte, error) {
+	out := new(wrapperspb.BytesValue)
+	if err := c.conn.Invoke(ctx, "/"+GRPCService+"/Fetch", wrapperspb.String(c.name), out); err != nil {
+		return nil, err
+	}
+	return out.GetValue(), nil
+}
+
+var grpcWatchStream = &grpc.StreamDesc{StreamName: "Watch", ServerStreams: true}
+
+// Watch returns a channel receiving the config documents streamed by the service.
+// The channel is closed when the stream ends or the context is done.
+func (c *GRPCServiceClient) Watch(ctx context.Context) (<-chan []byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := c.conn.NewStream(ctx, grpcWatchStream, "/"+GRPCService+"/Watch")
+	if err == nil {
+		err = stream.SendMsg(wrapperspb.String(c.name))
+	}
+	if err == nil {
+		err = stream.CloseSend()
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	updates := make(chan []byte)
+	go func() {
+		defer cancel()
+		defer close(updates)
+		for {
+			msg := new(wrapperspb.BytesValue)
+			if err := stream.RecvMsg(msg); err != nil {
+				return
+			}
+			select {
+			case updates <- msg.GetValue():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates, nil
+}
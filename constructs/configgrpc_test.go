@@ -0,0 +1,193 @@
+package constructs_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// configService is an in-process implementation of the constructs.GRPCService.
+type configService struct {
+	mu      sync.Mutex
+	docs    map[string][]byte
+	updates chan []byte // Updates of the "app" document.
+}
+
+func (s *configService) fetch(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.docs[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "config %q not found", name)
+	}
+	return data, nil
+}
+
+func (s *configService) update(name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs[name] = data
+}
+
+var configServiceDesc = grpc.ServiceDesc{
+	ServiceName: constructs.GRPCService,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Fetch",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(wrapperspb.StringValue)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			data, err := srv.(*configService).fetch(in.GetValue())
+			if err != nil {
+				return nil, err
+			}
+			return wrapperspb.Bytes(data), nil
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Watch",
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			s := srv.(*configService)
+			in := new(wrapperspb.StringValue)
+			if err := stream.RecvMsg(in); err != nil {
+				return err
+			}
+			for {
+				select {
+				case data := <-s.updates:
+					s.update(in.GetValue(), data)
+					if err := stream.SendMsg(wrapperspb.Bytes(data)); err != nil {
+						return err
+					}
+				case <-stream.Context().Done():
+					return nil
+				}
+			}
+		},
+	}},
+}
+
+// serveConfig starts the config service in-process and returns the connection to it.
+func serveConfig(t *testing.T, s *configService) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	srv.RegisterService(&configServiceDesc, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	dial := func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+type grpcServer struct {
+	constructs.ConfigGRPC
+	Host string
+	Port int
+}
+
+func (*grpcServer) Init() error              { return nil }
+func (*grpcServer) Usage(name string) string { return "" }
+
+func TestConfigGRPC(t *testing.T) {
+	s := &configService{
+		docs:    map[string][]byte{"app": []byte(`{"Host": "grpchost", "Port": 8080}`)},
+		updates: make(chan []byte),
+	}
+	client := constructs.NewGRPCClient(serveConfig(t, s), "app")
+	c := &grpcServer{ConfigGRPC: constructs.ConfigGRPC{Client: client, Format: "json"}}
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "grpchost" || c.Port != 8080 {
+		t.Errorf("config not loaded: %+v", c)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, err := c.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.updates <- []byte(`{"Port": 9090}`)
+	if got, want := string(<-updates), `{"Port": 9090}`; got != want {
+		t.Errorf("got %q; expected %q", got, want)
+	}
+	cancel()
+	if _, ok := <-updates; ok {
+		t.Error("updates not closed")
+	}
+}
+
+func TestConfigGRPCWatch(t *testing.T) {
+	s := &configService{
+		docs:    map[string][]byte{"app": []byte(`{"Host": "grpchost", "Port": 8080}`)},
+		updates: make(chan []byte),
+	}
+	client := constructs.NewGRPCClient(serveConfig(t, s), "app")
+	c := &grpcServer{ConfigGRPC: constructs.ConfigGRPC{Client: client, Format: "json"}}
+	// Only the streamed updates trigger the reload.
+	w, err := construct.WatchArgs(c, nil, construct.OptionWatchInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	select {
+	case s.updates <- []byte(`{"Host": "grpchost", "Port": 9090}`):
+	case <-time.After(5 * time.Second):
+		t.Fatal("config not watched")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Current().(*grpcServer).Port != 9090 {
+		if time.Now().After(deadline) {
+			t.Fatalf("config update not reloaded: %v", w.Err())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if c.Port != 8080 {
+		t.Errorf("initial config modified: %+v", c)
+	}
+}
+
+func TestConfigGRPCError(t *testing.T) {
+	s := &configService{docs: map[string][]byte{}}
+	client := constructs.NewGRPCClient(serveConfig(t, s), "app")
+	c := &grpcServer{ConfigGRPC: constructs.ConfigGRPC{Client: client, Format: "json"}}
+	if err := construct.LoadArgs(c, nil); status.Code(err) != codes.NotFound {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestConfigGRPCFormat(t *testing.T) {
+	s := &configService{docs: map[string][]byte{"app": []byte(`{}`)}}
+	conn := serveConfig(t, s)
+	for _, format := range []string{"", "unknown"} {
+		client := constructs.NewGRPCClient(conn, "app")
+		c := &grpcServer{ConfigGRPC: constructs.ConfigGRPC{Client: client, Format: format}}
+		err := construct.LoadArgs(c, nil)
+		if err == nil || !strings.Contains(err.Error(), "unknown store format") {
+			t.Errorf("%q: unexpected error %v", format, err)
+		}
+	}
+}
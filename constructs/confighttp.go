@@ -0,0 +1,238 @@
+package constructs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pierrec/construct"
+)
+
+var _ construct.Config = (*ConfigHTTP)(nil)
+
+// ConfigHTTP implements the FromIO interface for config documents fetched
+// from a URL, so that fleets of services can pull their config from a central one.
+// The config is read only: it is never saved.
+//
+// The document ETag is kept and sent back with the If-None-Match header when
+// the config is loaded again, e.g. on reload, reusing the previous document if
+// it has not changed. The document can also be cached to a file to be reused
+// across restarts.
+type ConfigHTTP struct {
+	// URL of the config document.
+	// If no URL is specified, the config is not loaded.
+	URL string `cfg:",noio"`
+	// Format of the config document, as registered with construct.RegisterStore.
+	// If no format is specified, it is derived from the response Content-Type,
+	// then from the URL path extension.
+	Format string `cfg:",noio"`
+	// Auth is the value of the Authorization header sent with the request,
+	// e.g. "Bearer <token>". Leave empty to disable.
+	Auth string `cfg:",secret,noio"`
+	// Timeout for fetching the config document.
+	// Leave empty to disable.
+	Timeout time.Duration `cfg:",noio"`
+	// Cache is the name of the file in which the document is cached,
+	// its ETag and format being cached in the file with the .etag extension added.
+	// Leave empty to only cache the document in memory.
+	Cache string `cfg:",noio"`
+	// TLS is the configuration of the HTTPS connections.
+	TLS *tls.Config `cfg:"-"`
+	// Client used to fetch the config document instead of the default one.
+	// TLS is ignored if it is set.
+	Client *http.Client `cfg:"-"`
+	// IgnoreNotFound loads no config instead of failing when the document
+	// is not found, e.g. for optional configs.
+	IgnoreNotFound bool `cfg:"-"`
+
+	etag   string
+	data   []byte
+	format string // Format derived from the response.
+}
+
+var _ construct.FromIO = (*ConfigHTTP)(nil)
+var _ construct.FromIOContext = (*ConfigHTTP)(nil)
+
+// Init initializes the ConfigHTTP.
+func (*ConfigHTTP) Init() error { return nil }
+
+// Usage returns the ConfigHTTP usage for each of its options.
+func (c *ConfigHTTP) Usage(name string) string {
+	switch name {
+	case "URL":
+		return "Config URL"
+	case "Format":
+		return "Config format"
+	case "Auth":
+		return "Authorization header for fetching the config"
+	case "Timeout":
+		return "Timeout for fetching the config"
+	case "Cache":
+		return "Config cache file"
+	}
+	return ""
+}
+
+// Load fetches the config document.
+func (c *ConfigHTTP) Load() (io.ReadCloser, error) {
+	return c.LoadContext(context.Background())
+}
+
+// LoadContext fetches the config document within the given context.
+// It fails if the document is not found, unless IgnoreNotFound is set, or if
+// its format cannot be determined.
+func (c *ConfigHTTP) LoadContext(ctx context.Context) (io.ReadCloser, error) {
+	if c.URL == "" {
+		return nil, nil
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequest(http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.Auth != "" {
+		req.Header.Set("Authorization", c.Auth)
+	}
+	if c.data == nil && c.Cache != "" {
+		c.readCache()
+	}
+	if c.data != nil && c.etag != "" {
+		req.Header.Set("If-None-Match", c.etag)
+	}
+
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		c.data, c.etag = data, resp.Header.Get("ETag")
+		c.format = httpFormat(resp.Header.Get("Content-Type"))
+		if c.Cache != "" {
+			if err := c.writeCache(); err != nil {
+				return nil, err
+			}
+		}
+	case http.StatusNotModified:
+		if format := httpFormat(resp.Header.Get("Content-Type")); format != "" {
+			c.format = format
+		}
+	case http.StatusNotFound:
+		if c.IgnoreNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("http: %s: %s", c.URL, resp.Status)
+	default:
+		return nil, fmt.Errorf("http: %s: %s", c.URL, resp.Status)
+	}
+	// The Store is created by New, which cannot fail.
+	if _, err := construct.NewStore(c.storeFormat(), nil); err != nil {
+		return nil, fmt.Errorf("http: %s: %v", c.URL, err)
+	}
+	return ioutil.NopCloser(bytes.NewReader(c.data)), nil
+}
+
+func (c *ConfigHTTP) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	if c.TLS == nil {
+		return http.DefaultClient
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = c.TLS
+	return &http.Client{Transport: transport}
+}
+
+// readCache loads the cached document, its ETag and format, if any.
+func (c *ConfigHTTP) readCache() {
+	data, err := ioutil.ReadFile(c.Cache)
+	if err != nil {
+		return
+	}
+	meta, err := ioutil.ReadFile(c.Cache + ".etag")
+	if err != nil {
+		return
+	}
+	lines := strings.SplitN(string(meta), "\n", 2)
+	c.data, c.etag = data, lines[0]
+	if len(lines) == 2 {
+		c.format = lines[1]
+	}
+}
+
+// writeCache saves the document, its ETag and format.
+func (c *ConfigHTTP) writeCache() error {
+	if err := ioutil.WriteFile(c.Cache, c.data, 0600); err != nil {
+		return err
+	}
+	if c.etag == "" {
+		err := os.Remove(c.Cache + ".etag")
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return ioutil.WriteFile(c.Cache+".etag", []byte(c.etag+"\n"+c.format), 0600)
+}
+
+// httpFormat returns the format of the given content type, if registered.
+func httpFormat(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	// e.g. application/json, application/x-yaml, text/x-toml, application/vnd.app+json.
+	format := mt[strings.IndexByte(mt, '/')+1:]
+	if i := strings.LastIndexByte(format, '+'); i >= 0 {
+		format = format[i+1:]
+	}
+	format = strings.TrimPrefix(format, "x-")
+	if _, err := construct.NewStore(format, nil); err != nil {
+		return ""
+	}
+	return format
+}
+
+// Save is a no-op as the config cannot be saved.
+func (c *ConfigHTTP) Save() (io.WriteCloser, error) { return nil, nil }
+
+// New returns the Store for the config document format.
+func (c *ConfigHTTP) New(lookup construct.LookupFn) construct.Store {
+	store, _ := construct.NewStore(c.storeFormat(), lookup)
+	return store
+}
+
+// storeFormat returns the format of the config document.
+func (c *ConfigHTTP) storeFormat() string {
+	format := c.Format
+	if format == "" {
+		format = c.format
+	}
+	if format == "" {
+		if u, err := url.Parse(c.URL); err == nil {
+			format = strings.TrimPrefix(path.Ext(u.Path), ".")
+		}
+	}
+	return format
+}
@@ -0,0 +1,92 @@
+package constructs_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type httpServer struct {
+	constructs.ConfigHTTP
+	Host string
+	Port int
+}
+
+func (*httpServer) Init() error              { return nil }
+func (*httpServer) Usage(name string) string { return "" }
+
+func TestConfigHTTP(t *testing.T) {
+	var fetched, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+			return
+		case "/text":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("Host = texthost"))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetched++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"Host": "httphost", "Port": 8080}`))
+	}))
+	defer srv.Close()
+
+	cache := filepath.Join(t.TempDir(), "config.cache")
+	for i := 0; i < 2; i++ {
+		c := &httpServer{}
+		c.URL = srv.URL + "/config"
+		c.Auth = "Bearer token"
+		c.Cache = cache
+		if err := construct.LoadArgs(c, nil); err != nil {
+			t.Fatal(err)
+		}
+		if c.Host != "httphost" || c.Port != 8080 {
+			t.Errorf("%d: config not loaded: %+v", i, c)
+		}
+	}
+	if fetched != 1 || notModified != 1 {
+		t.Errorf("got %d fetches and %d not modified; expected 1 and 1", fetched, notModified)
+	}
+
+	c := &httpServer{}
+	c.URL = srv.URL + "/config.json"
+	if err := construct.LoadArgs(c, nil); err == nil {
+		t.Error("expected an error without authorization")
+	}
+
+	// Missing documents are only ignored on request.
+	c = &httpServer{}
+	c.URL = srv.URL + "/missing"
+	if err := construct.LoadArgs(c, nil); err == nil {
+		t.Error("expected an error on missing config")
+	}
+	c.IgnoreNotFound = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Error(err)
+	}
+
+	// Unknown format.
+	c = &httpServer{}
+	c.URL = srv.URL + "/text"
+	err := construct.LoadArgs(c, nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown store format") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package constructs
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pierrec/construct"
+)
+
+// KVClient is the interface implemented by clients of key/value stores such
+// as etcd and Consul, see EtcdKV and ConsulKV.
+type KVClient interface {
+	// List returns the values of all the keys starting with prefix, by key.
+	List(ctx context.Context, prefix string) (map[string]string, error)
+	// Put sets the value of the key.
+	Put(ctx context.Context, key, value string) error
+}
+
+// KVWatcher is optionally implemented by a KVClient supporting change notifications.
+type KVWatcher interface {
+	// Watch returns a channel receiving a value whenever a key starting with
+	// prefix changes. The channel is closed when the context is done.
+	Watch(ctx context.Context, prefix string) (<-chan struct{}, error)
+}
+
+var _ construct.Config = (*ConfigKV)(nil)
+
+// ConfigKV implements the FromRemote interface for config items stored in a
+// key/value store, their key being made of the prefix followed by their path
+// separated by slashes, e.g. myapp/DB/Host for the Host item of the DB group
+// under the myapp prefix.
+//
+// The keys under the prefix are fetched at once when the config is loaded.
+// The config is watched with construct.Watch, reloading it as soon as the
+// store notifies a change if the Client implements KVWatcher.
+type ConfigKV struct {
+	// Client used to access the key/value store.
+	Client KVClient `cfg:"-"`
+	// Prefix of the keys of the config items.
+	Prefix string `cfg:",noio"`
+	// Timeout for accessing the key/value store.
+	// Leave empty to disable.
+	Timeout time.Duration `cfg:",noio"`
+}
+
+var _ construct.FromRemote = (*ConfigKV)(nil)
+var _ construct.FromRemoteNotifier = (*ConfigKV)(nil)
+
+// Init initializes the ConfigKV.
+func (*ConfigKV) Init() error { return nil }
+
+// Usage returns the ConfigKV usage for each of its options.
+func (c *ConfigKV) Usage(name string) string {
+	switch name {
+	case "Prefix":
+		return "Config keys prefix"
+	case "Timeout":
+		return "Timeout for accessing the config store"
+	}
+	return ""
+}
+
+// prefix returns the prefix of the keys, with its trailing slash if any.
+func (c *ConfigKV) prefix() string {
+	prefix := strings.Trim(c.Prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// Remote returns the client serving the keys under Prefix.
+func (c *ConfigKV) Remote() (construct.RemoteClient, string) {
+	if c.Client == nil {
+		return nil, ""
+	}
+	prefix := c.prefix()
+	return &kvClient{ConfigKV: c, prefix: prefix}, prefix
+}
+
+// Notify returns a channel receiving a value whenever the config items change,
+// if the Client implements KVWatcher.
+func (c *ConfigKV) Notify(ctx context.Context) (<-chan struct{}, error) {
+	w, ok := c.Client.(KVWatcher)
+	if !ok {
+		return nil, nil
+	}
+	return w.Watch(ctx, c.prefix())
+}
+
+// kvClient lists the keys on the first lookup.
+type kvClient struct {
+	*ConfigKV
+	prefix string
+	kvs    map[string]string
+	err    error
+}
+
+var _ construct.RemoteClientContext = (*kvClient)(nil)
+
+func (c *kvClient) Get(key string) (string, bool, error) {
+	return c.GetContext(context.Background(), key)
+}
+
+func (c *kvClient) GetContext(ctx context.Context, key string) (string, bool, error) {
+	if c.kvs == nil && c.err == nil {
+		if c.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+		}
+		c.kvs, c.err = c.Client.List(ctx, c.prefix)
+	}
+	if c.err != nil {
+		return "", false, c.err
+	}
+	v, ok := c.kvs[key]
+	return v, ok, nil
+}
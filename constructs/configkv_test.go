@@ -0,0 +1,163 @@
+package constructs_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+// consulServer fakes the Consul KV HTTP API, including blocking queries.
+type consulServer struct {
+	mu      sync.Mutex
+	kvs     map[string]string
+	index   int
+	changed chan struct{}
+}
+
+func (s *consulServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	s.mu.Lock()
+	if r.Method == http.MethodPut {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.kvs[key] = string(body)
+		s.index++
+		close(s.changed)
+		s.changed = make(chan struct{})
+		s.mu.Unlock()
+		return
+	}
+	if index := r.URL.Query().Get("index"); index == strconv.Itoa(s.index) {
+		changed := s.changed
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+		}
+		s.mu.Lock()
+	}
+	defer s.mu.Unlock()
+	type entry struct {
+		Key   string
+		Value []byte
+	}
+	var entries []entry
+	for k, v := range s.kvs {
+		if strings.HasPrefix(k, key) {
+			entries = append(entries, entry{k, []byte(v)})
+		}
+	}
+	w.Header().Set("X-Consul-Index", strconv.Itoa(s.index))
+	json.NewEncoder(w).Encode(entries)
+}
+
+type kvConfig struct {
+	constructs.ConfigKV
+	Tags    []string
+	DB      DirDB
+	changed chan *kvConfig
+	keys    chan []string
+}
+
+func (*kvConfig) Init() error              { return nil }
+func (*kvConfig) Usage(name string) string { return "" }
+func (c *kvConfig) OnChange(config construct.Config, keys []string) {
+	c.changed <- config.(*kvConfig)
+	c.keys <- keys
+}
+
+func TestConsulKV(t *testing.T) {
+	srv := &consulServer{
+		kvs: map[string]string{
+			"myapp/DB/Host": "dbhost",
+			"myapp/Tags":    "a,b",
+			"other/DB/Host": "otherhost",
+		},
+		changed: make(chan struct{}),
+	}
+	hs := httptest.NewServer(srv)
+	defer hs.Close()
+	consul := &constructs.ConsulKV{Address: hs.URL}
+
+	c := &kvConfig{changed: make(chan *kvConfig, 1), keys: make(chan []string, 1)}
+	c.Client = consul
+	c.Prefix = "myapp"
+	c.DB.Port = 5432
+	w, err := construct.Watch(c, construct.OptionWatchInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if c.DB.Host != "dbhost" || c.DB.Port != 5432 || !reflect.DeepEqual(c.Tags, []string{"a", "b"}) {
+		t.Fatalf("config not loaded: %+v", c)
+	}
+
+	if err := consul.Put(context.Background(), "myapp/DB/Host", "newhost"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case next := <-c.changed:
+		if next.DB.Host != "newhost" {
+			t.Errorf("got %q; expected newhost", next.DB.Host)
+		}
+		if keys, want := <-c.keys, []string{"DB-Host"}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("got %v; expected %v", keys, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("config change not notified: %v", w.Err())
+	}
+}
+
+func TestEtcdKV(t *testing.T) {
+	var mu sync.Mutex
+	kvs := map[string]string{"myapp/DB/Host": "dbhost", "myapq/DB/Host": "otherhost"}
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string][]byte
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/v3/kv/put":
+			kvs[string(req["key"])] = string(req["value"])
+			w.Write([]byte("{}"))
+		case "/v3/kv/range":
+			type kv struct {
+				Key   []byte `json:"key"`
+				Value []byte `json:"value"`
+			}
+			var res struct {
+				Kvs []kv `json:"kvs"`
+			}
+			for k, v := range kvs {
+				if k >= string(req["key"]) && k < string(req["range_end"]) {
+					res.Kvs = append(res.Kvs, kv{[]byte(k), []byte(v)})
+				}
+			}
+			json.NewEncoder(w).Encode(res)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer hs.Close()
+
+	c := &kvConfig{}
+	c.Client = &constructs.EtcdKV{Address: hs.URL}
+	c.Prefix = "myapp/"
+	c.DB.Port = 5432
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.DB.Host != "dbhost" || c.DB.Port != 5432 {
+		t.Errorf("config not loaded: %+v", c)
+	}
+}
@@ -72,7 +72,7 @@ func (lg *ConfigLog) Init() error {
 func (lg *ConfigLog) Usage(name string) string {
 	switch name {
 	case "Filename":
-		return "file to write logs to (default=stderr)"
+		return "file to write logs to (stderr if empty)"
 	case "Level":
 		levels := []colog.Level{colog.LTrace, colog.LDebug, colog.LInfo, colog.LWarning, colog.LError}
 		return fmt.Sprintf("logging level (one of %v)", levels)
@@ -0,0 +1,99 @@
+package constructs
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pierrec/construct"
+)
+
+var _ construct.Config = (*ConfigSSM)(nil)
+
+// SSMClient is the interface implemented by clients of the AWS Systems Manager
+// Parameter Store or Secrets Manager. It is typically a thin wrapper around the
+// AWS SDK client, e.g. paginating GetParametersByPath with decryption, or
+// flattening the JSON documents of the secrets under the path.
+type SSMClient interface {
+	// Parameters returns the values of all the parameters under the path,
+	// recursively, by their full name, e.g. /myapp/DB/Host.
+	Parameters(ctx context.Context, path string) (map[string]string, error)
+}
+
+// ConfigSSM implements the FromRemote interface for config items stored as
+// AWS parameters under a path, e.g. /myapp/DB/Host for the Host item of the
+// DB group under the /myapp path, so that no local config file is required.
+//
+// The parameters are fetched at once when the config is loaded.
+// Their priority is set with construct.OptionSources and construct.SourceRemote.
+type ConfigSSM struct {
+	// Client used to fetch the parameters.
+	Client SSMClient `cfg:"-"`
+	// Path prefix of the parameters, e.g. /myapp.
+	Path string `cfg:",noio"`
+	// Timeout for fetching the parameters.
+	// Leave empty to disable.
+	Timeout time.Duration `cfg:",noio"`
+	// Sensitive config items are only loaded from the parameters if set,
+	// e.g. for SecureString parameters or Secrets Manager secrets.
+	Sensitive bool `cfg:"-"`
+}
+
+var _ construct.FromRemote = (*ConfigSSM)(nil)
+var _ construct.SecureIO = (*ConfigSSM)(nil)
+
+// Init initializes the ConfigSSM.
+func (*ConfigSSM) Init() error { return nil }
+
+// Usage returns the ConfigSSM usage for each of its options.
+func (c *ConfigSSM) Usage(name string) string {
+	switch name {
+	case "Path":
+		return "Parameters path"
+	case "Timeout":
+		return "Timeout for fetching the parameters"
+	}
+	return ""
+}
+
+// Remote returns the client serving the parameters under Path.
+func (c *ConfigSSM) Remote() (construct.RemoteClient, string) {
+	if c.Client == nil {
+		return nil, ""
+	}
+	path := "/" + strings.Trim(c.Path, "/")
+	return &ssmClient{ConfigSSM: c, path: path}, path
+}
+
+// Secure returns whether or not sensitive config items are loaded from the parameters.
+func (c *ConfigSSM) Secure() bool { return c.Sensitive }
+
+// ssmClient fetches the parameters on the first lookup.
+type ssmClient struct {
+	*ConfigSSM
+	path   string
+	params map[string]string
+	err    error
+}
+
+var _ construct.RemoteClientContext = (*ssmClient)(nil)
+
+func (c *ssmClient) Get(key string) (string, bool, error) {
+	return c.GetContext(context.Background(), key)
+}
+
+func (c *ssmClient) GetContext(ctx context.Context, key string) (string, bool, error) {
+	if c.params == nil && c.err == nil {
+		if c.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+		}
+		c.params, c.err = c.Client.Parameters(ctx, c.path)
+	}
+	if c.err != nil {
+		return "", false, c.err
+	}
+	v, ok := c.params[key]
+	return v, ok, nil
+}
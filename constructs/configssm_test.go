@@ -0,0 +1,53 @@
+package constructs_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+// ssmClient fakes an AWS Parameter Store client.
+type ssmClient map[string]string
+
+func (c ssmClient) Parameters(ctx context.Context, path string) (map[string]string, error) {
+	params := make(map[string]string)
+	for name, v := range c {
+		if strings.HasPrefix(name, path+"/") {
+			params[name] = v
+		}
+	}
+	return params, nil
+}
+
+type ssmConfig struct {
+	constructs.ConfigSSM
+	Level string
+	DB    DirDB
+}
+
+func (*ssmConfig) Init() error              { return nil }
+func (*ssmConfig) Usage(name string) string { return "" }
+
+func TestConfigSSM(t *testing.T) {
+	client := ssmClient{
+		"/myapp/Level":       "debug",
+		"/myapp/DB/Host":     "dbhost",
+		"/myapp/DB/Port":     "5432",
+		"/myapp/DB/Password": "secret",
+		"/other/Level":       "info",
+	}
+	c := &ssmConfig{}
+	c.Client = client
+	c.Path = "/myapp/"
+	c.Sensitive = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := DirDB{Host: "dbhost", Port: 5432, Password: "secret"}
+	if c.Level != "debug" || c.DB != want {
+		t.Errorf("unexpected config: %+v", c)
+	}
+}
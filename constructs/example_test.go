@@ -31,30 +31,37 @@ type Server struct {
 var _ construct.Config = (*Server)(nil)
 var _ construct.FromFlags = (*Server)(nil)
 
-func (c *Server) DoConfig() {}
+func (c *Server) Init() error { return nil }
 
-// UsageConfig returns the usage for the Server struct fields.
-// The UsageConfig method for the embedded struct is automatically called by construct.
-func (c *Server) UsageConfig(name string) string {
+// Usage returns the usage for the Server struct fields.
+// The Usage method for the embedded struct is automatically called by construct.
+func (c *Server) Usage(name string) string {
 	switch name {
-	case "host":
+	case "Host":
 		return "host to connect to"
-	case "port":
+	case "Port":
 		return "listening port to connect to"
-	case "login":
+	case "Login":
 		return "login username"
-	case "password":
+	case "Password":
 		return "password for the user"
 	}
 	return ""
 }
 
+func (c *Server) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+
+func (c *Server) FlagsShort(name string) string { return "" }
+
 func Example() {
 	Server := &Server{
 		ConfigFileINI: constructs.ConfigFileINI{
-			Name:   "config.ini",
-			Backup: ".bak",
-			Save:   true},
+			ConfigFile: constructs.ConfigFile{
+				Name:   "config.ini",
+				Backup: ".bak",
+				ToSave: true,
+			},
+		},
 		Host:     "localhost",
 		Port:     80,
 		Login:    "xxlogin",
@@ -74,12 +81,17 @@ func Example() {
 	pretty.Println(Server)
 
 	// Output:
-	// 	&construct_test.Server{
+	// &constructs_test.Server{
 	//     ConfigFileINI: constructs.ConfigFileINI{
-	//         Name:       "config.ini",
-	//         Backup:     ".bak",
-	//         Save:       true,
-	//         configFile: constructs.configFile{},
+	//         ConfigFile: constructs.ConfigFile{
+	//             Name:   "config.ini",
+	//             Backup: ".bak",
+	//             ToSave: true,
+	//             Paths:  nil,
+	//             Merge:  false,
+	//             path:   "",
+	//         },
+	//         Nesting: 0,
 	//     },
 	//     Host:     "localhost",
 	//     Port:     80,
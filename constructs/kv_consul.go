@@ -0,0 +1,158 @@
+package constructs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var _ KVClient = (*ConsulKV)(nil)
+var _ KVWatcher = (*ConsulKV)(nil)
+
+// ConsulKV is a KVClient for the Consul KV store, using its HTTP API.
+// Changes are watched with blocking queries.
+type ConsulKV struct {
+	// Address of the Consul agent, e.g. http://127.0.0.1:8500.
+	// It defaults to the CONSUL_HTTP_ADDR environment variable,
+	// then to http://127.0.0.1:8500.
+	Address string
+	// Token used to authenticate to Consul.
+	// It defaults to the CONSUL_HTTP_TOKEN environment variable.
+	Token string
+	// Client used to query Consul instead of the default one.
+	Client *http.Client
+}
+
+// consulWait is the maximum duration of the blocking queries.
+const consulWait = 5 * time.Minute
+
+func (c *ConsulKV) request(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	addr := c.Address
+	if addr == "" {
+		addr = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if addr == "" {
+		addr = "http://127.0.0.1:8500"
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u := strings.TrimSuffix(addr, "/") + "/v1/kv/" + key
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	token := c.Token
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	if token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// list returns the values of the keys starting with prefix and the Consul index,
+// blocking until it is greater than index if not zero.
+func (c *ConsulKV) list(ctx context.Context, prefix, index string) (map[string]string, string, error) {
+	query := url.Values{"recurse": {"true"}}
+	if index != "" {
+		query.Set("index", index)
+		query.Set("wait", consulWait.String())
+	}
+	resp, err := c.request(ctx, http.MethodGet, prefix, query, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	index = resp.Header.Get("X-Consul-Index")
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return map[string]string{}, index, nil
+	default:
+		return nil, "", fmt.Errorf("consul: %s: %s", prefix, resp.Status)
+	}
+	var entries []struct {
+		Key   string
+		Value []byte // Base64 encoded.
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, "", fmt.Errorf("consul: %s: %v", prefix, err)
+	}
+	kvs := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Key, "/") {
+			// Not a folder.
+			kvs[e.Key] = string(e.Value)
+		}
+	}
+	return kvs, index, nil
+}
+
+// List returns the values of all the keys starting with prefix.
+func (c *ConsulKV) List(ctx context.Context, prefix string) (map[string]string, error) {
+	kvs, _, err := c.list(ctx, prefix, "")
+	return kvs, err
+}
+
+// Put sets the value of the key.
+func (c *ConsulKV) Put(ctx context.Context, key, value string) error {
+	resp, err := c.request(ctx, http.MethodPut, key, nil, []byte(value))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("consul: %s: %s", key, resp.Status)
+	}
+	return nil
+}
+
+// Watch notifies the changes of the keys starting with prefix.
+// Errors are retried after a second.
+func (c *ConsulKV) Watch(ctx context.Context, prefix string) (<-chan struct{}, error) {
+	_, index, err := c.list(ctx, prefix, "")
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+		for ctx.Err() == nil {
+			_, next, err := c.list(ctx, prefix, index)
+			if err != nil {
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second):
+				}
+				continue
+			}
+			if next == index {
+				// Wait timed out.
+				continue
+			}
+			index = next
+			select {
+			case ch <- struct{}{}:
+			default:
+				// A notification is already pending.
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,166 @@
+package constructs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var _ KVClient = (*EtcdKV)(nil)
+var _ KVWatcher = (*EtcdKV)(nil)
+
+// EtcdKV is a KVClient for etcd version 3, using its gRPC gateway JSON API.
+type EtcdKV struct {
+	// Address of the etcd server.
+	// It defaults to http://127.0.0.1:2379.
+	Address string
+	// Token used to authenticate to etcd, as returned by its authenticate API.
+	Token string
+	// Client used to query etcd instead of the default one.
+	Client *http.Client
+}
+
+// etcdRange returns the range of the keys starting with prefix.
+// Byte slices are base64 encoded in JSON as expected by the gateway.
+func etcdRange(prefix string) (key, end []byte) {
+	key = []byte(prefix)
+	if len(key) == 0 {
+		// All keys.
+		return []byte{0}, []byte{0}
+	}
+	end = append([]byte(nil), key...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return key, end[:i+1]
+		}
+	}
+	return key, []byte{0}
+}
+
+func (c *EtcdKV) request(ctx context.Context, api string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	addr := c.Address
+	if addr == "" {
+		addr = "http://127.0.0.1:2379"
+	}
+	u := strings.TrimSuffix(addr, "/") + "/v3/" + api
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", c.Token)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("etcd: %s: %s", api, resp.Status)
+	}
+	return resp, nil
+}
+
+// List returns the values of all the keys starting with prefix.
+func (c *EtcdKV) List(ctx context.Context, prefix string) (map[string]string, error) {
+	key, end := etcdRange(prefix)
+	resp, err := c.request(ctx, "kv/range", map[string][]byte{"key": key, "range_end": end})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Kvs []struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("etcd: %s: %v", prefix, err)
+	}
+	kvs := make(map[string]string, len(res.Kvs))
+	for _, kv := range res.Kvs {
+		kvs[string(kv.Key)] = string(kv.Value)
+	}
+	return kvs, nil
+}
+
+// Put sets the value of the key.
+func (c *EtcdKV) Put(ctx context.Context, key, value string) error {
+	resp, err := c.request(ctx, "kv/put", map[string][]byte{"key": []byte(key), "value": []byte(value)})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Watch notifies the changes of the keys starting with prefix.
+// The watch stream is reopened after a second on errors.
+func (c *EtcdKV) Watch(ctx context.Context, prefix string) (<-chan struct{}, error) {
+	key, end := etcdRange(prefix)
+	req := map[string]interface{}{
+		"create_request": map[string][]byte{"key": key, "range_end": end},
+	}
+	resp, err := c.request(ctx, "watch", req)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+		for {
+			c.watch(resp, ch)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Second):
+				}
+				if resp, err = c.request(ctx, "watch", req); err == nil {
+					break
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// watch notifies the events read from the watch stream until it ends.
+func (c *EtcdKV) watch(resp *http.Response, ch chan struct{}) {
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Result struct {
+				Events []json.RawMessage `json:"events"`
+			} `json:"result"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			return
+		}
+		if len(msg.Result.Events) == 0 {
+			// Watch created or progress notification.
+			continue
+		}
+		select {
+		case ch <- struct{}{}:
+		default:
+			// A notification is already pending.
+		}
+	}
+}
@@ -1,9 +1,15 @@
 package constructs
 
 import (
+	"encoding"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/pierrec/construct"
 	"github.com/pierrec/construct/internal/structs"
@@ -23,6 +29,51 @@ func (r *reader) Read(b []byte) (int, error) {
 	return n, err
 }
 
+// floatPlaceholder prefixes the floats formatted with structs.FormatFloat
+// by the stores which encoder formats them differently.
+// The placeholders are replaced by the formatted floats when writing.
+const floatPlaceholder = "construct-float:"
+
+var floatPlaceholderRe = regexp.MustCompile(`"?` + floatPlaceholder + `([-+.0-9]+)"?`)
+
+// replaceFloats replaces the float placeholders in s with the formatted floats.
+func replaceFloats(s string) string {
+	if !strings.Contains(s, floatPlaceholder) {
+		return s
+	}
+	return floatPlaceholderRe.ReplaceAllString(s, "$1")
+}
+
+// formatFloats returns v with its finite floats, including the items of
+// lists, formatted with structs.FormatFloat and converted by conv, so that
+// all the stores write floats the same way.
+func formatFloats(v interface{}, conv func(string) interface{}) interface{} {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f := value.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return v
+		}
+		return conv(structs.FormatFloat(f, value.Type().Bits()))
+	case reflect.Slice, reflect.Array:
+		switch value.Type().Elem().Kind() {
+		case reflect.Float32, reflect.Float64, reflect.Interface:
+		default:
+			return v
+		}
+		l := make([]interface{}, value.Len())
+		for i := range l {
+			l[i] = formatFloats(value.Index(i).Interface(), conv)
+		}
+		return l
+	}
+	return v
+}
+
+// jsonNumber returns the JSON number for the formatted float s.
+func jsonNumber(s string) interface{} { return json.Number(s) }
+
 // marshal makes sure the given value v is suitable for storage.
 // It may update the Store directly in which case the returned value is nil.
 func marshal(store construct.Store, marshal func([]string, interface{}) (interface{}, error),
@@ -59,6 +110,17 @@ func marshal(store construct.Store, marshal func([]string, interface{}) (interfa
 		err := marshalMap(store, marshal, keys, v)
 		return nil, err
 
+	case reflect.Struct:
+		if isMarshaler(v) {
+			mv, err := structs.MarshalValue(v, seps)
+			if err != nil {
+				return nil, err
+			}
+			return fmt.Sprintf("%v", mv), nil
+		}
+		err := marshalStruct(store, keys, v)
+		return nil, err
+
 	default:
 		mv, err := structs.MarshalValue(v, seps)
 		if err != nil {
@@ -81,21 +143,194 @@ func marshalMap(store construct.Store, marshal func([]string, interface{}) (inte
 		store.Set(zero, keys...)
 		return nil
 	}
-	mkeys := value.MapKeys()
-	for i := 0; i < n; i++ {
-		key := mkeys[i]
+	// Set the entries by key order so that they are stored deterministically.
+	type entry struct {
+		skey string
+		key  reflect.Value
+	}
+	entries := make([]entry, n)
+	for i, key := range value.MapKeys() {
 		mkey, err := marshal(keys, key.Interface())
 		if err != nil {
 			return err
 		}
-		skey := fmt.Sprintf("%v", mkey)
-		nkeys := append(keys, skey)
-		el := value.MapIndex(key)
+		entries[i] = entry{fmt.Sprintf("%v", mkey), key}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].skey < entries[j].skey })
+	for _, e := range entries {
+		nkeys := append(keys, e.skey)
+		el := value.MapIndex(e.key)
 		mel, err := marshal(nkeys, el.Interface())
 		if err != nil {
 			return err
 		}
+		if mel == nil {
+			// The store was updated directly.
+			continue
+		}
 		store.Set(mel, nkeys...)
 	}
 	return nil
 }
+
+// marshalStruct populates the store with the exported fields of the struct v.
+func marshalStruct(store construct.Store, keys []string, v interface{}) error {
+	value := reflect.ValueOf(v)
+	t := value.Type()
+	for i, n := 0, t.NumField(); i < n; i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// Unexported field.
+			continue
+		}
+		nkeys := append(keys[:len(keys):len(keys)], field.Name)
+		if err := store.Set(value.Field(i).Interface(), nkeys...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapKeys returns the keys of all the values in m, prefixed with keys.
+// Nested maps are walked, unless empty.
+func mapKeys(m map[string]interface{}, keys []string) [][]string {
+	var res [][]string
+	for k, v := range m {
+		ks := append(keys[:len(keys):len(keys)], k)
+		if sub, ok := v.(map[string]interface{}); ok && len(sub) > 0 {
+			res = append(res, mapKeys(sub, ks)...)
+			continue
+		}
+		res = append(res, ks)
+	}
+	return res
+}
+
+// isMarshaler returns whether or not v is serialized as a string, e.g. by its
+// MarshalText, MarshalBinary or String method.
+func isMarshaler(v interface{}) bool {
+	switch v.(type) {
+	case encoding.TextMarshaler, encoding.BinaryMarshaler:
+		return true
+	}
+	return v != nil && structs.IsScalar(reflect.TypeOf(v))
+}
+
+// keyOrder records the order in which the keys of nested maps are first set,
+// by the path of their parent map.
+type keyOrder map[string][]string
+
+// add records the keys path.
+func (o keyOrder) add(keys []string) {
+	for i, key := range keys {
+		parent := strings.Join(keys[:i], "\x00")
+		found := false
+		for _, k := range o[parent] {
+			if found = k == key; found {
+				break
+			}
+		}
+		if !found {
+			o[parent] = append(o[parent], key)
+		}
+	}
+}
+
+// keys returns the keys of m, which path is keys, in the order they were set
+// followed by the ones never set in alphabetical order.
+func (o keyOrder) keys(m map[string]interface{}, keys []string) []string {
+	res := make([]string, 0, len(m))
+	done := make(map[string]bool, len(m))
+	for _, k := range o[strings.Join(keys, "\x00")] {
+		if _, ok := m[k]; ok && !done[k] {
+			res = append(res, k)
+			done[k] = true
+		}
+	}
+	var rest []string
+	for k := range m {
+		if !done[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	return append(res, rest...)
+}
+
+// commentKey returns the key of the comment for the keys path.
+func commentKey(keys []string) string {
+	return strings.Join(keys, "\x00")
+}
+
+// globalComment is the key of the comment for the whole document.
+var globalComment = commentKey([]string{"", ""})
+
+// readComments records into comments the ones preceding the lines of the src
+// document for which keysOf, invoked on every line in order, returns keys.
+// A comment at the top of src followed by an empty line is the global one.
+func readComments(comments map[string]string, src string, keysOf func(line string) []string) {
+	var comment []string
+	top := true
+	for _, line := range strings.Split(src, "\n") {
+		switch l := strings.TrimSpace(line); {
+		case strings.HasPrefix(l, "#"):
+			comment = append(comment, strings.TrimPrefix(l[1:], " "))
+			continue
+		case l == "":
+			if top && comment != nil {
+				comments[globalComment] = strings.Join(comment, "\n")
+			}
+			comment = nil
+			continue
+		}
+		top = false
+		if keys := keysOf(line); keys != nil && comment != nil {
+			comments[commentKey(keys)] = strings.Join(comment, "\n")
+		}
+		comment = nil
+	}
+}
+
+// writeComments returns the src document with the comments inserted before
+// the lines for which keysOf, invoked on every line in order, returns keys.
+// Keys repeated in the document are only commented once.
+func writeComments(comments map[string]string, src string, keysOf func(line string) []string) string {
+	var b strings.Builder
+	if c, ok := comments[globalComment]; ok {
+		writeComment(&b, c, "")
+		b.WriteByte('\n')
+	}
+	done := make(map[string]bool)
+	for _, line := range strings.SplitAfter(src, "\n") {
+		if keys := keysOf(line); keys != nil {
+			if key := commentKey(keys); !done[key] {
+				done[key] = true
+				if c, ok := comments[key]; ok {
+					indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+					writeComment(&b, c, indent)
+				}
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// writeComment writes the lines of comment prefixed with indent and "# ".
+func writeComment(b *strings.Builder, comment, indent string) {
+	for _, line := range strings.Split(comment, "\n") {
+		fmt.Fprintf(b, "%s# %s\n", indent, line)
+	}
+}
+
+// countWriter counts the bytes written to w.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(buf []byte) (int, error) {
+	n, err := cw.w.Write(buf)
+	cw.n += int64(n)
+	return n, err
+}
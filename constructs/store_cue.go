@@ -0,0 +1,372 @@
+package constructs
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"time"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/token"
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/internal/structs"
+)
+
+var _ construct.Config = (*ConfigFileCUE)(nil)
+
+// ConfigFileCUE implements the FromIO interface for CUE formatted files.
+//
+// The file is evaluated by CUE: its constraints, definitions, references and
+// the standard library packages are supported, e.g.
+//  #Port: int & >0 & <65536
+//  Level: *"info" | "debug" | "error"
+//  Server: Port: #Port & 8080
+// An error is returned if a value does not satisfy its constraints.
+// The config items are set from the concrete values of the regular fields, or their
+// default value. Fields without a concrete value are not set.
+//
+// Comments are written before their key when saving, and read from the file are kept.
+// Constraints are not saved: the schema of a config can be generated with CUESchema.
+type ConfigFileCUE struct {
+	ConfigFile `cfg:",inline"`
+}
+
+var _ construct.FromIO = (*ConfigFileCUE)(nil)
+
+// New returns the Store for a CUE formatted file.
+func (c *ConfigFileCUE) New(lookup construct.LookupFn) construct.Store {
+	return NewStoreCUE(lookup)
+}
+
+// NewStoreCUE returns a Store based on the CUE format.
+func NewStoreCUE(lookup construct.LookupFn) construct.Store {
+	store := NewStoreJSON(lookup).(*jsonStore)
+	return &cueStore{jsonStore: store, comments: make(map[string]string)}
+}
+
+// CUESchema writes to w the CUE schema of config as the #Config definition,
+// derived from the types of its config items and documented with their usage.
+// Sensitive and secret config items are not included.
+//
+// The schema can be unified with a config file to validate it, e.g. with
+// cue vet schema.cue config.cue -d '#Config'.
+func CUESchema(config construct.Config, w io.Writer, options ...construct.Option) error {
+	newStore := func(lookup construct.LookupFn) construct.Store {
+		store := NewStoreCUE(lookup).(*cueStore)
+		store.schema = true
+		return store
+	}
+	return construct.SaveTo(config, newStore, w, options...)
+}
+
+var (
+	_ construct.Store        = (*cueStore)(nil)
+	_ construct.KeysStore    = (*cueStore)(nil)
+	_ construct.CommentStore = (*cueStore)(nil)
+)
+
+// cueStore extends jsonStore with the CUE evaluation, comments and schema generation.
+type cueStore struct {
+	*jsonStore
+	comments map[string]string
+	schema   bool // Store the CUE types of the values instead of the values.
+}
+
+// cueType is the CUE type of a value stored in schema mode.
+type cueType string
+
+func (store *cueStore) Set(v interface{}, keys ...string) error {
+	if !store.schema {
+		if d, ok := v.(time.Duration); ok {
+			// Durations are strings, see cueTypeOf.
+			v = d.String()
+		}
+		return store.jsonStore.Set(v, keys...)
+	}
+	if len(keys) == 0 || v == nil {
+		return nil
+	}
+	if value := reflect.ValueOf(v); value.Kind() == reflect.Struct && !isMarshaler(v) {
+		return marshalStruct(store, keys, v)
+	}
+	store.order.add(keys)
+	return store.set(store.data, cueType(cueTypeOf(reflect.TypeOf(v))), keys)
+}
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+)
+
+// cueTypeOf returns the CUE type of the values of type t once stored.
+func cueTypeOf(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	pt := reflect.PtrTo(t)
+	switch {
+	case pt.Implements(textMarshalerType), pt.Implements(binaryMarshalerType), structs.IsScalar(t):
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int & >=0"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "[..." + cueTypeOf(t.Elem()) + "]"
+	case reflect.Map:
+		return "{[string]: " + cueTypeOf(t.Elem()) + "}"
+	case reflect.Struct:
+		var fields []string
+		for i, n := 0, t.NumField(); i < n; i++ {
+			if f := t.Field(i); f.PkgPath == "" {
+				fields = append(fields, fmt.Sprintf("%s?: %s", f.Name, cueTypeOf(f.Type)))
+			}
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	return "_"
+}
+
+func (store *cueStore) SetComment(comment string, keys ...string) error {
+	store.comments[commentKey(keys)] = comment
+	return nil
+}
+
+func (store *cueStore) Comment(keys ...string) string {
+	return store.comments[commentKey(keys)]
+}
+
+func (store *cueStore) ReadFrom(r io.Reader) (int64, error) {
+	src, err := ioutil.ReadAll(r)
+	n := int64(len(src))
+	if err != nil {
+		return n, err
+	}
+	name := "config.cue"
+	if f, ok := r.(interface{ Name() string }); ok {
+		name = f.Name()
+	}
+	f, err := parser.ParseFile(name, src, parser.ParseComments)
+	if err != nil {
+		return n, cueError(err)
+	}
+	if cs := ast.Comments(f); len(cs) > 0 {
+		store.comments[globalComment] = cueCommentText(cs[0])
+	}
+	cueComments(store.comments, f.Decls, nil)
+
+	v := cuecontext.New().BuildFile(f)
+	if err := v.Validate(); err != nil {
+		return n, cueError(err)
+	}
+	data, err := cueConcrete(v)
+	if err != nil {
+		return n, cueError(err)
+	}
+	store.data = data
+	return n, nil
+}
+
+// cueError returns the CUE error err with the line of each of its errors.
+func cueError(err error) error {
+	var msgs []string
+	for _, e := range errors.Errors(err) {
+		pos := errors.Positions(e)
+		if len(pos) == 0 {
+			// Summary of the following errors, e.g. for disjunctions.
+			continue
+		}
+		format, args := e.Msg()
+		msg := fmt.Sprintf(format, args...)
+		if path := e.Path(); len(path) > 0 {
+			msg = strings.Join(path, ".") + ": " + msg
+		}
+		// The last position is the one of the value.
+		msgs = append(msgs, fmt.Sprintf("line %d: %s", pos[len(pos)-1].Line(), msg))
+	}
+	if len(msgs) == 0 {
+		return fmt.Errorf("cue: %v", err)
+	}
+	return fmt.Errorf("cue: %s", strings.Join(msgs, "; "))
+}
+
+// cueConcrete returns the concrete values of the regular fields of the struct v,
+// or their default ones. Fields without a concrete value are skipped.
+func cueConcrete(v cue.Value) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	iter, err := v.Fields()
+	if err != nil {
+		return nil, err
+	}
+	for iter.Next() {
+		label := iter.Selector().Unquoted()
+		value, _ := iter.Value().Default()
+		if value.IncompleteKind() == cue.StructKind {
+			sub, err := cueConcrete(value)
+			if err != nil {
+				return nil, err
+			}
+			if len(sub) > 0 {
+				m[label] = sub
+			}
+			continue
+		}
+		if value.Validate(cue.Concrete(true)) != nil {
+			// Not set.
+			continue
+		}
+		bts, err := value.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		var x interface{}
+		if err := json.Unmarshal(bts, &x); err != nil {
+			return nil, err
+		}
+		m[label] = x
+	}
+	return m, nil
+}
+
+// cueComments records the doc comments of the regular fields in decls,
+// which path is keys. The comment of a field using the a: b: c shorthand
+// is the one of its innermost field.
+func cueComments(comments map[string]string, decls []ast.Decl, keys []string) {
+	for _, decl := range decls {
+		field, ok := decl.(*ast.Field)
+		if !ok || field.Constraint == token.OPTION {
+			continue
+		}
+		label, _, err := ast.LabelName(field.Label)
+		if err != nil || strings.HasPrefix(label, "_") || strings.HasPrefix(label, "#") {
+			continue
+		}
+		ks := append(keys[:len(keys):len(keys)], label)
+		var doc string
+		for _, c := range ast.Comments(field) {
+			if c.Doc {
+				doc = cueCommentText(c)
+			}
+		}
+		st, ok := field.Value.(*ast.StructLit)
+		if !ok {
+			if doc != "" {
+				comments[commentKey(ks)] = doc
+			}
+			continue
+		}
+		if doc != "" {
+			k := ks
+			for inner := st; !inner.Lbrace.IsValid() && len(inner.Elts) == 1; {
+				f, ok := inner.Elts[0].(*ast.Field)
+				if !ok {
+					break
+				}
+				name, _, err := ast.LabelName(f.Label)
+				if err != nil {
+					break
+				}
+				k = append(k[:len(k):len(k)], name)
+				if inner, ok = f.Value.(*ast.StructLit); !ok {
+					break
+				}
+			}
+			comments[commentKey(k)] = doc
+		}
+		cueComments(comments, st.Elts, ks)
+	}
+}
+
+// cueCommentText returns the text of the comment group without the comment markers.
+func cueCommentText(c *ast.CommentGroup) string {
+	return strings.TrimSuffix(c.Text(), "\n")
+}
+
+func (store *cueStore) WriteTo(w io.Writer) (int64, error) {
+	decls, err := store.decls(store.data, nil)
+	if err != nil {
+		return 0, err
+	}
+	if store.schema {
+		def := &ast.Field{Label: ast.NewIdent("#Config"), Value: &ast.StructLit{Elts: decls}}
+		decls = []ast.Decl{def}
+	}
+	if c, ok := store.comments[globalComment]; ok {
+		decls = append([]ast.Decl{cueComment(c)}, decls...)
+	}
+	bts, err := format.Node(&ast.File{Decls: decls})
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(bts)
+	return int64(n), err
+}
+
+// decls returns the fields for m with the comments of their keys.
+func (store *cueStore) decls(m map[string]interface{}, keys []string) ([]ast.Decl, error) {
+	var decls []ast.Decl
+	for _, name := range store.order.keys(m, keys) {
+		ks := append(keys[:len(keys):len(keys)], name)
+		var value ast.Expr
+		switch v := m[name].(type) {
+		case map[string]interface{}:
+			elts, err := store.decls(v, ks)
+			if err != nil {
+				return nil, err
+			}
+			value = &ast.StructLit{Elts: elts}
+		case cueType:
+			expr, err := parser.ParseExpr(name, string(v))
+			if err != nil {
+				return nil, err
+			}
+			value = expr
+		default:
+			// The JSON representation of a value is valid CUE.
+			bts, err := json.Marshal(formatFloats(v, jsonNumber))
+			if err != nil {
+				return nil, err
+			}
+			expr, err := parser.ParseExpr(name, bts)
+			if err != nil {
+				return nil, err
+			}
+			value = expr
+		}
+		field := &ast.Field{Label: ast.NewStringLabel(name), Value: value}
+		if store.schema {
+			field.Constraint = token.OPTION
+		}
+		if c, ok := store.comments[commentKey(ks)]; ok {
+			ast.AddComment(field, cueComment(c))
+		}
+		decls = append(decls, field)
+	}
+	return decls, nil
+}
+
+// cueComment returns the doc comment group for the comment lines.
+func cueComment(comment string) *ast.CommentGroup {
+	group := &ast.CommentGroup{Doc: true}
+	for _, line := range strings.Split(comment, "\n") {
+		group.List = append(group.List, &ast.Comment{Text: "// " + line})
+	}
+	return group
+}
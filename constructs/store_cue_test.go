@@ -0,0 +1,248 @@
+package constructs_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type CUEServer struct {
+	Host string
+	Port int
+}
+
+func (*CUEServer) Init() error              { return nil }
+func (*CUEServer) Usage(name string) string { return "" }
+
+type cueConfig struct {
+	constructs.ConfigFileCUE
+	Level   string
+	Server  CUEServer
+	Tags    []string
+	Timeout time.Duration
+}
+
+func (*cueConfig) Init() error { return nil }
+func (*cueConfig) Usage(name string) string {
+	switch name {
+	case "":
+		return "Test config."
+	case "Level":
+		return "Log level."
+	}
+	return ""
+}
+
+func TestConfigFileCUE(t *testing.T) {
+	const schema = `#Port: int & >0 & <65536
+
+Level: *"info" | "debug" | "error"
+Server: {
+	// Listen address.
+	Host: string
+	Port: #Port
+}
+Server: Host: "localhost"
+Server: Port: 8080
+Tags: [...string]
+Tags: ["a", "b"]
+Timeout: =~"s$"
+Timeout: "10s"
+Unset?: int
+`
+	for _, tc := range []struct {
+		src string
+		err string
+	}{
+		{schema, ""},
+		{"package config\n\n" + schema, ""},
+		{"package config\n\nimport \"strings\"\n\n" +
+			strings.Replace(schema, `Host: "localhost"`, `Host: strings.ToLower("LOCALHOST")`, 1), ""},
+		{schema + "Level: \"warn\"\n", `line 16: Level: conflicting values "debug" and "warn"`},
+		{strings.Replace(schema, "8080", "0", 1), "line 10: Server.Port: invalid value 0 (out of bound >0)"},
+		{schema + "Server: Port: 80\n", "line 16: Server.Port: conflicting values 80 and 8080"},
+		{schema + "Server: Port: 1.5K1\n", "line 16: missing ','"},
+		{strings.Replace(schema, `["a", "b"]`, `["a", 1]`, 1), "line 12: Tags.1: conflicting values 1 and string"},
+		{schema + "X: Y\n", `line 16: X: reference "Y" not found`},
+	} {
+		name := filepath.Join(t.TempDir(), "config.cue")
+		if err := os.WriteFile(name, []byte(tc.src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		c := &cueConfig{}
+		c.Name = name
+		err := construct.LoadArgs(c, nil, construct.OptionStrictIO(nil))
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("got error %v; expected %q", err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Level != "info" || c.Server.Host != "localhost" || c.Server.Port != 8080 ||
+			len(c.Tags) != 2 || c.Timeout != 10*time.Second {
+			t.Errorf("unexpected config: %+v", c)
+		}
+	}
+}
+
+func TestCUENumbers(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		port int
+	}{
+		{"8080", 8080},
+		{"8_080", 8080},
+		{"0x1f90", 8080},
+		{"0o17620", 8080},
+		{"0b1_1111_1001_0000", 8080},
+		{"8.08K", 8080},
+		{"2Ki", 2048},
+	} {
+		name := filepath.Join(t.TempDir(), "config.cue")
+		if err := os.WriteFile(name, []byte("Server: Port: "+tc.src+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		c := &cueConfig{}
+		c.Name = name
+		if err := construct.LoadArgs(c, nil); err != nil {
+			t.Fatalf("%s: %v", tc.src, err)
+		}
+		if got, want := c.Server.Port, tc.port; got != want {
+			t.Errorf("%s: got %d; expected %d", tc.src, got, want)
+		}
+	}
+}
+
+func TestCUERoundTrip(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.cue")
+	c := &cueConfig{Level: "debug", Tags: []string{"a"}}
+	c.Server.Port = 80
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `// Test config.
+
+// Log level.
+Level: "debug"
+Server: {
+	Host: ""
+	Port: 80
+}
+Tags: ["a"]
+Timeout: "0s"
+`
+	if got := string(data); got != want {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, want)
+	}
+
+	c = &cueConfig{}
+	c.Name = name
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != "debug" || c.Server.Port != 80 {
+		t.Errorf("unexpected config: %+v", c)
+	}
+}
+
+func TestCUESchema(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := constructs.CUESchema(&cueConfig{}, buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `// Test config.
+
+#Config: {
+	// Log level.
+	Level?: string
+	Server?: {
+		Host?: string
+		Port?: int
+	}
+	Tags?: [...string]
+	Timeout?: string
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, want)
+	}
+}
+
+func TestCUEComments(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.cue")
+	src := `// Global comment.
+
+Level: "debug"
+// Port comment.
+Server: Port: 80
+Server: Host: string
+// Tags comment.
+Tags: ["a"]
+`
+	if err := os.WriteFile(name, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := &cueConfig{}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Fields without a concrete value are not set.
+	if c.Level != "debug" || c.Server.Port != 80 || c.Server.Host != "" {
+		t.Errorf("unexpected config: %+v", c)
+	}
+
+	// The comments read are kept, unless replaced by the usage.
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"// Test config.\n\n", "\t// Port comment.\n\tPort: 80", "// Tags comment.\nTags: ["} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("missing %q in:\n%s", want, data)
+		}
+	}
+}
+
+func TestCUESchemaValidate(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := constructs.CUESchema(&cueConfig{}, buf); err != nil {
+		t.Fatal(err)
+	}
+	ctx := cuecontext.New()
+	schema := ctx.CompileBytes(buf.Bytes()).LookupPath(cue.ParsePath("#Config"))
+	if err := schema.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		src string
+		ok  bool
+	}{
+		{`Level: "info", Server: Port: 80, Tags: ["a"]`, true},
+		{`Server: Port: "80"`, false},
+		{`Unknown: 1`, false},
+	} {
+		v := schema.Unify(ctx.CompileString(tc.src))
+		if err := v.Validate(); (err == nil) != tc.ok {
+			t.Errorf("%s: got %v", tc.src, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package constructs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/internal/dotenv"
+	"github.com/pierrec/construct/internal/structs"
+)
+
+var _ construct.Config = (*ConfigFileDotenv)(nil)
+
+// ConfigFileDotenv implements the FromIO interface for dotenv (.env) files.
+//
+// The variable of a config item is named after its upper cased keys joined
+// with an underscore, e.g. DB_HOST for the Host item of the DB group, so that
+// the same names can be used for environment variables.
+type ConfigFileDotenv struct {
+	ConfigFile `cfg:",inline"`
+}
+
+var _ construct.FromIO = (*ConfigFileDotenv)(nil)
+
+// New returns the Store for a dotenv file.
+func (c *ConfigFileDotenv) New(lookup construct.LookupFn) construct.Store {
+	return NewStoreDotenv(lookup)
+}
+
+// NewStoreDotenv returns a Store based on the dotenv format.
+func NewStoreDotenv(lookup construct.LookupFn) construct.Store {
+	return &dotenvStore{
+		lookup:   lookup,
+		values:   make(map[string]string),
+		comments: make(map[string]string),
+	}
+}
+
+var _ construct.Store = (*dotenvStore)(nil)
+
+// dotenvStore holds the variables of a dotenv file.
+type dotenvStore struct {
+	lookup   construct.LookupFn
+	names    []string // Variables names in order of definition.
+	values   map[string]string
+	comments map[string]string
+}
+
+func (store *dotenvStore) StructTag() string { return "dotenv" }
+
+// name returns the variable name for the keys.
+func (store *dotenvStore) name(keys []string) string {
+	return strings.ToUpper(strings.Join(keys, "_"))
+}
+
+func (store *dotenvStore) Has(keys ...string) bool {
+	_, ok := store.values[store.name(keys)]
+	return ok
+}
+
+func (store *dotenvStore) Get(keys ...string) (interface{}, error) {
+	return store.values[store.name(keys)], nil
+}
+
+func (store *dotenvStore) Set(v interface{}, keys ...string) error {
+	seps := store.lookup(keys...)
+	mv, err := structs.MarshalValue(v, seps)
+	if err != nil {
+		return err
+	}
+	store.set(store.name(keys), fmt.Sprintf("%v", mv))
+	return nil
+}
+
+func (store *dotenvStore) set(name, value string) {
+	if _, ok := store.values[name]; !ok {
+		store.names = append(store.names, name)
+	}
+	store.values[name] = value
+}
+
+func (store *dotenvStore) SetComment(comment string, keys ...string) error {
+	store.comments[store.name(keys)] = comment
+	return nil
+}
+
+func (store *dotenvStore) ReadFrom(r io.Reader) (int64, error) {
+	rd := &reader{Reader: r}
+	items, err := dotenv.Parse(rd)
+	if err != nil {
+		return rd.read(), err
+	}
+	for _, item := range items {
+		store.set(strings.ToUpper(item.Key), item.Value)
+	}
+	return rd.read(), nil
+}
+
+func (store *dotenvStore) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	buf := bufio.NewWriter(cw)
+	// The global comment is set with empty keys.
+	if c, ok := store.comments[store.name([]string{"", ""})]; ok {
+		store.writeComment(buf, c)
+		buf.WriteByte('\n')
+	}
+	for _, name := range store.names {
+		if c, ok := store.comments[name]; ok {
+			store.writeComment(buf, c)
+		}
+		fmt.Fprintf(buf, "%s=%s\n", name, dotenv.Quote(store.values[name]))
+	}
+	err := buf.Flush()
+	return cw.n, err
+}
+
+func (store *dotenvStore) writeComment(w *bufio.Writer, comment string) {
+	for _, line := range strings.Split(comment, "\n") {
+		fmt.Fprintf(w, "# %s\n", line)
+	}
+}
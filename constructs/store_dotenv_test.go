@@ -0,0 +1,89 @@
+package constructs_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type dotenvServer struct {
+	constructs.ConfigFileDotenv
+	Host  string
+	Hosts []string
+	HCLDatabase
+}
+
+func (*dotenvServer) Init() error { return nil }
+
+func (*dotenvServer) Usage(name string) string {
+	if name == "Host" {
+		return "server host"
+	}
+	return ""
+}
+
+func TestConfigFileDotenv(t *testing.T) {
+	name := filepath.Join(t.TempDir(), ".env")
+	data := `# Server config.
+export HOST="local host"
+HOSTS=a,b
+HCLDATABASE_USER=admin # user
+HCLDatabase_Retries=3
+`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &dotenvServer{}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	check := func(c *dotenvServer) bool {
+		return c.Host == "local host" && len(c.Hosts) == 2 && c.User == "admin" && c.Retries == 3
+	}
+	if !check(c) {
+		t.Fatalf("config not loaded: %+v", c)
+	}
+
+	saved, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(saved), "# server host\nHOST=\"local host\"\n") {
+		t.Errorf("unexpected saved config:\n%s", saved)
+	}
+	if strings.Contains(string(saved), "NAME=") || strings.Contains(string(saved), "SAVE=") {
+		t.Errorf("config file settings saved:\n%s", saved)
+	}
+
+	c = &dotenvServer{}
+	c.Name = name
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !check(c) {
+		t.Errorf("config not reloaded: %+v", c)
+	}
+}
+
+func TestDotenvWriteTo(t *testing.T) {
+	store := constructs.NewStoreDotenv(func(...string) []rune { return nil })
+	if err := store.Set("value", "key"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := store.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 || n != int64(buf.Len()) {
+		t.Errorf("got %d bytes written; expected %d", n, buf.Len())
+	}
+}
@@ -0,0 +1,67 @@
+package constructs
+
+import (
+	"encoding/gob"
+	"io"
+	"time"
+
+	"github.com/pierrec/construct"
+)
+
+func init() {
+	// Types of the nested values held by a gobStore.
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register(time.Duration(0))
+}
+
+var _ construct.Config = (*ConfigFileGob)(nil)
+
+// ConfigFileGob implements the FromIO interface for gob encoded files.
+//
+// The gob format is compact and fast to decode but not human readable:
+// it is meant for configs produced and consumed by programs, e.g. shipped
+// inside an orchestration payload. Comments are not supported.
+type ConfigFileGob struct {
+	ConfigFile `cfg:",inline"`
+}
+
+var _ construct.FromIO = (*ConfigFileGob)(nil)
+
+// New returns the Store for a gob encoded file.
+func (c *ConfigFileGob) New(lookup construct.LookupFn) construct.Store {
+	return NewStoreGob(lookup)
+}
+
+// NewStoreGob returns a Store based on the gob binary format.
+//
+// The config items values are encoded as they are stored in JSON,
+// without the conversion of numbers to floats when decoded.
+func NewStoreGob(lookup construct.LookupFn) construct.Store {
+	store := NewStoreJSON(lookup).(*jsonStore)
+	return &gobStore{store}
+}
+
+var _ construct.Store = (*gobStore)(nil)
+var _ construct.KeysStore = (*gobStore)(nil)
+
+// gobStore extends jsonStore with the gob encoding.
+type gobStore struct {
+	*jsonStore
+}
+
+func (store *gobStore) ReadFrom(r io.Reader) (int64, error) {
+	nr := &reader{Reader: r}
+	dec := gob.NewDecoder(nr)
+	err := dec.Decode(&store.data)
+	if err == io.EOF {
+		// Empty file.
+		err = nil
+	}
+	return nr.read(), err
+}
+
+func (store *gobStore) WriteTo(w io.Writer) (int64, error) {
+	enc := gob.NewEncoder(w)
+	return 0, enc.Encode(store.data)
+}
@@ -0,0 +1,60 @@
+package constructs_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type gobServer struct {
+	Host string
+	Port int
+}
+
+func (*gobServer) Init() error              { return nil }
+func (*gobServer) Usage(name string) string { return "" }
+
+type gobConfig struct {
+	constructs.ConfigFileGob
+	Port    int
+	Ratio   float64
+	Tags    []string
+	Limits  map[string]int
+	Timeout time.Duration
+	Server  gobServer
+}
+
+func (*gobConfig) Init() error              { return nil }
+func (*gobConfig) Usage(name string) string { return "" }
+
+func TestConfigFileGob(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.gob")
+	want := gobConfig{
+		Port:    8080,
+		Ratio:   0.5,
+		Tags:    []string{"a", "b"},
+		Limits:  map[string]int{"cpu": 2},
+		Timeout: time.Second,
+		Server:  gobServer{Host: "localhost", Port: 80},
+	}
+	c := want
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	got := gobConfig{}
+	got.Name = name
+	if err := construct.LoadArgs(&got, nil, construct.OptionStrictIO(nil)); err != nil {
+		t.Fatal(err)
+	}
+	got.ConfigFileGob = want.ConfigFileGob
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; expected %+v", got, want)
+	}
+}
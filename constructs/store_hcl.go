@@ -0,0 +1,299 @@
+package constructs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/parser"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/pierrec/construct"
+)
+
+var _ construct.Config = (*ConfigFileHCL)(nil)
+
+// ConfigFileHCL implements the FromIO interface for HCL (version 1) formatted files.
+//
+// Blocks, optionally labeled, map to groups of config items: name "label" { ... }
+//
+// Comments are written before their key when saving.
+type ConfigFileHCL struct {
+	ConfigFile `cfg:",inline"`
+}
+
+var _ construct.FromIO = (*ConfigFileHCL)(nil)
+
+// New returns the Store for a HCL formatted file.
+func (c *ConfigFileHCL) New(lookup construct.LookupFn) construct.Store {
+	return NewStoreHCL(lookup)
+}
+
+// NewStoreHCL returns a Store based on the HCL format.
+func NewStoreHCL(lookup construct.LookupFn) construct.Store {
+	store := NewStoreJSON(lookup).(*jsonStore)
+	return &hclStore{store, make(map[string]string)}
+}
+
+var _ construct.Store = (*hclStore)(nil)
+
+// hclStore extends jsonStore with the HCL syntax and comments.
+type hclStore struct {
+	*jsonStore
+	comments map[string]string
+}
+
+func (store *hclStore) StructTag() string { return "hcl" }
+
+// commentKey returns the key used for the comment of the given keys.
+func (store *hclStore) commentKey(keys []string) string {
+	return strings.Join(keys, "\x00")
+}
+
+func (store *hclStore) SetComment(comment string, keys ...string) error {
+	store.comments[store.commentKey(keys)] = comment
+	return nil
+}
+
+func (store *hclStore) ReadFrom(r io.Reader) (int64, error) {
+	src, err := ioutil.ReadAll(r)
+	n := int64(len(src))
+	if err != nil {
+		return n, err
+	}
+	f, err := parser.Parse(src)
+	if err != nil {
+		if perr, ok := err.(*parser.PosError); ok {
+			return n, hclErrorf(perr.Pos, "%v", perr.Err)
+		}
+		return n, fmt.Errorf("hcl: %v", err)
+	}
+	list, ok := f.Node.(*ast.ObjectList)
+	if !ok {
+		return n, hclErrorf(f.Node.Pos(), "unexpected node")
+	}
+	data, err := hclBody(list)
+	if err != nil {
+		return n, err
+	}
+	store.data = data
+	return n, nil
+}
+
+func (store *hclStore) WriteTo(w io.Writer) (int64, error) {
+	buf := bufio.NewWriter(w)
+	if c, ok := store.comments[store.commentKey([]string{"", ""})]; ok {
+		store.writeComment(buf, c, "")
+		buf.WriteByte('\n')
+	}
+	if err := store.write(buf, store.data, nil, ""); err != nil {
+		return 0, err
+	}
+	return 0, buf.Flush()
+}
+
+func (store *hclStore) writeComment(w *bufio.Writer, comment, indent string) {
+	for _, line := range strings.Split(comment, "\n") {
+		fmt.Fprintf(w, "%s# %s\n", indent, line)
+	}
+}
+
+// write serializes the body m with the comments of its keys:
+// attributes first, then blocks.
+func (store *hclStore) write(w *bufio.Writer, m map[string]interface{}, keys []string, indent string) error {
+	var attrs, blocks []string
+	for _, name := range store.order.keys(m, keys) {
+		if _, ok := m[name].(map[string]interface{}); ok {
+			blocks = append(blocks, name)
+		} else {
+			attrs = append(attrs, name)
+		}
+	}
+
+	for _, name := range attrs {
+		ks := append(keys[:len(keys):len(keys)], name)
+		if c, ok := store.comments[store.commentKey(ks)]; ok {
+			store.writeComment(w, c, indent)
+		}
+		fmt.Fprintf(w, "%s%s = ", indent, hclKey(name))
+		if err := hclWriteValue(w, m[name], indent); err != nil {
+			return err
+		}
+		w.WriteByte('\n')
+	}
+	for i, name := range blocks {
+		if i > 0 || len(attrs) > 0 {
+			w.WriteByte('\n')
+		}
+		ks := append(keys[:len(keys):len(keys)], name)
+		if c, ok := store.comments[store.commentKey(ks)]; ok {
+			store.writeComment(w, c, indent)
+		}
+		fmt.Fprintf(w, "%s%s {\n", indent, hclKey(name))
+		if err := store.write(w, m[name].(map[string]interface{}), ks, indent+"  "); err != nil {
+			return err
+		}
+		w.WriteString(indent + "}\n")
+	}
+	return nil
+}
+
+// hclKey returns the key as an identifier if possible, or a quoted string.
+func hclKey(key string) string {
+	for i := 0; i < len(key); i++ {
+		if c := key[i]; !isHCLIdent(c) || i == 0 && c >= '0' && c <= '9' {
+			return strconv.Quote(key)
+		}
+	}
+	if key == "" {
+		return `""`
+	}
+	return key
+}
+
+func hclWriteValue(w *bufio.Writer, v interface{}, indent string) error {
+	switch v := v.(type) {
+	case string:
+		w.WriteString(strconv.Quote(v))
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		w.WriteString("{")
+		for i, name := range names {
+			if i > 0 {
+				w.WriteString(",")
+			}
+			fmt.Fprintf(w, " %s = ", hclKey(name))
+			if err := hclWriteValue(w, v[name], indent); err != nil {
+				return err
+			}
+		}
+		w.WriteString(" }")
+	default:
+		// Lists of values are written on a single line.
+		if items, ok := toList(v); ok {
+			w.WriteString("[")
+			for i, item := range items {
+				if i > 0 {
+					w.WriteString(", ")
+				}
+				if err := hclWriteValue(w, item, indent); err != nil {
+					return err
+				}
+			}
+			w.WriteString("]")
+			return nil
+		}
+		bts, err := json.Marshal(formatFloats(v, jsonNumber))
+		if err != nil {
+			return err
+		}
+		w.Write(bts)
+	}
+	return nil
+}
+
+// toList returns the items of v if it is a list.
+func toList(v interface{}) ([]interface{}, bool) {
+	if items, ok := v.([]interface{}); ok {
+		return items, true
+	}
+	// Use the JSON encoding to convert typed slices.
+	bts, err := json.Marshal(v)
+	if err != nil || len(bts) == 0 || bts[0] != '[' {
+		return nil, false
+	}
+	var items []interface{}
+	if err := json.Unmarshal(bts, &items); err != nil {
+		return nil, false
+	}
+	return items, true
+}
+
+func isHCLIdent(c byte) bool {
+	return c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// hclBody converts the attributes and blocks of the HCL object list.
+func hclBody(list *ast.ObjectList) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for _, item := range list.Items {
+		keys := make([]string, len(item.Keys))
+		for i, k := range item.Keys {
+			keys[i], _ = k.Token.Value().(string)
+		}
+		v, err := hclValue(item.Val)
+		if err != nil {
+			return nil, err
+		}
+		if block, ok := v.(map[string]interface{}); ok {
+			// Block with optional labels.
+			hclMerge(m, keys, block)
+			continue
+		}
+		if len(keys) > 1 {
+			return nil, hclErrorf(item.Pos(), "unexpected labels for %s", keys[0])
+		}
+		m[keys[0]] = v
+	}
+	return m, nil
+}
+
+// hclMerge merges the block into m under keys.
+func hclMerge(m map[string]interface{}, keys []string, block map[string]interface{}) {
+	for _, key := range keys {
+		sub, ok := m[key].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[key] = sub
+		}
+		m = sub
+	}
+	for k, v := range block {
+		m[k] = v
+	}
+}
+
+// hclValue converts the HCL value.
+func hclValue(node ast.Node) (interface{}, error) {
+	switch n := node.(type) {
+	case *ast.LiteralType:
+		if n.Token.Type == token.NUMBER {
+			// Token.Value panics on integers overflowing int64.
+			if i, err := strconv.ParseInt(n.Token.Text, 0, 64); err == nil {
+				return i, nil
+			}
+			f, err := strconv.ParseFloat(n.Token.Text, 64)
+			if err != nil {
+				return nil, hclErrorf(n.Pos(), "invalid number %s", n.Token.Text)
+			}
+			return f, nil
+		}
+		return n.Token.Value(), nil
+	case *ast.ListType:
+		list := make([]interface{}, len(n.List))
+		for i, item := range n.List {
+			v, err := hclValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = v
+		}
+		return list, nil
+	case *ast.ObjectType:
+		return hclBody(n.List)
+	}
+	return nil, hclErrorf(node.Pos(), "unsupported value")
+}
+
+func hclErrorf(pos token.Pos, format string, args ...interface{}) error {
+	return fmt.Errorf("hcl: line %d: %s", pos.Line, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,119 @@
+package constructs_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type HCLDatabase struct {
+	User    string
+	Retries int
+}
+
+func (*HCLDatabase) Init() error { return nil }
+
+func (*HCLDatabase) Usage(name string) string { return "" }
+
+type hclServer struct {
+	constructs.ConfigFileHCL
+	Host  string
+	Port  int
+	Hosts []string
+	HCLDatabase
+}
+
+func (*hclServer) Init() error { return nil }
+
+func (*hclServer) Usage(name string) string {
+	switch name {
+	case "Port":
+		return "listening port"
+	}
+	return ""
+}
+
+func TestConfigFileHCL(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.hcl")
+	data := `# Server config.
+Host = "local\"host"
+Port = 8080 // port
+Hosts = [
+  "a",
+  "b", /* trailing comma */
+]
+
+HCLDatabase {
+  User    = "admin"
+  Retries = 3
+}
+`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &hclServer{}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	check := func(c *hclServer) bool {
+		return c.Host == `local"host` && c.Port == 8080 && len(c.Hosts) == 2 &&
+			c.User == "admin" && c.Retries == 3
+	}
+	if !check(c) {
+		t.Fatalf("config not loaded: %+v", c)
+	}
+
+	saved, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"# listening port\nPort = 8080\n",
+		"\nHCLDatabase {\n",
+		`  User = "admin"`,
+	} {
+		if !strings.Contains(string(saved), s) {
+			t.Errorf("missing %q in saved config:\n%s", s, saved)
+		}
+	}
+
+	// The saved config must load back.
+	c = &hclServer{}
+	c.Name = name
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !check(c) {
+		t.Errorf("config not reloaded: %+v", c)
+	}
+}
+
+func TestConfigFileHCLInvalid(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.hcl")
+	if err := os.WriteFile(name, []byte("Host = \"localhost\"\nPort 8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := &hclServer{}
+	c.Name = name
+	err := construct.LoadArgs(c, nil)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected a line 2 error, got %v", err)
+	}
+}
+
+func TestHCLBigNumber(t *testing.T) {
+	store := constructs.NewStoreHCL(nil)
+	if _, err := store.ReadFrom(strings.NewReader("Big = 1000000000000000000000\n")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := store.Get("Big"); err != nil || v != 1e21 {
+		t.Errorf("got %v (%v); expected 1e21", v, err)
+	}
+}
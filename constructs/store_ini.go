@@ -2,6 +2,7 @@ package constructs
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/pierrec/construct"
@@ -14,39 +15,93 @@ var _ construct.Config = (*ConfigFileINI)(nil)
 // ConfigFileINI implements the FromIO interface for INI formatted files.
 type ConfigFileINI struct {
 	ConfigFile `cfg:",inline"`
+	// Nesting of the config items in more than one group.
+	Nesting ININesting `cfg:"-"`
 }
 
 var _ construct.FromIO = (*ConfigFileINI)(nil)
 
 // New returns the Store for an INI formatted file.
 func (c *ConfigFileINI) New(lookup construct.LookupFn) construct.Store {
-	return NewStoreINI(lookup)
+	return NewStoreININesting(lookup, c.Nesting)
 }
 
+// ININesting defines how the config items nested in more than one group,
+// e.g. A.B.Key, are stored in INI files, which only have one level of sections.
+// Config items in a single group are always stored in the section of the group.
+type ININesting int
+
+const (
+	// INISections stores the config items in the section named after all their
+	// groups separated by dots, e.g. Key in the [A.B] section.
+	INISections ININesting = iota
+	// INIDottedKeys stores the config items in the section of their first group
+	// with their other groups prefixing their key, e.g. B.Key in the [A] section.
+	INIDottedKeys
+)
+
 // NewStoreINI returns a Store based on the INI format.
 func NewStoreINI(lookup construct.LookupFn) construct.Store {
+	return NewStoreININesting(lookup, INISections)
+}
+
+// NewStoreININesting returns a Store based on the INI format using the given
+// nesting of the config items.
+func NewStoreININesting(lookup construct.LookupFn, nesting ININesting) construct.Store {
 	v, _ := ini.New(ini.Comment("# "))
-	return &iniStore{lookup, v}
+	return &iniStore{lookup, v, nesting}
 }
 
 var _ construct.Store = (*iniStore)(nil)
+var _ construct.KeysStore = (*iniStore)(nil)
+var _ construct.CommentStore = (*iniStore)(nil)
 
 // iniStore wraps an ini.INI instance to implement the construct.ConfigIO interface.
 type iniStore struct {
 	lookup construct.LookupFn
 	*ini.INI
+	nesting ININesting
 }
 
 func (store *iniStore) StructTag() string { return "ini" }
 
+func (store *iniStore) Keys() [][]string {
+	var res [][]string
+	for _, key := range store.INI.Keys("") {
+		if key != "" {
+			res = append(res, []string{key})
+		}
+	}
+	for _, section := range store.INI.Sections() {
+		for _, key := range store.INI.Keys(section) {
+			if key == "" {
+				continue
+			}
+			switch store.nesting {
+			case INIDottedKeys:
+				res = append(res, append([]string{section}, strings.Split(key, ".")...))
+			default:
+				res = append(res, append(strings.Split(section, "."), key))
+			}
+		}
+	}
+	return res
+}
+
+// keys returns the section and key for the keys path according to the nesting.
 func (store *iniStore) keys(keys []string) (section, key string) {
-	switch len(keys) {
+	switch n := len(keys); n {
 	case 0:
 	case 1:
 		key = keys[0]
 	default:
-		section = keys[0]
-		key = keys[1]
+		if store.nesting == INIDottedKeys {
+			section = keys[0]
+			key = strings.Join(keys[1:], ".")
+		} else {
+			section = strings.Join(keys[:n-1], ".")
+			key = keys[n-1]
+		}
 	}
 	return
 }
@@ -73,7 +128,37 @@ func (store *iniStore) Set(v interface{}, keys ...string) error {
 
 func (store *iniStore) SetComment(comment string, keys ...string) error {
 	section, key := store.keys(keys)
-	comment = strings.Replace(comment, "\n", "\n# ", -1)
-	store.INI.SetComments(section, key, comment)
+	store.INI.SetComments(section, key, strings.Split(comment, "\n")...)
 	return nil
 }
+
+func (store *iniStore) Comment(keys ...string) string {
+	return strings.Join(store.INI.GetComments(store.keys(keys)), "\n")
+}
+
+func (store *iniStore) ReadFrom(r io.Reader) (int64, error) {
+	n, err := store.INI.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+	// Comments are read with the space following the comment character.
+	trim := func(section, key string) {
+		comments := store.INI.GetComments(section, key)
+		if len(comments) == 0 {
+			return
+		}
+		for i, c := range comments {
+			comments[i] = strings.TrimPrefix(c, " ")
+		}
+		store.INI.SetComments(section, key, comments...)
+	}
+	for _, section := range append([]string{""}, store.INI.Sections()...) {
+		trim(section, "")
+		for _, key := range store.INI.Keys(section) {
+			if key != "" {
+				trim(section, key)
+			}
+		}
+	}
+	return n, nil
+}
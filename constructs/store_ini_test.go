@@ -0,0 +1,75 @@
+package constructs_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type INIInner struct {
+	Key string
+}
+
+func (*INIInner) Init() error              { return nil }
+func (*INIInner) Usage(name string) string { return "" }
+
+type INIOuter struct {
+	Inner INIInner
+	Key   string
+}
+
+func (*INIOuter) Init() error              { return nil }
+func (*INIOuter) Usage(name string) string { return "" }
+
+type iniNested struct {
+	constructs.ConfigFileINI
+	Outer INIOuter
+}
+
+func (*iniNested) Init() error              { return nil }
+func (*iniNested) Usage(name string) string { return "" }
+
+func TestININesting(t *testing.T) {
+	for _, tc := range []struct {
+		nesting constructs.ININesting
+		want    []string
+	}{
+		{constructs.INISections, []string{"[Outer]\nKey = outer\n", "[Outer.Inner]\nKey = inner\n"}},
+		{constructs.INIDottedKeys, []string{"[Outer]\nInner.Key = inner\nKey       = outer\n"}},
+	} {
+		name := filepath.Join(t.TempDir(), "config.ini")
+		c := &iniNested{}
+		c.Outer.Key = "outer"
+		c.Outer.Inner.Key = "inner"
+		c.Name = name
+		c.ToSave = true
+		c.Nesting = tc.nesting
+		if err := construct.LoadArgs(c, nil); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%d: missing %q in:\n%s", tc.nesting, want, data)
+			}
+		}
+
+		c = &iniNested{}
+		c.Name = name
+		c.Nesting = tc.nesting
+		err = construct.LoadArgs(c, nil, construct.OptionStrictIO(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Outer.Key != "outer" || c.Outer.Inner.Key != "inner" {
+			t.Errorf("%d: got %q %q; expected outer inner", tc.nesting, c.Outer.Key, c.Outer.Inner.Key)
+		}
+	}
+}
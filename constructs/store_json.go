@@ -1,6 +1,7 @@
 package constructs
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
 	"time"
@@ -24,21 +25,27 @@ func (c *ConfigFileJSON) New(lookup construct.LookupFn) construct.Store {
 }
 
 // NewStoreJSON returns a Store based on the JSON format.
+//
+// Keys are written in the order they were first set, i.e. in struct fields order.
 func NewStoreJSON(lookup construct.LookupFn) construct.Store {
 	m := make(map[string]interface{})
-	return &jsonStore{lookup, m}
+	return &jsonStore{lookup, m, make(keyOrder)}
 }
 
 var _ construct.Store = (*jsonStore)(nil)
+var _ construct.KeysStore = (*jsonStore)(nil)
 
 // jsonStore wraps json instances to implement the construct.ConfigIO interface.
 type jsonStore struct {
 	lookup construct.LookupFn
 	data   map[string]interface{}
+	order  keyOrder
 }
 
 func (store *jsonStore) StructTag() string { return "json" }
 
+func (store *jsonStore) Keys() [][]string { return mapKeys(store.data, nil) }
+
 func (store *jsonStore) Has(keys ...string) bool {
 	if len(keys) == 0 {
 		return false
@@ -81,6 +88,7 @@ func (store *jsonStore) Set(v interface{}, keys ...string) error {
 	if len(keys) == 0 {
 		return nil
 	}
+	store.order.add(keys)
 	v, err := store.marshal(keys, v)
 	if err != nil || v == nil {
 		return err
@@ -134,7 +142,7 @@ func (store *jsonStore) ReadFrom(r io.Reader) (int64, error) {
 func (store *jsonStore) WriteTo(w io.Writer) (int64, error) {
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", " ")
-	if err := enc.Encode(store.data); err != nil {
+	if err := enc.Encode(store.order.json(store.data, nil)); err != nil {
 		return 0, err
 	}
 	return 0, nil
@@ -143,3 +151,52 @@ func (store *jsonStore) WriteTo(w io.Writer) (int64, error) {
 func (store *jsonStore) SetComment(comment string, keys ...string) error {
 	return nil
 }
+
+// json returns v with its maps replaced by jsonObjects encoding their keys in order.
+func (o keyOrder) json(v interface{}, keys []string) interface{} {
+	switch w := v.(type) {
+	case map[string]interface{}:
+		obj := jsonObject{keys: o.keys(w, keys)}
+		obj.values = make([]interface{}, len(obj.keys))
+		for i, k := range obj.keys {
+			obj.values[i] = o.json(w[k], append(keys[:len(keys):len(keys)], k))
+		}
+		return obj
+	case []interface{}:
+		l := make([]interface{}, len(w))
+		for i, v := range w {
+			l[i] = o.json(v, keys)
+		}
+		return l
+	}
+	return formatFloats(v, jsonNumber)
+}
+
+// jsonObject is a JSON object which keys are encoded in order.
+type jsonObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (obj jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range obj.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(obj.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
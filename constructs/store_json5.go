@@ -0,0 +1,198 @@
+package constructs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pierrec/construct"
+	"github.com/tailscale/hujson"
+)
+
+var _ construct.Config = (*ConfigFileJSON5)(nil)
+
+// ConfigFileJSON5 implements the FromIO interface for JSON files with comments
+// and trailing commas in objects and arrays, as supported by JSONC and JSON5.
+// Other JSON5 extensions, such as unquoted object keys, are not supported.
+//
+// Comments are written before their key when saving, and read from the file are kept.
+type ConfigFileJSON5 struct {
+	ConfigFile `cfg:",inline"`
+}
+
+var _ construct.FromIO = (*ConfigFileJSON5)(nil)
+
+// New returns the Store for a JSON5 formatted file.
+func (c *ConfigFileJSON5) New(lookup construct.LookupFn) construct.Store {
+	return NewStoreJSON5(lookup)
+}
+
+// NewStoreJSON5 returns a Store based on the JSON5 format.
+func NewStoreJSON5(lookup construct.LookupFn) construct.Store {
+	store := NewStoreJSON(lookup).(*jsonStore)
+	return &json5Store{store, make(map[string]string)}
+}
+
+var (
+	_ construct.Store        = (*json5Store)(nil)
+	_ construct.CommentStore = (*json5Store)(nil)
+)
+
+// json5Store extends jsonStore with comments.
+type json5Store struct {
+	*jsonStore
+	comments map[string]string
+}
+
+func (store *json5Store) SetComment(comment string, keys ...string) error {
+	store.comments[commentKey(keys)] = comment
+	return nil
+}
+
+func (store *json5Store) Comment(keys ...string) string {
+	return store.comments[commentKey(keys)]
+}
+
+func (store *json5Store) ReadFrom(r io.Reader) (int64, error) {
+	src, err := ioutil.ReadAll(r)
+	n := int64(len(src))
+	if err != nil {
+		return n, err
+	}
+	v, err := hujson.Parse(src)
+	if err != nil {
+		return n, fmt.Errorf("json5: %v", err)
+	}
+	if c := json5Comment(v.BeforeExtra, true); c != "" {
+		store.comments[globalComment] = c
+	}
+	store.readComments(v, nil)
+	v.Standardize()
+	if err := json.Unmarshal(v.Pack(), &store.data); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// readComments records the comments preceding the keys of the object v.
+// The objects in arrays are not addressable and their comments are ignored.
+func (store *json5Store) readComments(v hujson.Value, keys []string) {
+	obj, ok := v.Value.(*hujson.Object)
+	if !ok {
+		return
+	}
+	for _, m := range obj.Members {
+		name, ok := m.Name.Value.(hujson.Literal)
+		if !ok {
+			continue
+		}
+		ks := append(keys[:len(keys):len(keys)], name.String())
+		if c := json5Comment(m.Name.BeforeExtra, false); c != "" {
+			store.comments[commentKey(ks)] = c
+		}
+		store.readComments(m.Value, ks)
+	}
+}
+
+func (store *json5Store) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	buf := bufio.NewWriter(cw)
+	if c, ok := store.comments[globalComment]; ok {
+		store.writeComment(buf, c, "")
+		buf.WriteByte('\n')
+	}
+	if err := store.write(buf, store.data, nil, ""); err != nil {
+		return cw.n, err
+	}
+	buf.WriteByte('\n')
+	err := buf.Flush()
+	return cw.n, err
+}
+
+func (store *json5Store) writeComment(w *bufio.Writer, comment, indent string) {
+	for _, line := range strings.Split(comment, "\n") {
+		fmt.Fprintf(w, "%s// %s\n", indent, line)
+	}
+}
+
+// write serializes the object m with the comments of its keys.
+func (store *json5Store) write(w *bufio.Writer, m map[string]interface{}, keys []string, indent string) error {
+	names := store.order.keys(m, keys)
+
+	w.WriteString("{\n")
+	for i, name := range names {
+		ks := append(keys[:len(keys):len(keys)], name)
+		in := indent + " "
+		if c, ok := store.comments[commentKey(ks)]; ok {
+			store.writeComment(w, c, in)
+		}
+		key, _ := json.Marshal(name)
+		fmt.Fprintf(w, "%s%s: ", in, key)
+		if sub, ok := m[name].(map[string]interface{}); ok {
+			if err := store.write(w, sub, ks, in); err != nil {
+				return err
+			}
+		} else {
+			bts, err := json.Marshal(formatFloats(m[name], jsonNumber))
+			if err != nil {
+				return err
+			}
+			w.Write(bts)
+		}
+		if i < len(names)-1 {
+			w.WriteByte(',')
+		}
+		w.WriteByte('\n')
+	}
+	w.WriteString(indent + "}")
+	return nil
+}
+
+// json5Comment returns the text of the comments on their own lines in extra,
+// the comments and whitespace preceding a value.
+// The comments followed by an empty line are ignored unless global is set.
+func json5Comment(extra hujson.Extra, global bool) string {
+	var lines []string
+	// Whether or not the current line has no token, and no comment for empty, so far.
+	blank, empty := global, false
+	for b := []byte(extra); len(b) > 0; {
+		switch {
+		case bytes.HasPrefix(b, []byte("//")):
+			i := bytes.IndexByte(b, '\n')
+			if i < 0 {
+				i = len(b)
+			}
+			if blank {
+				text := strings.TrimRight(string(b[2:i]), " \t\r")
+				lines = append(lines, strings.TrimPrefix(text, " "))
+			}
+			empty = false
+			b = b[i:]
+		case bytes.HasPrefix(b, []byte("/*")):
+			// The comment is terminated as the extra has been parsed.
+			i := bytes.Index(b, []byte("*/"))
+			if blank {
+				for _, line := range strings.Split(string(b[2:i]), "\n") {
+					if line = strings.TrimSpace(line); line != "" {
+						lines = append(lines, line)
+					}
+				}
+			}
+			empty = false
+			b = b[i+2:]
+		case b[0] == '\n':
+			if empty && !global {
+				lines = nil
+			}
+			blank, empty = true, true
+			b = b[1:]
+		default:
+			b = b[1:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
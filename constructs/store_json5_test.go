@@ -0,0 +1,140 @@
+package constructs_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type json5Server struct {
+	constructs.ConfigFileJSON5
+	Host  string
+	Port  int
+	Hosts []string
+	Ratio float64
+	Mask  int
+	Nested
+}
+
+type Nested struct {
+	Level float64
+}
+
+func (*Nested) Init() error              { return nil }
+func (*Nested) Usage(name string) string { return "" }
+
+func (*json5Server) Init() error { return nil }
+
+func (*json5Server) Usage(name string) string {
+	switch name {
+	case "Port":
+		return "listening port"
+	}
+	return ""
+}
+
+func TestConfigFileJSON5(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json5")
+	data := `// Server config.
+{
+	/* host to connect to */
+	"Host": "local\"host",
+	"Port": 8080, // port
+	"Hosts": ["a", "b",],
+	"Ratio": 1.5e2,
+
+	// dropped as followed by an empty line
+
+	"Mask": 31,
+	"Nested": {
+		// inner value
+		"Level": -0.5,
+	},
+}
+`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &json5Server{}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != `local"host` || c.Port != 8080 || len(c.Hosts) != 2 ||
+		c.Ratio != 150 || c.Mask != 31 || c.Level != -0.5 {
+		t.Fatalf("config not loaded: %+v", c)
+	}
+
+	saved, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"// Server config.\n\n{",
+		"// host to connect to\n \"Host\"",
+		"// listening port\n \"Port\": 8080",
+		"  // inner value\n  \"Level\": -0.5",
+	} {
+		if !strings.Contains(string(saved), want) {
+			t.Errorf("missing %q in saved config:\n%s", want, saved)
+		}
+	}
+	for _, notwant := range []string{"// port", "dropped"} {
+		if strings.Contains(string(saved), notwant) {
+			t.Errorf("unexpected %q in saved config:\n%s", notwant, saved)
+		}
+	}
+
+	// The saved config must load back.
+	c = &json5Server{}
+	c.Name = name
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != `local"host` || c.Port != 8080 || len(c.Hosts) != 2 {
+		t.Errorf("config not reloaded: %+v", c)
+	}
+}
+
+func TestJSON5Unsupported(t *testing.T) {
+	for _, data := range []string{
+		`{x: 1}`,
+		`{"x": 'a'}`,
+		`{"x": 0x10}`,
+		`{"x": .5}`,
+	} {
+		store := constructs.NewStoreJSON5(nil)
+		if _, err := store.ReadFrom(strings.NewReader(data)); err == nil {
+			t.Errorf("%s: expected an error", data)
+		}
+	}
+}
+
+func TestJSON5WriteTo(t *testing.T) {
+	store := constructs.NewStoreJSON5(nil)
+	data := `{"x": 1e5, "y": -16, "v": 1E-2,}`
+	if _, err := store.ReadFrom(strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]float64{"x": 1e5, "y": -16, "v": 0.01} {
+		if v, err := store.Get(key); err != nil || v != want {
+			t.Errorf("%s: got %v (%v); expected %v", key, v, err, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := store.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("got %d bytes written; expected %d", n, buf.Len())
+	}
+}
@@ -0,0 +1,119 @@
+package constructs_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type jsonSizes struct {
+	constructs.ConfigFileJSON
+	Timeout time.Duration
+	Size    constructs.BytesSize
+	Retries []time.Duration
+	Limit   *time.Duration
+	Delays  map[string]time.Duration
+}
+
+func (*jsonSizes) Init() error              { return nil }
+func (*jsonSizes) Usage(name string) string { return "" }
+
+func TestJSONHumanValues(t *testing.T) {
+	dir := t.TempDir()
+	snap := filepath.Join(dir, "snapshot.json")
+	limit := time.Minute
+	c := &jsonSizes{
+		Timeout: 10 * time.Second,
+		Size:    10e6,
+		Retries: []time.Duration{time.Second, 2 * time.Second},
+		Limit:   &limit,
+		Delays:  map[string]time.Duration{"a": time.Millisecond},
+	}
+	if err := construct.LoadArgs(c, nil, construct.OptionSnapshot(snap, "json")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"Timeout": "10s",
+		"Size":    "10 MB",
+		"Retries": []interface{}{"1s", "2s"},
+		"Limit":   "1m0s",
+		"Delays":  map[string]interface{}{"a": "1ms"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; expected %v", got, want)
+	}
+
+	// Read back the human readable values.
+	c = &jsonSizes{}
+	c.Name = snap
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Timeout != 10*time.Second || c.Size != 10e6 || c.Limit == nil || *c.Limit != limit ||
+		!reflect.DeepEqual(c.Retries, []time.Duration{time.Second, 2 * time.Second}) ||
+		!reflect.DeepEqual(c.Delays, map[string]time.Duration{"a": time.Millisecond}) {
+		t.Errorf("got %+v; expected the snapshot values", c)
+	}
+
+	// The saved and sampled durations are left unchanged.
+	var saved, sample bytes.Buffer
+	if err := construct.SaveTo(c, constructs.NewStoreJSON, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if err := construct.Sample(c, "json", &sample); err != nil {
+		t.Fatal(err)
+	}
+	for _, buf := range []*bytes.Buffer{&saved, &sample} {
+		got = nil
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := got["Timeout"], float64(10*time.Second); got != want {
+			t.Errorf("got %v; expected %v", got, want)
+		}
+	}
+}
+
+type jsonOrder struct {
+	Zeta  string
+	Alpha int
+	Mid   map[string]int
+}
+
+func (*jsonOrder) Init() error              { return nil }
+func (*jsonOrder) Usage(name string) string { return "" }
+
+func TestJSONOrder(t *testing.T) {
+	c := &jsonOrder{Zeta: "z", Alpha: 1, Mid: map[string]int{"b": 2, "a": 1}}
+	var buf bytes.Buffer
+	if err := construct.SaveTo(c, constructs.NewStoreJSON, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+ "Zeta": "z",
+ "Alpha": 1,
+ "Mid": {
+  "a": 1,
+  "b": 2
+ }
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+package constructs
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pierrec/construct"
+)
+
+var _ construct.FromIO = (*MemoryStore)(nil)
+var _ construct.Store = (*MemoryStore)(nil)
+var _ construct.KeysStore = (*MemoryStore)(nil)
+
+// MemoryStore implements the FromIO and Store interfaces with the config items
+// held in memory, typically to test the loading of a config without files.
+// It is used by embedding it in the config with the "-" key or with FromIOMulti.
+//
+// The config items are saved back to it once loaded, as they would be to a file.
+type MemoryStore struct {
+	*jsonStore
+}
+
+// NewMemoryStore returns a MemoryStore holding the given values, which are
+// nested maps of config items values by name, e.g.
+//  map[string]interface{}{"Port": 8080, "DB": map[string]interface{}{"Host": "localhost"}}
+func NewMemoryStore(values map[string]interface{}) *MemoryStore {
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+	return &MemoryStore{&jsonStore{data: values, order: make(keyOrder)}}
+}
+
+// Values returns the values held by the store.
+func (store *MemoryStore) Values() map[string]interface{} {
+	return store.data
+}
+
+// Load makes MemoryStore implement FromIO.
+func (store *MemoryStore) Load() (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader("")), nil
+}
+
+// Save makes MemoryStore implement FromIO.
+func (store *MemoryStore) Save() (io.WriteCloser, error) {
+	return &nopCloser{ioutil.Discard}, nil
+}
+
+// New makes MemoryStore implement FromIO. It returns the store itself.
+func (store *MemoryStore) New(lookup construct.LookupFn) construct.Store {
+	store.lookup = lookup
+	return store
+}
+
+// ReadFrom does nothing as the values are already held by the store.
+func (store *MemoryStore) ReadFrom(r io.Reader) (int64, error) {
+	return 0, nil
+}
+
+// WriteTo does nothing as the values are already held by the store.
+func (store *MemoryStore) WriteTo(w io.Writer) (int64, error) {
+	return 0, nil
+}
+
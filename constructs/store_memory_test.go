@@ -0,0 +1,59 @@
+package constructs_test
+
+import (
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type memConfig struct {
+	*constructs.MemoryStore `cfg:"-"`
+	Host                    string
+	Port                    int
+	User                    string
+}
+
+func (*memConfig) Init() error                                  { return nil }
+func (*memConfig) Usage(name string) string                     { return "" }
+func (*memConfig) FlagsDone([]construct.Config, []string) error { return nil }
+func (*memConfig) FlagsShort(string) string                     { return "" }
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name string
+		args []string
+		env  map[string]string
+		want memConfig
+	}{
+		{"store", nil, nil, memConfig{Host: "db", Port: 5432, User: "root"}},
+		{"env", nil, map[string]string{"APP_PORT": "6543"}, memConfig{Host: "db", Port: 6543, User: "root"}},
+		{"flags", []string{"--port", "7654"}, map[string]string{"APP_PORT": "6543", "APP_USER": "admin"},
+			memConfig{Host: "db", Port: 7654, User: "admin"}},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			store := constructs.NewMemoryStore(map[string]interface{}{
+				"Host": "db",
+				"Port": 5432,
+				"User": "root",
+			})
+			c := &memConfig{MemoryStore: store}
+			err := construct.LoadArgs(c, tc.args,
+				construct.OptionEnvPrefix("APP"),
+				construct.OptionEnv(tc.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Host != tc.want.Host || c.Port != tc.want.Port || c.User != tc.want.User {
+				t.Errorf("got %s %d %s; expected %s %d %s",
+					c.Host, c.Port, c.User, tc.want.Host, tc.want.Port, tc.want.User)
+			}
+			if got := store.Values()["Port"]; got != tc.want.Port {
+				t.Errorf("saved Port %v; expected %d", got, tc.want.Port)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package constructs_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+)
+
+type floatConfig struct {
+	Big    float64
+	Round  float64
+	Small  float32
+	Floats []float64
+}
+
+func (*floatConfig) Init() error              { return nil }
+func (*floatConfig) Usage(name string) string { return "" }
+
+func TestStoreFloats(t *testing.T) {
+	for _, format := range []string{"cue", "hcl", "ini", "json", "json5", "toml", "yaml"} {
+		c := &floatConfig{1e21, 1e6, 0.1, []float64{1234567.5, 0.3}}
+		newStore := func(lookup construct.LookupFn) construct.Store {
+			store, _ := construct.NewStore(format, lookup)
+			return store
+		}
+		var buf bytes.Buffer
+		if err := construct.SaveTo(c, newStore, &buf); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		out := buf.String()
+		if strings.Contains(out, "e+") || strings.Contains(out, "0.1000") || strings.Contains(out, "construct-float") {
+			t.Errorf("%s: unexpected float formatting:\n%s", format, out)
+		}
+		for _, want := range []string{"1000000000000000000000", "1000000", "0.1", "1234567.5", "0.3"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: %s not found in:\n%s", format, want, out)
+			}
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package constructs
 
 import (
+	"fmt"
 	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	toml "github.com/pelletier/go-toml"
 	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/internal/structs"
 )
 
 var _ construct.Config = (*ConfigFileTOML)(nil)
@@ -23,21 +29,42 @@ func (c *ConfigFileTOML) New(lookup construct.LookupFn) construct.Store {
 }
 
 // NewStoreTOML returns a Store based on the TOML format.
+//
+// Struct fields tagged with the inline option, e.g. `toml:",inline"`, are
+// written as inline tables instead of sections.
+//
+// Comments are written before the keys and tables they belong to, the global
+// one at the top of the file. Comments read from the file are kept.
 func NewStoreTOML(lookup construct.LookupFn) construct.Store {
 	v, _ := toml.Load("")
-	return &tomlStore{lookup, v}
+	return &tomlStore{
+		lookup:   lookup,
+		toml:     v,
+		inline:   make(map[string]bool),
+		comments: make(map[string]string),
+	}
 }
 
-var _ construct.Store = (*tomlStore)(nil)
+var (
+	_ construct.Store        = (*tomlStore)(nil)
+	_ construct.TagStore     = (*tomlStore)(nil)
+	_ construct.KeysStore    = (*tomlStore)(nil)
+	_ construct.CommentStore = (*tomlStore)(nil)
+)
 
 // tomlStore wraps an toml.Toml instance to implement the construct.ConfigIO interface.
 type tomlStore struct {
-	lookup construct.LookupFn
-	toml   *toml.Tree
+	lookup   construct.LookupFn
+	toml     *toml.Tree
+	inline   map[string]bool   // Keys of the inline tables.
+	tables   []string          // Inline tables replacing their placeholder when writing.
+	comments map[string]string // Comments by key.
 }
 
 func (store *tomlStore) StructTag() string { return "toml" }
 
+func (store *tomlStore) Keys() [][]string { return mapKeys(store.toml.ToMap(), nil) }
+
 func (store *tomlStore) Has(keys ...string) bool {
 	return store.toml.HasPath(keys)
 }
@@ -54,21 +81,45 @@ func (store *tomlStore) Get(keys ...string) (interface{}, error) {
 			l[i] = t.ToMap()
 		}
 		return l, nil
+	case []interface{}:
+		// Keep datetime arrays native.
+		l := make([]time.Time, len(w))
+		for i, item := range w {
+			t, ok := item.(time.Time)
+			if !ok {
+				return v, nil
+			}
+			l[i] = t
+		}
+		return l, nil
 	}
 	return v, nil
 }
 
+func (store *tomlStore) SetTag(tag reflect.StructTag, keys ...string) {
+	opts := strings.Split(tag.Get("toml"), ",")
+	for _, opt := range opts[1:] {
+		if opt == "inline" {
+			store.inline[strings.Join(keys, ".")] = true
+			return
+		}
+	}
+}
+
 // TOML supported types:
 // string, int, bool, float, datetime, array, table
 //
 // Strategy for marshaling:
-//  - leave string, int64, bool, float64, time.Time unchanged
+//  - leave string, int64, bool, float64, time.Time, []time.Time unchanged
 //  - int, int8, int16, int32 -> int64
 //  - uint, uint8, uint16, uint32 -> int64
-//  - float32 -> float64
+//  - float32 -> float64 with the float32 precision
 //  - time.Duration -> string
 //  - any map -> string
 //  - any slice -> slice of marshaled items
+//  - any struct -> table or inline table
+//
+// Datetimes are written with a precision of one second.
 func (store *tomlStore) marshal(keys []string, v interface{}) (interface{}, error) {
 	switch w := v.(type) {
 	case toml.Marshaler:
@@ -77,7 +128,7 @@ func (store *tomlStore) marshal(keys []string, v interface{}) (interface{}, erro
 			return nil, err
 		}
 		return string(bts), nil
-	case int64, float64, string, bool, time.Time:
+	case int64, float64, string, bool, time.Time, []time.Time:
 	case int:
 		v = int64(w)
 	case int8:
@@ -97,14 +148,92 @@ func (store *tomlStore) marshal(keys []string, v interface{}) (interface{}, erro
 	case uint64:
 		v = int64(w)
 	case float32:
-		v = float64(w)
+		// Keep the float32 precision when formatted.
+		v, _ = strconv.ParseFloat(structs.FormatFloat(float64(w), 32), 64)
 	default:
+		if value := reflect.ValueOf(v); isTable(value) {
+			if !store.inline[strings.Join(keys, ".")] {
+				return nil, store.setTable(keys, value)
+			}
+			table, err := store.inlineTable(keys, value)
+			if err != nil {
+				return nil, err
+			}
+			// The placeholder is replaced by the table when writing.
+			store.tables = append(store.tables, table)
+			return tomlInlinePlaceholder(len(store.tables) - 1), nil
+		}
 		seps := store.lookup(keys...)
 		return marshal(store, store.marshal, keys, v, seps)
 	}
 	return v, nil
 }
 
+// isTable returns whether or not the value is serialized as a table.
+func isTable(v reflect.Value) bool {
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	return !isMarshaler(v.Interface())
+}
+
+// setTable sets the exported fields of the struct v as a table.
+func (store *tomlStore) setTable(keys []string, v reflect.Value) error {
+	for i, n := 0, v.NumField(); i < n; i++ {
+		f := v.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		ks := append(keys[:len(keys):len(keys)], f.Name)
+		if err := store.Set(v.Field(i).Interface(), ks...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inlineTable returns the struct v as an inline table.
+func (store *tomlStore) inlineTable(keys []string, v reflect.Value) (string, error) {
+	var items []string
+	for i, n := 0, v.NumField(); i < n; i++ {
+		f := v.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		ks := append(keys[:len(keys):len(keys)], f.Name)
+		var item string
+		if fv := v.Field(i); isTable(fv) {
+			table, err := store.inlineTable(ks, fv)
+			if err != nil {
+				return "", err
+			}
+			item = table
+		} else {
+			w, err := store.marshal(ks, fv.Interface())
+			if err != nil {
+				return "", err
+			}
+			if w == nil {
+				return "", fmt.Errorf("%s: unsupported type in inline table: %v", f.Name, f.Type)
+			}
+			// Use the TOML encoder for the value representation.
+			t, _ := toml.Load("")
+			t.Set("v", w)
+			s, err := t.ToTomlString()
+			if err != nil {
+				return "", err
+			}
+			item = strings.TrimSpace(strings.TrimPrefix(s, "v = "))
+		}
+		items = append(items, f.Name+" = "+item)
+	}
+	return "{" + strings.Join(items, ", ") + "}", nil
+}
+
+func tomlInlinePlaceholder(i int) string {
+	return fmt.Sprintf("construct-inline-table-%d", i)
+}
+
 func (store *tomlStore) Set(v interface{}, keys ...string) error {
 	v, err := store.marshal(keys, v)
 	if err != nil || v == nil {
@@ -115,18 +244,124 @@ func (store *tomlStore) Set(v interface{}, keys ...string) error {
 }
 
 func (store *tomlStore) ReadFrom(r io.Reader) (int64, error) {
-	nr := &reader{Reader: r}
-	t, err := toml.LoadReader(nr)
-	if err == nil {
-		store.toml = t
+	src, err := ioutil.ReadAll(r)
+	n := int64(len(src))
+	if err != nil {
+		return n, err
 	}
-	return nr.read(), err
+	t, err := toml.LoadBytes(src)
+	if err != nil {
+		return n, err
+	}
+	store.toml = t
+	readComments(store.comments, string(src), tomlKeys())
+	return n, nil
 }
 
 func (store *tomlStore) WriteTo(w io.Writer) (int64, error) {
-	return store.toml.WriteTo(w)
+	restore := tomlFloats(store.toml)
+	s, err := store.toml.ToTomlString()
+	restore()
+	if err != nil {
+		return 0, err
+	}
+	s = replaceFloats(s)
+	for i, table := range store.tables {
+		s = strings.Replace(s, `"`+tomlInlinePlaceholder(i)+`"`, table, 1)
+	}
+	if len(store.comments) > 0 {
+		s = writeComments(store.comments, s, tomlKeys())
+	}
+	n, err := io.WriteString(w, s)
+	return int64(n), err
+}
+
+// tomlFloats replaces the floats of t with placeholders, as the TOML encoder
+// formats them with a reduced precision, and returns the function restoring them.
+func tomlFloats(t *toml.Tree) func() {
+	var restore []func()
+	var walk func(*toml.Tree)
+	walk = func(t *toml.Tree) {
+		for _, k := range t.Keys() {
+			key := []string{k}
+			switch v := t.GetPath(key).(type) {
+			case *toml.Tree:
+				walk(v)
+			case []*toml.Tree:
+				for _, t := range v {
+					walk(t)
+				}
+			default:
+				found := false
+				w := formatFloats(v, func(s string) interface{} {
+					found = true
+					if !strings.ContainsRune(s, '.') {
+						// Integral floats require a decimal point.
+						s += ".0"
+					}
+					return floatPlaceholder + s
+				})
+				if found {
+					t.SetPath(key, w)
+					restore = append(restore, func() { t.SetPath(key, v) })
+				}
+			}
+		}
+	}
+	walk(t)
+	return func() {
+		for _, f := range restore {
+			f()
+		}
+	}
+}
+
+// tomlKeys returns the function returning the keys of the key or table
+// defined by a line of a TOML document, which are processed in order.
+func tomlKeys() func(line string) []string {
+	var table []string
+	return func(line string) []string {
+		var keys []string
+		keys, table = tomlLineKeys(strings.TrimSpace(line), table)
+		return keys
+	}
+}
+
+// tomlLineKeys returns the keys of the key or table defined by the trimmed
+// line, if any, given the current table, and the new current table.
+func tomlLineKeys(line string, table []string) (keys, current []string) {
+	switch {
+	case line == "", line[0] == '#':
+		return nil, table
+	case strings.HasPrefix(line, "[["):
+		keys = tomlSplitKey(strings.TrimSuffix(strings.TrimPrefix(line, "[["), "]]"))
+		return keys, keys
+	case line[0] == '[':
+		keys = tomlSplitKey(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"))
+		return keys, keys
+	}
+	i := strings.IndexByte(line, '=')
+	if i <= 0 {
+		return nil, table
+	}
+	keys = append(table[:len(table):len(table)], tomlSplitKey(line[:i])...)
+	return keys, table
+}
+
+// tomlSplitKey splits the dotted key into its unquoted parts.
+func tomlSplitKey(key string) []string {
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return parts
 }
 
 func (store *tomlStore) SetComment(comment string, keys ...string) error {
+	store.comments[commentKey(keys)] = comment
 	return nil
 }
+
+func (store *tomlStore) Comment(keys ...string) string {
+	return store.comments[commentKey(keys)]
+}
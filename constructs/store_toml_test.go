@@ -0,0 +1,189 @@
+package constructs_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type tomlDates struct {
+	constructs.ConfigFileTOML
+	Dates []time.Time
+}
+
+func (*tomlDates) Init() error              { return nil }
+func (*tomlDates) Usage(name string) string { return "" }
+
+func TestTOMLTimeSlice(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.toml")
+	dates := []time.Time{
+		time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC),
+	}
+
+	c := &tomlDates{Dates: dates}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); !strings.Contains(got, "2020-01-02T03:04:05Z") || strings.Contains(got, `"`) {
+		t.Fatalf("dates not saved as a native TOML datetime array:\n%s", got)
+	}
+
+	c = &tomlDates{}
+	c.Name = name
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Dates, dates) {
+		t.Errorf("got %v; expected %v", c.Dates, dates)
+	}
+
+	// Native datetime arrays with offsets.
+	data = []byte("Dates = [1979-05-27T07:32:00Z, 1979-05-27T00:32:00-07:00]\n")
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c = &tomlDates{}
+	c.Name = name
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Dates) != 2 || !c.Dates[0].Equal(c.Dates[1]) {
+		t.Errorf("unexpected dates %v", c.Dates)
+	}
+}
+
+type tomlEndpoint struct {
+	Host string
+	Port int
+}
+
+type tomlTables struct {
+	constructs.ConfigFileTOML
+	Endpoint tomlEndpoint `toml:",inline"`
+	Backend  tomlEndpoint
+}
+
+func (*tomlTables) Init() error              { return nil }
+func (*tomlTables) Usage(name string) string { return "" }
+
+func TestTOMLInlineTable(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.toml")
+	c := &tomlTables{
+		Endpoint: tomlEndpoint{"x", 1},
+		Backend:  tomlEndpoint{"y", 2},
+	}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); !strings.Contains(got, `Endpoint = {Host = "x", Port = 1}`) ||
+		!strings.Contains(got, "[Backend]") {
+		t.Fatalf("unexpected tables:\n%s", got)
+	}
+
+	c = &tomlTables{}
+	c.Name = name
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Endpoint != (tomlEndpoint{"x", 1}) || c.Backend != (tomlEndpoint{"y", 2}) {
+		t.Errorf("got %+v %+v", c.Endpoint, c.Backend)
+	}
+}
+
+type TOMLDB struct {
+	Host string
+}
+
+func (*TOMLDB) Init() error { return nil }
+func (*TOMLDB) Usage(name string) string {
+	if name == "Host" {
+		return "database host"
+	}
+	return ""
+}
+
+type tomlComments struct {
+	constructs.ConfigFileTOML
+	Port   int
+	Debug  bool
+	TOMLDB `cfg:"DB"`
+}
+
+func (*tomlComments) Init() error { return nil }
+func (*tomlComments) Usage(name string) string {
+	switch name {
+	case "":
+		return "server config"
+	case "Port":
+		return "listening port"
+	}
+	return ""
+}
+
+func TestTOMLComments(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.toml")
+	c := &tomlComments{Port: 80}
+	c.Host = "localhost"
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# server config
+
+Debug = false
+# listening port
+Port = 80
+
+[DB]
+  # database host
+  Host = "localhost"
+`
+	if got := string(data); got != want {
+		t.Fatalf("got:\n%s\nexpected:\n%s", got, want)
+	}
+
+	// Hand written comments are preserved for the config items without usage.
+	data = []byte(strings.Replace(string(data), "# listening port", "# public port", 1))
+	data = []byte(strings.Replace(string(data), "Debug =", "# debug mode\nDebug =", 1))
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c = &tomlComments{}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = strings.Replace(want, "Debug =", "# debug mode\nDebug =", 1)
+	if string(got) != want {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, want)
+	}
+}
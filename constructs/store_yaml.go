@@ -2,7 +2,9 @@ package constructs
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/pierrec/construct"
@@ -25,21 +27,32 @@ func (c *ConfigFileYAML) New(lookup construct.LookupFn) construct.Store {
 }
 
 // NewStoreYAML returns a Store based on the YAML format.
+//
+// Keys are written in the order they were first set, i.e. in struct fields order.
+//
+// Comments are written before the keys they belong to, the global one at the
+// top of the file. Comments read from the file are kept.
 func NewStoreYAML(lookup construct.LookupFn) construct.Store {
 	m := make(map[string]interface{})
-	return &yamlStore{lookup, m}
+	return &yamlStore{lookup, m, make(keyOrder), make(map[string]string)}
 }
 
 var _ construct.Store = (*yamlStore)(nil)
+var _ construct.KeysStore = (*yamlStore)(nil)
+var _ construct.CommentStore = (*yamlStore)(nil)
 
 // yamlStore wraps json instances to implement the construct.ConfigIO interface.
 type yamlStore struct {
-	lookup construct.LookupFn
-	data   map[string]interface{}
+	lookup   construct.LookupFn
+	data     map[string]interface{}
+	order    keyOrder
+	comments map[string]string // Comments by key.
 }
 
 func (store *yamlStore) StructTag() string { return "json" }
 
+func (store *yamlStore) Keys() [][]string { return mapKeys(store.data, nil) }
+
 func (store *yamlStore) Has(keys ...string) bool {
 	if len(keys) == 0 {
 		return false
@@ -82,6 +95,7 @@ func (store *yamlStore) Set(v interface{}, keys ...string) error {
 	if len(keys) == 0 {
 		return nil
 	}
+	store.order.add(keys)
 	v, err := store.marshal(keys, v)
 	if err != nil || v == nil {
 		return err
@@ -131,18 +145,153 @@ func (store *yamlStore) ReadFrom(r io.Reader) (n int64, err error) {
 	if err != nil {
 		return
 	}
+	for k, v := range store.data {
+		store.data[k] = yamlNormalize(v)
+	}
+	readComments(store.comments, buf.String(), yamlKeys())
 	return
 }
 
+// yamlNormalize converts the maps decoded by the yaml package into
+// map[string]interface{} so that nested keys can be looked up.
+// Scalars are left as is, quoted or not, since they are converted
+// to the type of the field they are assigned to.
+func yamlNormalize(v interface{}) interface{} {
+	switch w := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(w))
+		for k, v := range w {
+			m[fmt.Sprintf("%v", k)] = yamlNormalize(v)
+		}
+		return m
+	case []interface{}:
+		for i, v := range w {
+			w[i] = yamlNormalize(v)
+		}
+	}
+	return v
+}
+
 func (store *yamlStore) WriteTo(w io.Writer) (int64, error) {
-	bts, err := yaml.Marshal(store.data)
+	bts, err := yaml.Marshal(store.order.yaml(store.data, nil))
 	if err != nil {
 		return 0, err
 	}
-	r := bytes.NewReader(bts)
-	return io.Copy(w, r)
+	s := replaceFloats(string(bts))
+	if len(store.comments) > 0 {
+		s = writeComments(store.comments, s, yamlKeys())
+	}
+	n, err := io.WriteString(w, s)
+	return int64(n), err
 }
 
 func (store *yamlStore) SetComment(comment string, keys ...string) error {
+	store.comments[commentKey(keys)] = comment
 	return nil
 }
+
+func (store *yamlStore) Comment(keys ...string) string {
+	return store.comments[commentKey(keys)]
+}
+
+// yamlKeys returns the function returning the keys of the mapping key defined
+// by a line of a block style YAML document, which are processed in order.
+// Keys within sequences and block scalars are ignored.
+func yamlKeys() func(line string) []string {
+	type level struct {
+		indent int
+		key    string
+	}
+	var stack []level
+	skip := -1 // Indentation of the sequence or block scalar being skipped.
+	return func(line string) []string {
+		content := strings.TrimSpace(line)
+		if content == "" || content[0] == '#' {
+			return nil
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if skip >= 0 {
+			if indent > skip || indent == skip && content[0] == '-' {
+				return nil
+			}
+			skip = -1
+		}
+		if content[0] == '-' {
+			skip = indent
+			return nil
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		key, value, ok := yamlSplitKey(content)
+		if !ok {
+			return nil
+		}
+		stack = append(stack, level{indent, key})
+		if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			skip = indent
+		}
+		keys := make([]string, len(stack))
+		for i, l := range stack {
+			keys[i] = l.key
+		}
+		return keys
+	}
+}
+
+// yamlSplitKey returns the unquoted key and the value of the mapping entry s.
+func yamlSplitKey(s string) (key, value string, ok bool) {
+	if q := s[0]; q == '"' || q == '\'' {
+		i := 1
+		for ; i < len(s); i++ {
+			if s[i] == '\\' && q == '"' {
+				i++
+			} else if s[i] == q {
+				if q == '\'' && i+1 < len(s) && s[i+1] == q {
+					i++
+					continue
+				}
+				break
+			}
+		}
+		if i >= len(s) || !strings.HasPrefix(s[i+1:], ":") {
+			return "", "", false
+		}
+		if err := yaml.Unmarshal([]byte(s[:i+1]), &key); err != nil {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(s[i+2:]), true
+	}
+	if strings.HasSuffix(s, ":") {
+		return s[:len(s)-1], "", true
+	}
+	i := strings.Index(s, ": ")
+	if i < 0 {
+		return "", "", false
+	}
+	return s[:i], strings.TrimSpace(s[i+2:]), true
+}
+
+// yaml returns v with its maps replaced by yaml.MapSlices holding their keys in order.
+func (o keyOrder) yaml(v interface{}, keys []string) interface{} {
+	switch w := v.(type) {
+	case map[string]interface{}:
+		ks := o.keys(w, keys)
+		m := make(yaml.MapSlice, len(ks))
+		for i, k := range ks {
+			m[i] = yaml.MapItem{Key: k, Value: o.yaml(w[k], append(keys[:len(keys):len(keys)], k))}
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(w))
+		for i, v := range w {
+			l[i] = o.yaml(v, keys)
+		}
+		return l
+	}
+	return formatFloats(v, yamlFloat)
+}
+
+// yamlFloat returns the placeholder for the formatted float s, as the
+// YAML encoder formats floats with an exponent.
+func yamlFloat(s string) interface{} { return floatPlaceholder + s }
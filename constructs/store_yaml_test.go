@@ -0,0 +1,181 @@
+package constructs_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type YAMLClient struct {
+	Timeout time.Duration
+	Retries uint
+}
+
+func (*YAMLClient) Init() error              { return nil }
+func (*YAMLClient) Usage(name string) string { return "" }
+
+type yamlServer struct {
+	constructs.ConfigFileYAML
+	YAMLClient `cfg:"Client"`
+	Port       int
+	Debug      bool
+	Ratio      float64
+	Ports      []int
+}
+
+func (*yamlServer) Init() error              { return nil }
+func (*yamlServer) Usage(name string) string { return "" }
+
+func TestYAMLQuotedScalars(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.yaml")
+	data := `Port: "8080"
+Debug: "true"
+Ratio: "0.5"
+Ports: ["1", "2"]
+Client:
+  Timeout: "10s"
+  Retries: "3"
+`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &yamlServer{}
+	c.Name = name
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := &yamlServer{
+		YAMLClient: YAMLClient{10 * time.Second, 3},
+		Port:       8080,
+		Debug:      true,
+		Ratio:      0.5,
+		Ports:      []int{1, 2},
+	}
+	want.Name = name
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v; expected %+v", c, want)
+	}
+}
+
+func TestYAMLOrder(t *testing.T) {
+	c := &yamlServer{Port: 80, Ports: []int{1}}
+	c.Timeout = time.Second
+	var buf bytes.Buffer
+	if err := construct.SaveTo(c, constructs.NewStoreYAML, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `Client:
+  Timeout: 1s
+  Retries: 0
+Port: 80
+Debug: false
+Ratio: 0
+Ports:
+- 1
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, want)
+	}
+}
+
+type YAMLDB struct {
+	Host  string
+	Hosts []string
+}
+
+func (*YAMLDB) Init() error { return nil }
+func (*YAMLDB) Usage(name string) string {
+	switch name {
+	case "Host":
+		return "database host"
+	case "Hosts":
+		return "replicas"
+	}
+	return ""
+}
+
+type yamlComments struct {
+	constructs.ConfigFileYAML
+	Motd   string
+	YAMLDB `cfg:"DB"`
+	Port   int
+	Debug  bool
+}
+
+func (*yamlComments) Init() error { return nil }
+func (*yamlComments) Usage(name string) string {
+	switch name {
+	case "":
+		return "server config"
+	case "Motd":
+		return "message of the day"
+	case "Port":
+		return "listening port"
+	}
+	return ""
+}
+
+func TestYAMLComments(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.yaml")
+	c := &yamlComments{Motd: "hello\nPort: 1\n", Port: 80}
+	c.Host = "localhost"
+	c.Hosts = []string{"a", "b"}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# server config
+
+# message of the day
+Motd: |
+  hello
+  Port: 1
+DB:
+  # database host
+  Host: localhost
+  # replicas
+  Hosts:
+  - a
+  - b
+# listening port
+Port: 80
+Debug: false
+`
+	if got := string(data); got != want {
+		t.Fatalf("got:\n%s\nexpected:\n%s", got, want)
+	}
+
+	// Hand written comments are preserved for the config items without usage.
+	data = []byte(strings.Replace(string(data), "# listening port", "# public port", 1))
+	data = []byte(strings.Replace(string(data), "Debug:", "# debug mode\nDebug:", 1))
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c = &yamlComments{}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = strings.Replace(want, "Debug:", "# debug mode\nDebug:", 1)
+	if string(got) != want {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, want)
+	}
+}
@@ -7,7 +7,12 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/cespare/xxhash"
 	humanize "github.com/dustin/go-humanize"
@@ -111,3 +116,135 @@ func (sz *BytesSize) UnmarshalText(text []byte) error {
 	}
 	return err
 }
+
+// FileMode implements reading and writing file permissions.
+type FileMode os.FileMode
+
+var (
+	_ encoding.TextMarshaler   = (*FileMode)(nil)
+	_ encoding.TextUnmarshaler = (*FileMode)(nil)
+)
+
+// MarshalText makes FileMode implement encoding.TextMarshaler.
+// The permission bits are written in octal, e.g. 0644.
+func (m FileMode) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%04o", os.FileMode(m).Perm())), nil
+}
+
+// UnmarshalText makes FileMode implement encoding.TextUnmarshaler.
+// The mode is either in octal, e.g. 0644 or 644, or made of comma separated
+// symbolic clauses applied to the current mode as for chmod, e.g. u=rw,go+r.
+func (m *FileMode) UnmarshalText(text []byte) error {
+	s := string(text)
+	if s != "" && s[0] >= '0' && s[0] <= '7' {
+		u, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+		if err != nil || u > 0777 {
+			return fmt.Errorf("invalid file mode %q", s)
+		}
+		*m = FileMode(u)
+		return nil
+	}
+
+	mode := os.FileMode(*m).Perm()
+	for _, clause := range strings.Split(s, ",") {
+		var err error
+		if mode, err = applyModeClause(mode, clause); err != nil {
+			return err
+		}
+	}
+	*m = FileMode(mode)
+	return nil
+}
+
+// applyModeClause applies the symbolic clause to mode, e.g. ug+rw.
+func applyModeClause(mode os.FileMode, clause string) (os.FileMode, error) {
+	i := strings.IndexAny(clause, "+-=")
+	if i < 0 {
+		return 0, fmt.Errorf("invalid file mode clause %q", clause)
+	}
+	who, op, perms := clause[:i], clause[i], clause[i+1:]
+
+	// Bits of the permissions for all users.
+	var bits os.FileMode
+	for _, p := range perms {
+		switch p {
+		case 'r':
+			bits |= 0444
+		case 'w':
+			bits |= 0222
+		case 'x':
+			bits |= 0111
+		default:
+			return 0, fmt.Errorf("invalid file mode clause %q", clause)
+		}
+	}
+	// Restrict them to the users.
+	var mask os.FileMode
+	if who == "" {
+		who = "a"
+	}
+	for _, w := range who {
+		switch w {
+		case 'u':
+			mask |= 0700
+		case 'g':
+			mask |= 0070
+		case 'o':
+			mask |= 0007
+		case 'a':
+			mask |= 0777
+		default:
+			return 0, fmt.Errorf("invalid file mode clause %q", clause)
+		}
+	}
+	bits &= mask
+
+	switch op {
+	case '+':
+		mode |= bits
+	case '-':
+		mode &^= bits
+	case '=':
+		mode = mode&^mask | bits
+	}
+	return mode, nil
+}
+
+// URL implements reading and writing URLs.
+//
+// Unlike *url.URL, its value can be constrained with the url struct tag flag,
+// e.g. `cfg:",url=https|host|nouser"`.
+type URL url.URL
+
+var (
+	_ encoding.TextMarshaler   = (*URL)(nil)
+	_ encoding.TextUnmarshaler = (*URL)(nil)
+)
+
+// URL returns u as a *url.URL.
+func (u *URL) URL() *url.URL {
+	return (*url.URL)(u)
+}
+
+// String returns the URL as a string.
+func (u URL) String() string {
+	return u.URL().String()
+}
+
+// MarshalText makes URL implement encoding.TextMarshaler.
+func (u URL) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText makes URL implement encoding.TextUnmarshaler.
+func (u *URL) UnmarshalText(text []byte) error {
+	v, err := url.Parse(string(text))
+	if err != nil {
+		if e, ok := err.(*url.Error); ok {
+			err = e.Err
+		}
+		return fmt.Errorf("invalid URL %q: %v", text, err)
+	}
+	*u = URL(*v)
+	return nil
+}
@@ -0,0 +1,44 @@
+package constructs_test
+
+import (
+	"testing"
+
+	"github.com/pierrec/construct/constructs"
+)
+
+func TestFileMode(t *testing.T) {
+	for _, tc := range []struct {
+		mode constructs.FileMode
+		in   string
+		want constructs.FileMode
+	}{
+		{0, "0644", 0644},
+		{0, "755", 0755},
+		{0, "0o600", 0600},
+		{0, "u=rw,go=r", 0644},
+		{0644, "u+x", 0744},
+		{0755, "go-rx", 0700},
+		{0600, "+r", 0644},
+		{0777, "o=", 0770},
+	} {
+		m := tc.mode
+		if err := m.UnmarshalText([]byte(tc.in)); err != nil {
+			t.Errorf("%s: %v", tc.in, err)
+			continue
+		}
+		if m != tc.want {
+			t.Errorf("%s: got %o; expected %o", tc.in, m, tc.want)
+		}
+	}
+
+	for _, in := range []string{"0999", "01777", "u+z", "q+r", "rw"} {
+		var m constructs.FileMode
+		if err := m.UnmarshalText([]byte(in)); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+
+	if b, err := constructs.FileMode(0640).MarshalText(); err != nil || string(b) != "0640" {
+		t.Errorf("got %s (%v); expected 0640", b, err)
+	}
+}
@@ -0,0 +1,160 @@
+package constructs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pierrec/construct"
+)
+
+var _ construct.Resolver = (*VaultResolver)(nil)
+
+// VaultResolver resolves the references to secrets stored in HashiCorp Vault,
+// in the form <path>#<field>, e.g. kv/data/app#password, using its HTTP API.
+// Both versions of the KV secrets engine are supported. The field may be omitted
+// if the secret only has one.
+//
+// It is typically registered for the vault scheme:
+//  construct.OptionResolver("vault", &constructs.VaultResolver{})
+type VaultResolver struct {
+	// Address of the Vault server, e.g. https://vault:8200.
+	// It defaults to the VAULT_ADDR environment variable.
+	Address string
+	// Token used to authenticate to the Vault server.
+	// It defaults to the VAULT_TOKEN environment variable.
+	Token string
+	// Namespace of the secrets, if any.
+	// It defaults to the VAULT_NAMESPACE environment variable.
+	Namespace string
+	// Client used to query the Vault server instead of the default one.
+	Client *http.Client
+	// TTL is the duration for which the secrets read are cached.
+	// Leave empty to cache them until Invalidate is called.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	secrets map[string]vaultSecret // Secrets already read, by path.
+}
+
+// vaultSecret is a secret read from Vault.
+type vaultSecret struct {
+	data    map[string]interface{}
+	expires time.Time // Zero if the secret does not expire.
+}
+
+// Invalidate removes the secrets from the cache so that they are read again
+// from Vault, e.g. before reloading the config once they were rotated.
+func (v *VaultResolver) Invalidate() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secrets = nil
+}
+
+// Resolve returns the value of the secret field referenced by ref.
+func (v *VaultResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	path, field := ref, ""
+	if i := strings.LastIndexByte(ref, '#'); i >= 0 {
+		path, field = ref[:i], ref[i+1:]
+	}
+	data, err := v.read(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if field == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("vault: %s: missing field in reference", path)
+		}
+		for field = range data {
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("vault: %s: field %s not found", path, field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// read returns the data of the secret at path.
+// The lock is not held while querying Vault so that concurrent reads do not
+// wait for each other, the last one read being cached.
+func (v *VaultResolver) read(ctx context.Context, path string) (map[string]interface{}, error) {
+	v.mu.Lock()
+	cached, ok := v.secrets[path]
+	v.mu.Unlock()
+	if ok && (cached.expires.IsZero() || time.Now().Before(cached.expires)) {
+		return cached.data, nil
+	}
+
+	addr := v.Address
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("vault: no server address")
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	token := v.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	namespace := v.Namespace
+	if namespace == "" {
+		namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault: %s: %s", path, resp.Status)
+	}
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("vault: %s: %v", path, err)
+	}
+	data := secret.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			// KV version 2.
+			data = inner
+		}
+	}
+	cached = vaultSecret{data: data}
+	if v.TTL > 0 {
+		cached.expires = time.Now().Add(v.TTL)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.secrets == nil {
+		v.secrets = make(map[string]vaultSecret)
+	}
+	v.secrets[path] = cached
+	return data, nil
+}
@@ -0,0 +1,132 @@
+package constructs_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type vaultConfig struct {
+	constructs.ConfigFileJSON
+	User     string
+	Password string `cfg:",secretref"`
+}
+
+func (*vaultConfig) Init() error              { return nil }
+func (*vaultConfig) Usage(name string) string { return "" }
+
+func TestVaultResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" || r.URL.Path != "/v1/kv/data/app" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data": {"data": {"password": "s3cret"}, "metadata": {"version": 1}}}`))
+	}))
+	defer srv.Close()
+
+	name := filepath.Join(t.TempDir(), "config.json")
+	data := `{"User": "admin", "Password": "vault:kv/data/app#password"}`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := &vaultConfig{}
+	c.Name = name
+	c.ToSave = true
+	vault := &constructs.VaultResolver{Address: srv.URL, Token: "token"}
+	if err := construct.LoadArgs(c, nil, construct.OptionResolver("vault", vault)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Password != "s3cret" {
+		t.Errorf("got password %q; expected s3cret", c.Password)
+	}
+	saved, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(saved); !strings.Contains(s, "vault:kv/data/app#password") || strings.Contains(s, "s3cret") {
+		t.Errorf("secret reference not saved:\n%s", s)
+	}
+
+	c = &vaultConfig{Password: "vault:kv/data/other#password"}
+	err = construct.LoadArgs(c, nil, construct.OptionResolver("vault", vault))
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("got error %v; expected 403", err)
+	}
+}
+
+type vaultPointerConfig struct {
+	Password *string `cfg:",secretref"`
+	Token    *string `cfg:",secretref"`
+}
+
+func (*vaultPointerConfig) Init() error              { return nil }
+func (*vaultPointerConfig) Usage(name string) string { return "" }
+
+type vaultIntConfig struct {
+	Port int `cfg:",secretref"`
+}
+
+func (*vaultIntConfig) Init() error              { return nil }
+func (*vaultIntConfig) Usage(name string) string { return "" }
+
+func TestVaultResolverTypes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"password": "s3cret"}}`))
+	}))
+	defer srv.Close()
+	vault := &constructs.VaultResolver{Address: srv.URL}
+
+	ref := "vault:kv/app"
+	c := &vaultPointerConfig{Password: &ref}
+	if err := construct.LoadArgs(c, nil, construct.OptionResolver("vault", vault)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Password == nil || *c.Password != "s3cret" || c.Token != nil {
+		t.Errorf("secrets not resolved: %+v", c)
+	}
+
+	err := construct.LoadArgs(&vaultIntConfig{}, nil, construct.OptionResolver("vault", vault))
+	if err == nil || !strings.Contains(err.Error(), "secret reference on non string field") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestVaultResolverCache(t *testing.T) {
+	var reads int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reads, 1)
+		w.Write([]byte(`{"data": {"password": "s3cret"}}`))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	check := func(vault *constructs.VaultResolver, want int32) {
+		t.Helper()
+		if v, err := vault.Resolve(ctx, "kv/app#password"); err != nil || v != "s3cret" {
+			t.Fatalf("got %q (%v); expected s3cret", v, err)
+		}
+		if got := atomic.LoadInt32(&reads); got != want {
+			t.Errorf("got %d reads; expected %d", got, want)
+		}
+	}
+
+	vault := &constructs.VaultResolver{Address: srv.URL}
+	check(vault, 1)
+	check(vault, 1)
+	vault.Invalidate()
+	check(vault, 2)
+
+	vault = &constructs.VaultResolver{Address: srv.URL, TTL: time.Millisecond}
+	check(vault, 3)
+	time.Sleep(2 * time.Millisecond)
+	check(vault, 4)
+}
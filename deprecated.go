@@ -0,0 +1,57 @@
+package construct
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pierrec/construct/internal/structs"
+	"github.com/pkg/errors"
+)
+
+// replacedName returns the name of the config item replacing the deprecated
+// config item name by the field by of the same struct.
+func (c *config) replacedName(name, by string) string {
+	if i := strings.LastIndex(name, c.options.gsep); i >= 0 {
+		return name[:i+len(c.options.gsep)] + by
+	}
+	return by
+}
+
+// deprecatedBy returns the field replacing the deprecated config item name by
+// the field by of the same struct, or nil if it does not exist.
+func (c *config) deprecatedBy(name, by string) *structs.StructField {
+	keys := strings.Split(name, c.options.gsep)
+	keys[len(keys)-1] = by
+	return c.root.Lookup(keys...)
+}
+
+// applyDeprecated warns about the deprecated config items set by a source and
+// assigns their value to the config items replacing them, unless already set by a source.
+func (c *config) applyDeprecated() error {
+	names := make([]string, 0, len(c.sources))
+	for name := range c.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := c.root.Lookup(strings.Split(name, c.options.gsep)...)
+		by, ok := field.Flag("deprecated")
+		if !ok || c.sources[name] == SourceDefault {
+			continue
+		}
+		newName := c.replacedName(name, by)
+		fmt.Fprintf(c.options.dout, "%s (%s) is deprecated, use %s\n", name, c.sources[name], newName)
+		if _, ok := c.sources[newName]; ok {
+			// The new config item has precedence.
+			continue
+		}
+		if err := c.deprecatedBy(name, by).Set(field.Interface()); err != nil {
+			return errors.Errorf("%s: %v", newName, err)
+		}
+		c.setSource(newName, c.sources[name], c.raws[name])
+		delete(c.trans, strings.ToLower(newName))
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package construct
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FieldDiff describes the difference between two values of a config item.
+type FieldDiff struct {
+	// Key is the config item name, with its groups separated by the flags group separator.
+	Key string
+	// From is the reference value.
+	From string
+	// To is the compared value.
+	//
+	// Values of secret and sensitive config items are set to RedactedValue.
+	To string
+}
+
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %s -> %s", d.Key, d.From, d.To)
+}
+
+// Drift compares config against the baseline file of the given registered format
+// and reports the config items that differ from the ones set in the file,
+// as the Changed items reported by Diff with the same options.
+// Config items not set in the file are ignored.
+//
+// config is not modified.
+func Drift(config Config, baselinePath, format string, options ...Option) ([]FieldDiff, error) {
+	fn, err := storeFn(format)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(baselinePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	res, err := Diff(config, fn, f, options...)
+	if err != nil {
+		return nil, errors.Errorf("%s: %v", baselinePath, err)
+	}
+	return res.Changed, nil
+}
+
+// StoreDiff lists the differences between a config and a Store.
+type StoreDiff struct {
+	// Added are the names of the config items not set in the Store
+	// that would be added to it when saving the config.
+	Added []string
+	// Removed are the dotted keys of the Store not mapping to any config item.
+	Removed []string
+	// Changed are the config items which values differ, the From value
+	// being the one of the Store.
+	Changed []FieldDiff
+}
+
+// Empty returns whether or not the config and the Store are identical.
+func (d *StoreDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares config against the content of r, typically the file config is
+// saved to, read with the Store returned by newStore, and reports the differences,
+// e.g. to check whether or not saving config would change the file.
+// The Store must implement KeysStore.
+// Sensitive and secret config items are ignored as they are not saved.
+//
+// config is not modified.
+func Diff(config Config, newStore NewStoreFn, r io.Reader, options ...Option) (*StoreDiff, error) {
+	conf, err := newConfig(config, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := conf.buildKeys(conf.root.Fields(), "", false); err != nil {
+		return nil, err
+	}
+	store := conf.newIOStore(newStore)
+	if _, err := store.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	res := new(StoreDiff)
+	if err := conf.checkIOKeys(store, nil); err != nil {
+		uerr, ok := err.(*UnknownKeyError)
+		if !ok {
+			return nil, err
+		}
+		res.Removed = uerr.Keys
+	}
+	for _, name := range conf.trans {
+		field := conf.root.Lookup(strings.Split(name, conf.options.gsep)...)
+		if isSecret(field) || ioDiscarded(store, field) {
+			continue
+		}
+		if _, ok := field.Flag("deprecated"); ok {
+			continue
+		}
+		if !store.Has(conf.fromNameAll(name, conf.options.gsep)...) {
+			res.Added = append(res.Added, name)
+		}
+	}
+	sort.Strings(res.Added)
+
+	bconf, names, err := loadStore(config, store, options)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(names); i++ {
+		if conf.isSecret(names[i]) {
+			names = append(names[:i], names[i+1:]...)
+			i--
+		}
+	}
+	res.Changed, err = diff(bconf, conf, names)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// loadStore loads the config items set in store into a new instance of the
+// type of config, and returns its config along with the names of these items.
+func loadStore(config Config, store Store, options []Option) (*config, []string, error) {
+	base := reflect.New(reflect.TypeOf(config).Elem()).Interface().(Config)
+	bconf, err := newConfig(base, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := bconf.buildKeys(bconf.root.Fields(), "", false); err != nil {
+		return nil, nil, err
+	}
+	var names []string
+	for lname, name := range bconf.trans {
+		keys := bconf.fromNameAll(name, bconf.options.gsep)
+		if !store.Has(keys...) {
+			delete(bconf.trans, lname)
+			continue
+		}
+		names = append(names, name)
+	}
+	if err := bconf.updateIO(store, true, nil); err != nil {
+		return nil, nil, err
+	}
+	return bconf, names, nil
+}
+
+// diff compares the config items identified by names between a and b.
+// The differences are sorted by key.
+func diff(a, b *config, names []string) ([]FieldDiff, error) {
+	sort.Strings(names)
+	var res []FieldDiff
+	for _, name := range names {
+		from, err := a.marshalItem(name)
+		if err != nil {
+			return nil, err
+		}
+		to, err := b.marshalItem(name)
+		if err != nil {
+			return nil, err
+		}
+		if from != to {
+			if a.isSecret(name) {
+				from, to = RedactedValue, RedactedValue
+			}
+			res = append(res, FieldDiff{name, from, to})
+		}
+	}
+	return res, nil
+}
+
+// marshalItem returns the serialized value of the config item.
+func (c *config) marshalItem(name string) (string, error) {
+	keys := strings.Split(name, c.options.gsep)
+	field := c.root.Lookup(keys...)
+	if field == nil {
+		return "", errors.Errorf("unknown config item %s", name)
+	}
+	v, err := field.MarshalValue()
+	if err != nil {
+		return "", errors.Errorf("%s: %v", name, err)
+	}
+	return fmt.Sprintf("%v", v), nil
+}
+
+// isSecret returns whether or not the value of the config item must not be disclosed.
+func (c *config) isSecret(name string) bool {
+	field := c.root.Lookup(strings.Split(name, c.options.gsep)...)
+	return field != nil && isSecret(field)
+}
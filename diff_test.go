@@ -0,0 +1,88 @@
+package construct_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+	_ "github.com/pierrec/construct/constructs"
+)
+
+type cfgDrift struct {
+	Host  string
+	Port  int
+	Debug bool
+	Tags  []string
+}
+
+func (*cfgDrift) Init() error              { return nil }
+func (*cfgDrift) Usage(name string) string { return "" }
+
+func TestDrift(t *testing.T) {
+	baseline := filepath.Join(t.TempDir(), "baseline.json")
+	data := `{"Host": "localhost", "Port": 80}`
+	if err := os.WriteFile(baseline, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &cfgDrift{Host: "localhost", Port: 8080, Debug: true}
+	diffs, err := construct.Drift(c, baseline, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []construct.FieldDiff{{Key: "Port", From: "80", To: "8080"}}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("got %v; expected %v", diffs, want)
+	}
+	if c.Port != 8080 {
+		t.Errorf("config modified")
+	}
+
+	// The options apply to the baseline file.
+	data = `{"host": "localhost", "port": 80}`
+	if err := os.WriteFile(baseline, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	diffs, err = construct.Drift(c, baseline, "json", construct.OptionIOCase(construct.CaseSnake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("got %v; expected %v", diffs, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	newStore := func(lookup construct.LookupFn) construct.Store {
+		store, _ := construct.NewStore("json", lookup)
+		return store
+	}
+	data := `{"Host": "localhost", "Port": 80, "Verbose": true, "Tags": ["a"]}`
+
+	c := &cfgDrift{Host: "localhost", Port: 8080, Tags: []string{"a"}}
+	diff, err := construct.Diff(c, newStore, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &construct.StoreDiff{
+		Added:   []string{"Debug"},
+		Removed: []string{"Verbose"},
+		Changed: []construct.FieldDiff{{Key: "Port", From: "80", To: "8080"}},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("got %+v; expected %+v", diff, want)
+	}
+
+	c = &cfgDrift{Host: "localhost", Port: 80}
+	data = `{"Host": "localhost", "Port": 80, "Debug": false, "Tags": null}`
+	diff, err = construct.Diff(c, newStore, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Errorf("got %+v; expected no differences", diff)
+	}
+}
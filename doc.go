@@ -19,6 +19,9 @@
 //  - fields are only processed if they are exported
 //  - a field represents a config item for the Config interface
 //  - an embedded type implementing the Config interface is used to group config items logically
+//  - a named struct field which type implements the Config interface is also a group,
+//    named after the field, so that the same type can be used for several groups.
+//    Other struct fields are single config items, e.g. tables in FromIO sources
 //  - an embedded type implementing the Config and FromFlags interfaces represents a subcommand
 //  - fields processing can be modified using field tags with the following format
 //
@@ -26,13 +29,84 @@
 //
 // If the key is "-", the field is ignored.
 // If the key is not empty, the value is used as the field name.
+// The tag names can be changed with OptionTagID and OptionSepTagID, and the field
+// names taken from other tags, such as json, with OptionNameTags.
 //
 // The following struct tag flags are currently supported:
 //
 //     inline       Inline the field which must be a struct, instead of
 //                  processing it as a group of config items. Inlined fields
 //                  must not collide with the outer struct ones.
-//                  It has no effect on non embedded types.
+//                  It has no effect on non group fields.
+//     sensitive    The field can only be set from environment variables
+//                  or FromIO sources implementing SecureIO. It is neither
+//                  available as a command line flag nor saved to non
+//                  secure sources.
+//     secret       The field is loaded from all sources but its value is
+//                  never disclosed: it is only saved to non secure sources
+//                  if it was read from them and is redacted in snapshots,
+//                  reports and diffs.
+//     secretref    The string or *string field value is a reference to a secret, e.g.
+//                  vault:kv/data/app#password, replaced by the secret once
+//                  loaded from all sources (see OptionResolver). It is a
+//                  secret and the reference is saved instead of its value.
+//     noenv        The field, or all the fields of the embedded struct, are
+//                  not set from environment variables.
+//     noio         The field is not written to FromIO sources, snapshots
+//                  and diffs, as the settings of the FromIO sources
+//                  themselves, e.g. the config file name.
+//     required     The field must be set by a source or have a non zero
+//                  value, otherwise Load fails with a ValidationError.
+//     count        The integer field command line flag does not take any
+//                  value and is set to its number of occurrences, e.g. -vvv
+//                  sets it to 3.
+//     hidden       The field command line flag, or the subcommand, is not
+//                  listed in the usage nor completed, but it is still
+//                  documented (see GenDocs) and its usage is saved as comment.
+//                  An empty usage also hides flags and subcommands but
+//                  removes their documentation.
+//     main=<name>  The config item <name> of the embedded struct is set with
+//                  the command line flag named after the struct, e.g.
+//                  --config instead of --config-name. On an inlined struct,
+//                  it applies to the struct it is inlined into.
+//     explicit     The bool field command line flag requires a value,
+//                  e.g. --flag=true, instead of being set by its presence.
+//     deprecated=<name>
+//                  The field is deprecated in favor of the field <name> of
+//                  the same struct. When set by any source, a warning is
+//                  written (see OptionDeprecatedWriter) and its value is
+//                  assigned to the new field unless the new field is set by a
+//                  source. It is hidden from the usage and not saved.
+//     choices=<c1>|<c2>...
+//                  The field values are restricted to the given choices, which
+//                  are listed in the usage and completed. Otherwise, Load fails
+//                  with a ValidationError. Types implementing Enumerator are
+//                  restricted the same way.
+//     url=<c1>|<c2>...
+//                  The *url.URL or constructs.URL field value is constrained
+//                  by the allowed schemes and the options "host", requiring
+//                  the host, and "nouser", forbidding user information, e.g.
+//                  url=https|host|nouser. Otherwise, Load fails with a
+//                  ValidationError.
+//     merge=<strategy>
+//                  The slice or map field set by several sources merges their
+//                  values instead of keeping the one of the source with the
+//                  highest priority (replace): append appends the slice items
+//                  in increasing priority order, e.g. file then env then
+//                  flags, and union does the same without duplicates. Map
+//                  items are merged with both, the highest priority winning.
+//                  Saving merged values adds them to the FromIO source.
+//     time=<l1>|<l2>...
+//                  The time.Time field is parsed with the first matching
+//                  layout, as defined by the time package, and formatted with
+//                  the first one, e.g. time=2006-01-02|2006-01-02T15:04.
+//                  Layouts cannot contain commas.
+//     float=<f>    Float values are formatted using the strconv.FormatFloat
+//                  format <f> optionally followed by the precision, e.g.
+//                  float=e or float=f2, for the flags defaults and the
+//                  sources storing values as text. All sources format floats
+//                  by default without exponent and with the minimum number
+//                  of digits.
 //
 // Subcommands
 //
@@ -41,45 +115,99 @@
 //
 // The FlagsDone() method is invoked on the last subcommand with
 // the remaining command line arguments.
+// Commands implementing the PreRunner and PostRunner interfaces, such as the
+// main one, have their PreRun and PostRun methods invoked around it, which
+// is convenient for concerns shared by all the subcommands.
+//
+// The help of a subcommand, requested with -h or the automatic help command,
+// e.g. "help serve", lists its flags and the ones of its parent commands.
+// The help command is not available if flags are interspersed or a subcommand
+// is named help.
+//
+// The config items of a subcommand are only set when it is invoked.
+// If it does not implement the FromEnv or FromIO interfaces, the ones of its
+// closest parent command are used with the config items namespaced under
+// the command path: the Port item of the Serve subcommand is looked up as
+// Serve-Port in environment variables and under the Serve group in io sources,
+// so that subcommands can define config items with the same name.
+// The io sources of the parent command are not read again.
 //
 // Sources
 //
 // Data used to populate structs can be fetched from various sources to override
 // the current struct instance values in the following order:
 //  - file in various formats
+//  - remote key/value stores
 //  - environment variables
 //  - command line flags
 //
 // The data sources are defined by implementing the relevant interfaces on the struct:
 //  - FromFlags interface for command line flags
-//  - FromEnv interface for environment variables
-//  - FromIO interface for io sources
-//
-// Once the data is loaded from all sources, the Init() method is invoked
+//  - FromEnv interface or OptionEnvPrefix for environment variables
+//  - FromRemote interface for remote key/value stores
+//  - FromIO interface for io sources, or FromIOMulti for several layered ones
+//
+// The names of the config items in the sources are derived from their groups and
+// field names, which can be customized per source with the FlagsNamer, FromEnv
+// and IONamer interfaces, or converted with OptionFlagsCase and OptionIOCase.
+//
+// Once the data is loaded from all sources, the required config items and
+// the ones implementing the Validator interface, as well as the Config structs
+// implementing it, are validated, all failures being reported in a
+// ValidationError. Then the Init() method is invoked
 // on the main struct as well as all the embedded ones except subcommands that have
 // not been requested.
 //
+// A config item failing to be set from a source is reported in a FieldError
+// holding its path and source, which wraps a ParseError if its value could not
+// be converted. Unknown keys in FromIO sources are reported in an UnknownKeyError.
+// With OptionCollectErrors, they are all reported at once in a MultiError.
+//
 // Supported field types
 //
 // The following types, as well as slices and maps of them, are supported:
 //  - time.Duration, time.Time
 //  - *url.URL
 //  - *regexp.Regexp
-//  - *text/template.Template, *html/template.Template
-//  - *net.IPAddr, *net.IPNet
+//  - *text/template.Template, *html/template.Template. Values starting with "@"
+//    reference the file holding the template, e.g. "@mail.tmpl", which is
+//    preserved on save. A template starting with "@" is escaped as "@@"
+//  - net.IP, *net.IPAddr, net.IPNet, *net.IPNet, net.HardwareAddr
 //  - bool
 //  - string
 //  - float32, float64
 //  - int, int8, int16, int32, int64
 //  - uint, uint8, uint16, uint32, uint64
+//  - math/big.Int, math/big.Float, math/big.Rat. Values decoded as numbers by
+//    FromIO sources may already be truncated: use strings to keep their precision
 //  - types implementing encoding.TextMarshaler and encoding.TextUnmarshaler
+//  - types implementing encoding.BinaryMarshaler and encoding.BinaryUnmarshaler,
+//    as base64 encoded strings
+//  - types implementing the String and Set methods of flag.Value, with a pointer
+//    receiver for Set. Those also implementing pflag.Value are used as is as
+//    command line flags
+//
+// Pointers to any of these types, e.g. *int, distinguish unset config items from
+// the ones set to their zero value: they are nil unless a source sets them, in which
+// case the value is allocated. Unset items are not saved. Pointers to structs are
+// only set from FromIO sources.
+//
+// Maps with struct values, e.g. map[string]Listener, are stored as nested
+// tables by FromIO sources and their entries are commented with the usage of
+// the struct if it implements Config. Command line flags and environment
+// variables address their fields by key as <key>.<field><sep><value> items,
+// e.g. --listeners web.addr:localhost or with `sep:",="` --listeners web.addr=:80.
 //
 // Configuration formats
 //
 // The FromIO interface is used to load and save the configuration from and to
 // any kind of storage and using any format.
+// When saving a loaded configuration, the keys not mapping to any config item
+// are kept, as well as the comments read by Stores implementing CommentStore
+// for the config items without usage.
 //
 // Implementations for file based storage and widely used formats such as json, toml,
-// yaml or ini are available in the construct/constructs package.
+// yaml or ini are available in the construct/constructs package, which registers
+// them by name with RegisterStore so that they can be retrieved with NewStore.
 //
 package construct
@@ -0,0 +1,337 @@
+package construct
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pierrec/construct/internal/structs"
+	"github.com/pkg/errors"
+)
+
+// GenDocs writes the documentation of the config items of config and of its
+// subcommands to w in the given format: "markdown" or "man" (roff).
+//
+// Each config item is documented with its usage, type, default value, command
+// line flag if config implements FromFlags and environment variable if any.
+// The command name is the program name, see OptionProgramName.
+// Hidden config items and subcommands, i.e. with an empty usage, are not documented.
+func GenDocs(config Config, format string, w io.Writer, options ...Option) error {
+	var write func(io.Writer, *docCommand) error
+	switch format {
+	case "markdown":
+		write = writeMarkdownDocs
+	case "man":
+		write = writeManDocs
+	default:
+		return errors.Errorf("unsupported documentation format %q", format)
+	}
+
+	conf, err := newFlagsConfig(config, options)
+	if err != nil {
+		return err
+	}
+	name := conf.options.prog
+	cmd, err := conf.newDocCommand(name)
+	if err != nil {
+		return err
+	}
+	return write(w, cmd)
+}
+
+// docCommand describes the documentation of a command and its subcommands.
+type docCommand struct {
+	name  string // Full name of the command, including its parents.
+	usage string
+	items []docItem
+	cmds  []*docCommand
+}
+
+// docItem describes the documentation of a config item.
+type docItem struct {
+	name  string
+	flag  string
+	short string
+	env   string
+	typ   string
+	def   string
+	usage string
+}
+
+// newDocCommand returns the documentation of c, its flags being built, and of its subcommands.
+func (c *config) newDocCommand(name string) (*docCommand, error) {
+	cmd := &docCommand{name: name, usage: c.raw.Usage("")}
+	cmd.items = c.docItems("", c.root, nil)
+
+	for _, field := range c.root.Fields() {
+		s, sc := getCommand(field)
+		if s == nil || sc.Usage("") == "" {
+			continue
+		}
+		sub := newConfigFromStruct(s, sc, c)
+		if err := sub.buildKeys(s.Fields(), "", false); err != nil {
+			return nil, err
+		}
+		if err := sub.buildFlags("", s); err != nil {
+			return nil, err
+		}
+		subcmd, err := sub.newDocCommand(name + " " + strings.ToLower(s.Name()))
+		if err != nil {
+			return nil, err
+		}
+		cmd.cmds = append(cmd.cmds, subcmd)
+	}
+	sort.Slice(cmd.cmds, func(i, j int) bool { return cmd.cmds[i].name < cmd.cmds[j].name })
+
+	return cmd, nil
+}
+
+// docItems appends the documentation of the config items of root to items,
+// in the order they are defined.
+func (c *config) docItems(section string, root *structs.StructStruct, items []docItem) []docItem {
+	config, ok := root.Interface().(Config)
+	if !ok {
+		return items
+	}
+	_, isFlags := c.raw.(FromFlags)
+	from, prefix := c.fromEnv()
+
+	for _, field := range root.Fields() {
+		if s, _ := getCommand(field); s != nil {
+			continue
+		}
+		if emb := field.Embedded(); emb != nil {
+			items = c.docItems(c.toSection(section, emb), emb, items)
+			continue
+		}
+		usage := config.Usage(field.Name())
+		if _, ok := field.Flag("deprecated"); ok || usage == "" {
+			// Hidden config item.
+			continue
+		}
+		name := c.toName(section, field)
+		fname := c.flagName(name)
+		if choices := enumValues(field); len(choices) > 0 {
+			usage += " (one of " + strings.Join(choices, ", ") + ")"
+		}
+		item := docItem{
+			name:  name,
+			typ:   strings.TrimPrefix(field.Type().String(), "*"),
+			def:   c.flagDefault(fname),
+			usage: usage,
+		}
+		if f := c.fs.Lookup(fname); isFlags && f != nil {
+			item.flag = f.Name
+			if c.isNegated(f.Name) {
+				item.flag = "[no-]" + f.Name
+			}
+			item.short = f.Shorthand
+		}
+		if from != nil && !c.noenv[name] {
+			item.env = from.Env(strings.Join(append(prefix, name), c.options.gsep))
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func writeMarkdownDocs(w io.Writer, cmd *docCommand) error {
+	return writeMarkdownCommand(w, cmd, 1)
+}
+
+func writeMarkdownCommand(w io.Writer, cmd *docCommand, level int) error {
+	title := strings.Repeat("#", level)
+	if _, err := fmt.Fprintf(w, "%s %s\n\n", title, cmd.name); err != nil {
+		return err
+	}
+	if cmd.usage != "" {
+		if _, err := fmt.Fprintf(w, "%s\n\n", cmd.usage); err != nil {
+			return err
+		}
+	}
+
+	if len(cmd.items) > 0 {
+		if _, err := fmt.Fprintf(w, "%s# Options\n\n", title); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "| Name | Flag | Environment | Type | Default | Description |\n"); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "|------|------|-------------|------|---------|-------------|\n"); err != nil {
+			return err
+		}
+		for _, item := range cmd.items {
+			flag := item.flag
+			if flag != "" {
+				flag = "`--" + flag + "`"
+				if item.short != "" {
+					flag = "`-" + item.short + "`, " + flag
+				}
+			}
+			_, err := fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s |\n",
+				item.name, flag, markdownCode(item.env), item.typ,
+				markdownCode(item.def), markdownCell(item.usage))
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+
+	if len(cmd.cmds) > 0 {
+		if _, err := fmt.Fprintf(w, "%s# Commands\n\n", title); err != nil {
+			return err
+		}
+		for _, sub := range cmd.cmds {
+			if _, err := fmt.Fprintf(w, "- `%s`: %s\n", sub.name, markdownCell(sub.usage)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+
+	for _, sub := range cmd.cmds {
+		if err := writeMarkdownCommand(w, sub, level+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdownCode formats s as inline code, if not empty.
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + markdownCell(s) + "`"
+}
+
+// markdownCell escapes s for use in a table cell.
+func markdownCell(s string) string {
+	s = strings.Replace(s, "|", `\|`, -1)
+	return strings.Replace(s, "\n", " ", -1)
+}
+
+func writeManDocs(w io.Writer, cmd *docCommand) error {
+	if _, err := fmt.Fprintf(w, ".TH %s 1\n", roffEscape(strings.ToUpper(cmd.name))); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, ".SH NAME\n%s", roffEscape(cmd.name)); err != nil {
+		return err
+	}
+	if cmd.usage != "" {
+		if _, err := fmt.Fprintf(w, " \\- %s", roffEscape(cmd.usage)); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, ".SH SYNOPSIS\n\\fB%s\\fR [\\fIoptions\\fR]", roffEscape(cmd.name)); err != nil {
+		return err
+	}
+	if len(cmd.cmds) > 0 {
+		if _, err := fmt.Fprintf(w, " [\\fIcommand\\fR]"); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+	if len(cmd.items) > 0 {
+		if _, err := fmt.Fprintf(w, ".SH OPTIONS\n"); err != nil {
+			return err
+		}
+		if err := writeManItems(w, cmd); err != nil {
+			return err
+		}
+	}
+
+	if len(cmd.cmds) > 0 {
+		if _, err := fmt.Fprintf(w, ".SH COMMANDS\n"); err != nil {
+			return err
+		}
+		return writeManCommands(w, cmd)
+	}
+	return nil
+}
+
+// writeManCommands recursively writes the subcommands of cmd as subsections.
+func writeManCommands(w io.Writer, cmd *docCommand) error {
+	for _, sub := range cmd.cmds {
+		if _, err := fmt.Fprintf(w, ".SS %s\n", roffEscape(sub.name)); err != nil {
+			return err
+		}
+		if sub.usage != "" {
+			if _, err := fmt.Fprintf(w, "%s\n", roffEscape(sub.usage)); err != nil {
+				return err
+			}
+		}
+		if len(sub.items) > 0 {
+			if _, err := fmt.Fprintf(w, ".PP\nOptions:\n"); err != nil {
+				return err
+			}
+			if err := writeManItems(w, sub); err != nil {
+				return err
+			}
+		}
+		if err := writeManCommands(w, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeManItems writes the config items of cmd as a list of tagged paragraphs.
+func writeManItems(w io.Writer, cmd *docCommand) error {
+	for _, item := range cmd.items {
+		if _, err := fmt.Fprintf(w, ".TP\n"); err != nil {
+			return err
+		}
+		var tag []string
+		if item.short != "" {
+			tag = append(tag, `\fB\-`+roffEscape(item.short)+`\fR`)
+		}
+		if item.flag != "" {
+			tag = append(tag, `\fB\-\-`+roffEscape(item.flag)+`\fR`)
+		}
+		if len(tag) == 0 {
+			tag = append(tag, `\fB`+roffEscape(item.name)+`\fR`)
+		}
+		if _, err := fmt.Fprintf(w, "%s \\fI%s\\fR\n", strings.Join(tag, ", "), roffEscape(item.typ)); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", roffEscape(item.usage)); err != nil {
+			return err
+		}
+		if item.def != "" {
+			if _, err := fmt.Fprintf(w, ".br\nDefault: %s\n", roffEscape(item.def)); err != nil {
+				return err
+			}
+		}
+		if item.env != "" {
+			if _, err := fmt.Fprintf(w, ".br\nEnvironment: %s\n", roffEscape(item.env)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// roffEscape escapes s for use as roff text.
+func roffEscape(s string) string {
+	s = strings.Replace(s, `\`, `\e`, -1)
+	s = strings.Replace(s, "-", `\-`, -1)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
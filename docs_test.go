@@ -0,0 +1,74 @@
+package construct_test
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+)
+
+func TestGenDocs(t *testing.T) {
+	const name = "my-app"
+	c := cfgCompletion{Config: "app.toml"}
+	opts := []construct.Option{construct.OptionEnvPrefix("APP"), construct.OptionProgramName(name)}
+	for _, tc := range []struct {
+		format string
+		want   []string
+	}{
+		{"markdown", []string{
+			"# " + name + "\n",
+			"| Config | `--config` | `APP_CONFIG` | string | `\"app.toml\"` | config file |",
+			"| Verbose | `-v`, `--verbose` | `APP_VERBOSE` | bool |  | verbose mode |",
+			"- `" + name + " deploy`: deploy the app",
+			"## " + name + " deploy\n",
+			"| Force | `--force` | `APP_DEPLOY_FORCE` | bool |  | force the deployment |",
+		}},
+		{"man", []string{
+			".SH OPTIONS\n",
+			"\\fB\\-\\-config\\fR \\fIstring\\fR\nconfig file\n.br\nDefault: \"app.toml\"\n.br\nEnvironment: APP_CONFIG\n",
+			"\\fB\\-v\\fR, \\fB\\-\\-verbose\\fR \\fIbool\\fR\n",
+			".SS " + strings.Replace(name, "-", "\\-", -1) + " deploy\ndeploy the app\n",
+		}},
+	} {
+		var buf bytes.Buffer
+		if err := construct.GenDocs(&c, tc.format, &buf, opts...); err != nil {
+			t.Fatal(err)
+		}
+		s := buf.String()
+		for _, want := range tc.want {
+			if !strings.Contains(s, want) {
+				t.Errorf("%s: missing %q in:\n%s", tc.format, want, s)
+			}
+		}
+		if tc.format != "man" {
+			continue
+		}
+		if groff, err := exec.LookPath("groff"); err == nil {
+			cmd := exec.Command(groff, "-man", "-Tutf8", "-ww", "-z")
+			cmd.Stdin = &buf
+			if out, err := cmd.CombinedOutput(); err != nil || len(out) > 0 {
+				t.Errorf("invalid man page: %v\n%s", err, out)
+			}
+		}
+	}
+
+	if err := construct.GenDocs(&c, "html", &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+
+	for _, format := range []string{"markdown", "man"} {
+		if err := construct.GenDocs(&c, format, failWriter{}); err != errWrite {
+			t.Errorf("%s: got error %v; expected %v", format, err, errWrite)
+		}
+	}
+}
+
+var errWrite = errors.New("write failed")
+
+// failWriter fails all writes.
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) { return 0, errWrite }
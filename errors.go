@@ -0,0 +1,150 @@
+package construct
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pierrec/construct/internal/structs"
+	"github.com/pkg/errors"
+)
+
+// ErrNotSecure is the error of a FieldError for a sensitive config item
+// set from a FromIO source not implementing SecureIO.
+var ErrNotSecure = errors.New("sensitive config item set from a non secure source")
+
+// ErrHelp is returned by Load when the help is requested with the -h or --help
+// command line flag, once the usage is written, unless OptionFlagsUsage is set.
+var ErrHelp = errors.New("help requested")
+
+// FieldError is returned by Load when a config item cannot be set from a source.
+// Use errors.As to retrieve it and errors.Is or errors.As on it to inspect its cause,
+// e.g. a ParseError.
+type FieldError struct {
+	// Path is the dotted path to the config item, e.g. "Group.Field".
+	Path string
+	// Source is the source the config item was being set from.
+	Source Source
+	// Key is the name of the config item in the source, e.g. the flag name or
+	// the environment variable. It is empty for FromIO sources.
+	Key string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Source, e.Key, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// ParseError is the error of a FieldError for a value which could not
+// be converted to the type of its config item.
+type ParseError struct {
+	// Value is the value as read from the source.
+	// It is redacted for secret config items.
+	Value string
+	// Err is the conversion error.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// UnknownKeyError is returned by Load when the FromIO source has keys
+// not mapping to any config item with OptionStrictIO.
+type UnknownKeyError struct {
+	// Keys are the dotted unknown keys, in alphabetical order.
+	Keys []string
+}
+
+func (e *UnknownKeyError) Error() string {
+	return "unknown config keys: " + strings.Join(e.Keys, ", ")
+}
+
+// UnknownFlagError is returned by Load when a command line flag is not defined.
+type UnknownFlagError struct {
+	// Flag is the unknown flag, with its dashes.
+	Flag string
+	// Suggestions are the defined flags closest to Flag, if any.
+	Suggestions []string
+}
+
+func (e *UnknownFlagError) Error() string {
+	return "unknown flag: " + e.Flag + didYouMean(e.Suggestions)
+}
+
+// UnknownCommandError is returned by Load when the help command, or with
+// OptionStrictCommands a command line argument, does not match any subcommand,
+// typically a misspelled one.
+type UnknownCommandError struct {
+	// Command is the unknown command.
+	Command string
+	// Suggestions are the subcommands closest to Command.
+	Suggestions []string
+}
+
+func (e *UnknownCommandError) Error() string {
+	return "unknown command: " + e.Command + didYouMean(e.Suggestions)
+}
+
+// didYouMean formats the suggestions for an unknown name.
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return ", did you mean " + strings.Join(suggestions, " or ") + "?"
+}
+
+// MultiError is returned by Load with OptionCollectErrors when one or more
+// config items could not be set. Use errors.As to retrieve the individual errors,
+// e.g. a FieldError or a ValidationError.
+type MultiError struct {
+	errs []error
+}
+
+// Errors returns the collected errors in the order they were encountered.
+func (e *MultiError) Errors() []error {
+	return e.errs
+}
+
+func (e *MultiError) Error() string {
+	lst := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		lst[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors: %s", len(e.errs), strings.Join(lst, "; "))
+}
+
+func (e *MultiError) Unwrap() []error { return e.errs }
+
+// collect records err and returns nil with OptionCollectErrors, err otherwise.
+func (c *config) collect(err error) error {
+	if !c.options.collect {
+		return err
+	}
+	c.errs = append(c.errs, err)
+	return nil
+}
+
+// fieldError returns a FieldError for the config item name.
+func (c *config) fieldError(name string, src Source, key string, err error) error {
+	keys := append(c.cmds[:len(c.cmds):len(c.cmds)], strings.Split(name, c.options.gsep)...)
+	return &FieldError{strings.Join(keys, "."), src, key, err}
+}
+
+// setField sets the field to v and returns a ParseError on failure.
+func setField(field *structs.StructField, v interface{}, raw string) error {
+	if err := field.Set(v); err != nil {
+		if isSecret(field) {
+			raw = RedactedValue
+		}
+		return &ParseError{raw, err}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package construct_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+)
+
+func TestFieldError(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(name, []byte(`{"DB": {"Port": "x"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		label string
+		opts  []construct.Option
+		file  string
+		want  construct.FieldError
+		msg   string
+	}{
+		{"env", []construct.Option{
+			construct.OptionEnvPrefix("APP"),
+			construct.OptionEnv(map[string]string{"APP_DB_PORT": "y"}),
+		}, "", construct.FieldError{Path: "DB.Port", Source: construct.SourceEnv, Key: "APP_DB_PORT"}, "env APP_DB_PORT: "},
+		{"file", nil, name, construct.FieldError{Path: "DB.Port", Source: construct.SourceFile}, "DB.Port: "},
+	} {
+		t.Run(tc.label, func(t *testing.T) {
+			c := cfgStrict{}
+			c.Name = tc.file
+			err := construct.LoadArgs(&c, nil, tc.opts...)
+			var ferr *construct.FieldError
+			if !errors.As(err, &ferr) {
+				t.Fatalf("got %v; expected a FieldError", err)
+			}
+			if ferr.Path != tc.want.Path || ferr.Source != tc.want.Source || ferr.Key != tc.want.Key {
+				t.Errorf("got %s %v %s; expected %s %v %s",
+					ferr.Path, ferr.Source, ferr.Key, tc.want.Path, tc.want.Source, tc.want.Key)
+			}
+			if msg := err.Error(); !strings.HasPrefix(msg, tc.msg) {
+				t.Errorf("got %q; expected prefix %q", msg, tc.msg)
+			}
+			var perr *construct.ParseError
+			if !errors.As(err, &perr) {
+				t.Errorf("got %v; expected a ParseError", err)
+			}
+		})
+	}
+}
+
+func TestUnknownKeyError(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(name, []byte(`{"DB": {"Hots": "x"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgStrict{}
+	c.Name = name
+	err := construct.LoadArgs(&c, nil, construct.OptionStrictIO(nil))
+	var uerr *construct.UnknownKeyError
+	if !errors.As(err, &uerr) {
+		t.Fatalf("got %v; expected an UnknownKeyError", err)
+	}
+	if len(uerr.Keys) != 1 || uerr.Keys[0] != "DB.Hots" {
+		t.Errorf("got %v; expected [DB.Hots]", uerr.Keys)
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(name, []byte(`{"DB": {"Port": "x"}, "Levl": "debug"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgStrict{}
+	c.Name = name
+	err := construct.LoadArgs(&c, nil,
+		construct.OptionCollectErrors(),
+		construct.OptionStrictIO(nil),
+		construct.OptionEnvPrefix("APP"),
+		construct.OptionEnv(map[string]string{"APP_DB_PORT": "y"}))
+	var merr *construct.MultiError
+	if !errors.As(err, &merr) {
+		t.Fatalf("got %v; expected a MultiError", err)
+	}
+	var sources []construct.Source
+	for _, err := range merr.Errors() {
+		if ferr, ok := err.(*construct.FieldError); ok {
+			sources = append(sources, ferr.Source)
+		}
+	}
+	// The DB.Port value of the file is shadowed by the environment variable.
+	want := []construct.Source{construct.SourceEnv}
+	if !reflect.DeepEqual(sources, want) {
+		t.Errorf("got %v; expected %v", sources, want)
+	}
+	var uerr *construct.UnknownKeyError
+	if !errors.As(err, &uerr) {
+		t.Errorf("got %v; expected an UnknownKeyError", err)
+	}
+	if n := len(merr.Errors()); n != 2 {
+		t.Errorf("got %d errors; expected 2", n)
+	}
+}
+
+func TestUnknownFlagError(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want construct.UnknownFlagError
+		msg  string
+	}{
+		{[]string{"--verbos"}, construct.UnknownFlagError{Flag: "--verbos", Suggestions: []string{"--verbose"}},
+			"unknown flag: --verbos, did you mean --verbose?"},
+		{[]string{"--db-prot=1"}, construct.UnknownFlagError{Flag: "--db-prot", Suggestions: []string{"--db-port"}},
+			"unknown flag: --db-prot, did you mean --db-port?"},
+		{[]string{"--xyz"}, construct.UnknownFlagError{Flag: "--xyz"}, "unknown flag: --xyz"},
+	} {
+		var c cfgUsageTemplate
+		err := construct.LoadArgs(&c, tc.args, construct.OptionFlagsWriter(io.Discard))
+		var ferr *construct.UnknownFlagError
+		if !errors.As(err, &ferr) {
+			t.Fatalf("%v: got %v; expected an UnknownFlagError", tc.args, err)
+		}
+		if !reflect.DeepEqual(*ferr, tc.want) || err.Error() != tc.msg {
+			t.Errorf("%v: got %+v %q; expected %+v %q", tc.args, *ferr, err, tc.want, tc.msg)
+		}
+	}
+}
+
+func TestUnknownCommandError(t *testing.T) {
+	for _, args := range [][]string{{"dploy"}, {"help", "dploy"}} {
+		var c cfgUsageTemplate
+		err := construct.LoadArgs(&c, args,
+			construct.OptionFlagsWriter(io.Discard),
+			construct.OptionStrictCommands())
+		var cerr *construct.UnknownCommandError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("%v: got %v; expected an UnknownCommandError", args, err)
+		}
+		if msg := "unknown command: dploy, did you mean deploy?"; err.Error() != msg {
+			t.Errorf("%v: got %q; expected %q", args, err, msg)
+		}
+	}
+
+	// Positional arguments are accepted by default, even if close to a subcommand.
+	for _, arg := range []string{"file.txt", "dploy"} {
+		var c cfgUsageTemplate
+		if err := construct.LoadArgs(&c, []string{arg}); err != nil {
+			t.Fatalf("%s: %v", arg, err)
+		}
+	}
+
+	var c cfgUsageTemplate
+	err := construct.LoadArgs(&c, []string{"file.txt"},
+		construct.OptionFlagsWriter(io.Discard),
+		construct.OptionStrictCommands())
+	if msg := "unknown command: file.txt"; err == nil || err.Error() != msg {
+		t.Errorf("got %v; expected %q", err, msg)
+	}
+}
@@ -0,0 +1,120 @@
+package construct
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pierrec/construct/internal/structs"
+	"github.com/pkg/errors"
+)
+
+// expandValue expands the environment variables referenced in v if it is a
+// string or a list of strings.
+func (c *config) expandValue(v interface{}) (interface{}, error) {
+	switch w := v.(type) {
+	case string:
+		return c.expandEnv(w)
+	case []interface{}:
+		var res []interface{}
+		for i, item := range w {
+			s, ok := item.(string)
+			if !ok {
+				continue
+			}
+			xs, err := c.expandEnv(s)
+			if err != nil {
+				return nil, err
+			}
+			if xs == s {
+				continue
+			}
+			if res == nil {
+				res = append([]interface{}(nil), w...)
+			}
+			res[i] = xs
+		}
+		if res == nil {
+			return v, nil
+		}
+		return res, nil
+	}
+	return v, nil
+}
+
+// expandEnv replaces $VAR and ${VAR} in s by the value of the environment
+// variable VAR and $$ by $.
+func (c *config) expandEnv(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		ref := s[i:]
+		var name string
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+			continue
+		case '{':
+			end := strings.IndexByte(ref, '}')
+			if end < 0 {
+				return "", errors.Errorf("unterminated variable reference in %q", s)
+			}
+			name, ref = ref[2:end], ref[:end+1]
+			if name == "" || envNameLen(name) != len(name) {
+				return "", errors.Errorf("invalid variable reference %s", ref)
+			}
+		default:
+			n := envNameLen(ref[1:])
+			if n == 0 {
+				// Not a reference.
+				b.WriteByte('$')
+				continue
+			}
+			name, ref = ref[1:1+n], ref[:1+n]
+		}
+		i += len(ref) - 1
+		v, ok := c.lookupEnv(name)
+		if !ok {
+			if c.options.xstrict {
+				return "", errors.Errorf("undefined environment variable %s", name)
+			}
+			v = ref
+		}
+		b.WriteString(v)
+	}
+	return b.String(), nil
+}
+
+// envNameLen returns the length of the environment variable name at the start of s.
+func envNameLen(s string) int {
+	for i, r := range s {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case '0' <= r && r <= '9' && i > 0:
+		default:
+			return i
+		}
+	}
+	return len(s)
+}
+
+// unexpandedValue returns the value of the config item identified by keys before
+// the expansion of its environment variables, if its value was not modified since.
+func (c *config) unexpandedValue(field *structs.StructField, keys []string) (interface{}, bool) {
+	name := strings.Join(keys, c.options.gsep)
+	raw, ok := c.unexpanded[name]
+	if !ok || c.sources[name] != SourceFile {
+		return nil, false
+	}
+	v, err := field.MarshalValue()
+	if err != nil || fmt.Sprintf("%v", v) != c.raws[name] {
+		return nil, false
+	}
+	return raw, true
+}
@@ -0,0 +1,77 @@
+package construct
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pierrec/construct/internal/dotenv"
+	"github.com/pkg/errors"
+)
+
+// envFile is a dotenv file set with OptionEnvFile.
+type envFile struct {
+	name     string
+	required bool
+}
+
+// loadEnvFiles reads the variables defined in the env files.
+func (c *config) loadEnvFiles() error {
+	if len(c.options.envfiles) == 0 {
+		return nil
+	}
+	envs := make(map[string]string)
+	for _, ef := range c.options.envfiles {
+		f, err := os.Open(ef.name)
+		if err != nil {
+			if os.IsNotExist(err) && !ef.required {
+				continue
+			}
+			return err
+		}
+		items, err := dotenv.Parse(f)
+		f.Close()
+		if err != nil {
+			return errors.Errorf("%s: %v", ef.name, err)
+		}
+		for _, item := range items {
+			envs[item.Key] = item.Value
+		}
+	}
+	c.options.envs = envs
+	return nil
+}
+
+// lookupEnv returns the value of the environment variable, which is looked up
+// in the environment first, or with the OptionEnvFunc function, then in the env files.
+func (c *config) lookupEnv(name string) (string, bool) {
+	lookup := os.LookupEnv
+	if c.options.envfunc != nil {
+		lookup = c.options.envfunc
+	}
+	if v, ok := lookup(name); ok {
+		return v, true
+	}
+	v, ok := c.options.envs[name]
+	return v, ok
+}
+
+// envPrefix implements FromEnv for OptionEnvPrefix.
+type envPrefix struct {
+	from   FromEnv // FromEnv source of the config, if any.
+	prefix string
+	gsep   string
+	envsep string
+}
+
+func (e *envPrefix) Env(name string) string {
+	if e.from != nil {
+		if env := e.from.Env(name); env != "" {
+			return env
+		}
+	}
+	env := strings.ToUpper(strings.Replace(name, e.gsep, e.envsep, -1))
+	if e.prefix == "" {
+		return env
+	}
+	return e.prefix + e.envsep + env
+}
@@ -0,0 +1,81 @@
+package construct_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+)
+
+type cfgEnvFiles struct {
+	Host  string
+	Port  int
+	Debug bool
+}
+
+func (*cfgEnvFiles) Init() error              { return nil }
+func (*cfgEnvFiles) Usage(name string) string { return "" }
+func (*cfgEnvFiles) Env(name string) string   { return "ENVF_" + strings.ToUpper(name) }
+
+func TestEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	env := filepath.Join(dir, ".env")
+	local := filepath.Join(dir, ".env.local")
+	if err := os.WriteFile(env, []byte("ENVF_HOST=envhost\nENVF_PORT=80\nENVF_DEBUG=true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(local, []byte("export ENVF_PORT=8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ENVF_DEBUG", "false")
+
+	var c cfgEnvFiles
+	err := construct.LoadArgs(&c, nil,
+		construct.OptionEnvFile(env, true),
+		construct.OptionEnvFile(local, false),
+		construct.OptionEnvFile(filepath.Join(dir, "missing"), false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (cfgEnvFiles{"envhost", 8080, false}); c != want {
+		t.Errorf("got %+v; expected %+v", c, want)
+	}
+
+	err = construct.LoadArgs(&c, nil, construct.OptionEnvFile(filepath.Join(dir, "missing"), true))
+	if err == nil {
+		t.Error("expected error on missing required env file")
+	}
+}
+
+type cfgEnvPrefix struct {
+	RemoteDB `cfg:"DB"`
+	Level    string
+}
+
+func (*cfgEnvPrefix) Init() error              { return nil }
+func (*cfgEnvPrefix) Usage(name string) string { return "" }
+
+func TestEnvPrefix(t *testing.T) {
+	t.Setenv("MYAPP_DB_PORT", "5432")
+	t.Setenv("MYAPP_LEVEL", "debug")
+	t.Setenv("DB_HOST", "unprefixed")
+
+	var c cfgEnvPrefix
+	if err := construct.LoadArgs(&c, nil, construct.OptionEnvPrefix("MYAPP")); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 5432 || c.Level != "debug" || c.Host != "" {
+		t.Errorf("got %+v", c)
+	}
+
+	c = cfgEnvPrefix{}
+	if err := construct.LoadArgs(&c, nil, construct.OptionEnvPrefix("")); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "unprefixed" {
+		t.Errorf("got %+v", c)
+	}
+}
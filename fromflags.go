@@ -1,12 +1,13 @@
 package construct
 
 import (
+	stdflag "flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"reflect"
+	"strconv"
 	"strings"
-	"text/tabwriter"
 	"time"
 
 	"github.com/pierrec/construct/internal/structs"
@@ -14,14 +15,158 @@ import (
 	flag "github.com/spf13/pflag"
 )
 
+// interspersed returns whether flags may follow the positional arguments.
+// Unless set by OptionFlagsInterspersed, it is the case if the config has no subcommand.
+// It is never the case with the flag package.
+func (c *config) interspersed() bool {
+	if c.options.stdflags {
+		return false
+	}
+	if c.options.mixflags != nil {
+		return *c.options.mixflags
+	}
+	return !c.hasCommands()
+}
+
+// hasCommands returns whether or not the config has subcommands.
+func (c *config) hasCommands() bool {
+	for _, field := range c.root.Fields() {
+		if cmd, _ := getCommand(field); cmd != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// flagPrefix returns the prefix of the command line flags names.
+func (c *config) flagPrefix() string {
+	if c.options.stdflags {
+		return "-"
+	}
+	return "--"
+}
+
+// run invokes FlagsDone on the last command, preceded by the PreRun methods of
+// the commands from the main one and followed by the PostRun methods in reverse
+// order. The PostRun method of a command is not invoked if its PreRun failed,
+// nor the ones of its subcommands.
+func (c *config) run(from FromFlags) (err error) {
+	cmds := append(c.prev[:len(c.prev):len(c.prev)], c.raw)
+	args := c.args
+	n := 0
+	for ; n < len(cmds); n++ {
+		if pre, ok := cmds[n].(PreRunner); ok {
+			if err = pre.PreRun(cmds, args); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = from.FlagsDone(c.prev, args)
+	}
+	for n--; n >= 0; n-- {
+		if post, ok := cmds[n].(PostRunner); ok {
+			err = post.PostRun(cmds, err)
+		}
+	}
+	return err
+}
+
+// hasHelpCommand returns whether the help command is available, i.e. the config
+// has subcommands and none of them is named help.
+// The command name cannot be told apart from the positional arguments if
+// flags are interspersed.
+func (c *config) hasHelpCommand() bool {
+	if c.interspersed() || !c.hasCommands() {
+		return false
+	}
+	s, _, err := c.lookupCommand("help")
+	return s == nil && err == nil
+}
+
+// helpCommand writes the usage of the subcommand identified by its path in names,
+// or of the config if there is none.
+func (c *config) helpCommand(names []string) error {
+	conf := c
+	for _, name := range names {
+		s, sc, err := conf.lookupCommand(name)
+		if err != nil {
+			return err
+		}
+		if s == nil {
+			err := &UnknownCommandError{name, suggest(name, conf.commandNames())}
+			return conf.options.fusage(err, conf.buildFlagsUsage())
+		}
+		conf = newConfigFromStruct(s, sc, conf)
+		if err := conf.buildKeys(conf.root.Fields(), "", false); err != nil {
+			return err
+		}
+		if err := conf.buildFlags("", conf.root); err != nil {
+			return err
+		}
+	}
+	return conf.options.fusage(nil, conf.buildFlagsUsage())
+}
+
+// parseStdlib parses args with a FlagSet of the standard flag package defining
+// the same flags as c.fs, their values being set on the flags of c.fs.
+func (c *config) parseStdlib(args []string) error {
+	sfs := stdflag.NewFlagSet("", stdflag.ContinueOnError)
+	// Disable the output on error.
+	sfs.SetOutput(ioutil.Discard)
+	sfs.Usage = func() {}
+	c.fs.VisitAll(func(f *flag.Flag) {
+		sfs.Var(&stdlibValue{f}, f.Name, f.Usage)
+	})
+	if err := sfs.Parse(args); err != nil {
+		return err
+	}
+	c.args = sfs.Args()
+	return nil
+}
+
+// stdlibValue implements the flag.Value of the standard flag package for a
+// pflag flag, which is marked as changed once set.
+type stdlibValue struct {
+	f *flag.Flag
+}
+
+func (v *stdlibValue) String() string {
+	if v.f == nil {
+		// Zero value used by the flag package to print the defaults.
+		return ""
+	}
+	return v.f.Value.String()
+}
+
+func (v *stdlibValue) Set(s string) error {
+	if v.IsBoolFlag() && s == "true" {
+		// Flag set without value, e.g. a count flag.
+		s = v.f.NoOptDefVal
+	}
+	if err := v.f.Value.Set(s); err != nil {
+		return err
+	}
+	v.f.Changed = true
+	return nil
+}
+
+// IsBoolFlag makes the flag package accept the flag without value if
+// pflag does, e.g. -verbose for a bool flag.
+func (v *stdlibValue) IsBoolFlag() bool {
+	return v.f.NoOptDefVal != ""
+}
+
 func (c *config) buildFlags(section string, root *structs.StructStruct) error {
 	if c.fs == nil {
 		c.fs = flag.NewFlagSet("", flag.ContinueOnError)
 		// Disable the output on error.
 		c.fs.SetOutput(ioutil.Discard)
 		// Make sure the parsing stops when a command is found.
-		c.fs.SetInterspersed(false)
+		c.fs.SetInterspersed(c.interspersed())
 		c.refs = make(map[string]interface{})
+		c.negs = make(map[string]string)
+		c.fitems = make(map[string]string)
 	}
 
 	config, ok := root.Interface().(Config)
@@ -30,6 +175,16 @@ func (c *config) buildFlags(section string, root *structs.StructStruct) error {
 		return nil
 	}
 	from, isFlags := root.Interface().(FromFlags)
+	replaced := make(map[string]string)
+	var hidden []string
+	defer func() {
+		for fname, msg := range replaced {
+			c.fs.MarkDeprecated(fname, msg)
+		}
+		for _, fname := range hidden {
+			c.fs.Lookup(fname).Hidden = true
+		}
+	}()
 
 	for _, field := range root.Fields() {
 		if c, _ := getCommand(field); c != nil {
@@ -45,148 +200,246 @@ func (c *config) buildFlags(section string, root *structs.StructStruct) error {
 			}
 			continue
 		}
+		if isSensitive(field) {
+			// Sensitive items must not be visible from the command line.
+			continue
+		}
 		name := c.toName(section, field)
+		fname := c.flagName(name)
+		c.fitems[fname] = strings.ToLower(name)
+		usage := config.Usage(field.Name())
+		var short string
+		if isFlags && !c.options.stdflags {
+			short = from.FlagsShort(field.Name())
+			short = strings.ToLower(short)
+		}
+
+		if _, ok := field.Flag("hidden"); ok {
+			// Hide the flag once defined.
+			hidden = append(hidden, fname)
+		}
+		by, deprecated := field.Flag("deprecated")
+		if deprecated {
+			// Hide the flag once defined.
+			replaced[fname] = "use " + c.flagPrefix() + c.flagName(c.replacedName(name, by))
+		}
+
+		if ptr := reflect.New(field.Type()); ptr.Type().Implements(flagValueType) {
+			// Values set themselves from the command line.
+			ptr.Elem().Set(reflect.ValueOf(field.Interface()))
+			c.fs.VarP(ptr.Interface().(flag.Value), fname, short, usage)
+			c.refs[fname] = ptr.Interface()
+			continue
+		}
+
+		if isCompositeField(field) {
+			// Each flag occurrence adds items to the slice or map.
+			value := newCompositeValue(field)
+			c.fs.VarP(value, fname, short, usage)
+			c.refs[fname] = value.value.Addr().Interface()
+			continue
+		}
+
+		if t := field.Type(); field.IsPointer() && t.Elem().Kind() == reflect.Struct && !structs.IsScalar(t.Elem()) {
+			// Pointers to structs are only set from FromIO sources.
+			continue
+		}
 
 		// Convert lower types.
 		v, err := field.MarshalValue()
 		if err != nil {
 			return errors.Errorf("field %s: %v", name, err)
 		}
-		lname := strings.ToLower(name)
-		usage := config.Usage(field.Name())
-		var short string
-		if isFlags {
-			short = from.FlagsShort(field.Name())
-			short = strings.ToLower(short)
+		switch reflect.TypeOf(field.Indirect()).Kind() {
+		case reflect.Float32, reflect.Float64:
+			// Floats are marshaled as strings.
+			v = reflect.ValueOf(field.Indirect()).Float()
+		}
+
+		if _, ok := field.Flag("count"); ok {
+			switch v.(type) {
+			case int64, uint64:
+			default:
+				return errors.Errorf("field %s: count flag on non integer type %T", name, v)
+			}
+			// Each flag occurrence increments the value, starting from 0.
+			c.refs[fname] = c.fs.CountP(fname, short, usage)
+			continue
 		}
 
 		// Assign flags and keep track of the pointers of the set value.
 		var ref interface{}
 		switch w := v.(type) {
 		case bool:
-			ref = c.fs.BoolP(lname, short, w, usage)
+			ref = c.fs.BoolP(fname, short, w, usage)
+			if _, ok := field.Flag("explicit"); ok {
+				// Require a value instead of setting the flag to true.
+				c.fs.Lookup(fname).NoOptDefVal = ""
+			}
+			if c.options.negflags && !deprecated {
+				c.negateFlag(fname, usage)
+			}
 		case time.Duration:
-			ref = c.fs.DurationP(lname, short, w, usage)
+			ref = c.fs.DurationP(fname, short, w, usage)
 		case float64:
-			ref = c.fs.Float64P(lname, short, w, usage)
+			ref = c.fs.Float64P(fname, short, w, usage)
 		case int:
-			ref = c.fs.IntP(lname, short, w, usage)
+			ref = c.fs.IntP(fname, short, w, usage)
 		case int64:
-			ref = c.fs.Int64P(lname, short, w, usage)
+			ref = c.fs.Int64P(fname, short, w, usage)
 		case string:
-			ref = c.fs.StringP(lname, short, w, usage)
+			ref = c.fs.StringP(fname, short, w, usage)
 		case uint:
-			ref = c.fs.UintP(lname, short, w, usage)
+			ref = c.fs.UintP(fname, short, w, usage)
 		case uint64:
-			ref = c.fs.Uint64P(lname, short, w, usage)
+			ref = c.fs.Uint64P(fname, short, w, usage)
 		}
-		c.refs[lname] = ref
+		c.refs[fname] = ref
 	}
 
 	return nil
 }
 
 func (c *config) buildFlagsUsage() func(io.Writer) error {
-	type subcommand struct {
-		s *structs.StructStruct
-		c Config
+	return func(out io.Writer) error {
+		u := c.flagsUsage()
+		if tmpl := c.options.utmpl; tmpl != nil {
+			return tmpl.Execute(out, u)
+		}
+		return u.write(out)
 	}
-	var subcommands []subcommand
+}
 
-	for _, field := range c.root.Fields() {
-		s, c := getCommand(field)
-		if s != nil {
-			subcommands = append(subcommands, subcommand{s, c})
-		}
+// flagDefault returns the formatted default value of the flag,
+// or an empty string if it is not set or must not be disclosed.
+func (c *config) flagDefault(fname string) string {
+	field := c.root.Lookup(c.fromNameAll(c.flagItem(fname), c.options.gsep)...)
+	if field == nil || isSecret(field) {
+		return ""
 	}
+	if reflect.ValueOf(field.Interface()).IsZero() {
+		return ""
+	}
+	v, err := field.MarshalValue()
+	if err != nil {
+		return ""
+	}
+	if reflect.TypeOf(field.Indirect()).Kind() == reflect.String {
+		return fmt.Sprintf("%q", v)
+	}
+	return fmt.Sprintf("%v", v)
+}
 
-	return func(out io.Writer) (err error) {
-		// Main usage.
-		if usage := c.raw.Usage(""); usage != "" {
-			_, err = fmt.Fprintf(out, "%s\n\n", usage)
-			if err != nil {
-				return err
-			}
-		}
-		_, err = fmt.Fprintf(out, "Options:\n")
-		if err != nil {
-			return err
-		}
+var flagValueType = reflect.TypeOf((*flag.Value)(nil)).Elem()
 
-		tabw := tabwriter.NewWriter(out, 8, 0, 1, ' ', 0)
-		c.fs.VisitAll(func(f *flag.Flag) {
-			if err != nil {
+// negateFlag defines the hidden --no-<fname> flag negating the bool flag fname,
+// unless a flag with that name already exists.
+func (c *config) negateFlag(fname, usage string) {
+	neg := "no-" + fname
+	if c.fs.Lookup(neg) != nil {
+		return
+	}
+	c.refs[neg] = c.fs.Bool(neg, false, usage)
+	c.fs.Lookup(neg).Hidden = true
+	c.negs[neg] = fname
+}
+
+// isNegated returns whether or not the bool flag fname has a negative flag.
+func (c *config) isNegated(fname string) bool {
+	_, ok := c.negs["no-"+fname]
+	return ok
+}
+
+// The flags that have been updated are removed from the map.
+// Flags of config items already set by a source with a higher priority are ignored.
+func (c *config) updateFlags() (err error) {
+	items := c.items()
+	c.fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || !f.Changed {
+			return
+		}
+		if fname, ok := c.negs[f.Name]; ok {
+			if c.fs.Changed(fname) {
+				p := c.flagPrefix()
+				err = errors.Errorf("flags %s%s and %s%s are mutually exclusive", p, fname, p, f.Name)
 				return
 			}
-			if f.Usage == "" {
-				// Hidden flag.
+			lname := c.flagItem(fname)
+			name, ok := items[lname]
+			if !ok {
 				return
 			}
-
-			refv := c.refs[f.Name]
-			v := reflect.ValueOf(refv).Elem().Interface()
-			short := f.Shorthand
-			if short != "" {
-				short = "-" + short + ", "
-			}
-			switch v.(type) {
-			case bool:
-				_, err = fmt.Fprintf(tabw, " %s\t--%s\t", short, f.Name)
-			default:
-				_, err = fmt.Fprintf(tabw, " %s\t--%s\t%T", short, f.Name, v)
-			}
-			if err == nil {
-				_, err = fmt.Fprintf(tabw, "\t%s\n", f.Usage)
-			}
-		})
-		if err != nil {
-			return err
-		}
-		if err = tabw.Flush(); err != nil {
-			return err
-		}
-
-		// Subcommands.
-		if len(subcommands) > 0 {
-			_, err = fmt.Fprintf(out, "\nCommands:\n")
+			field := c.root.Lookup(strings.Split(name, c.options.gsep)...)
+			v := !*c.refs[f.Name].(*bool)
+			err = c.setItem(lname, name, field, v, SourceFlags, strconv.FormatBool(v))
 			if err != nil {
-				return err
-			}
-			for _, sub := range subcommands {
-				usage := sub.c.Usage("")
-				if usage == "" {
-					// Hidden command.
-					continue
-				}
-				cmd := strings.ToLower(sub.s.Name())
-				_, err = fmt.Fprintf(tabw, "\t%s\t%s\n", cmd, usage)
-				if err != nil {
-					return err
-				}
+				err = c.collect(c.fieldError(name, SourceFlags, f.Name, err))
 			}
+			return
 		}
-
-		return tabw.Flush()
-	}
-}
-
-// The flags that have been updated are removed from the map.
-func (c *config) updateFlags() (err error) {
-	c.fs.Visit(func(f *flag.Flag) {
-		if err != nil {
+		lname := c.flagItem(f.Name)
+		name, ok := items[lname]
+		if !ok {
 			return
 		}
-		names := c.fromNameAll(f.Name, c.options.gsep)
-		field := c.root.Lookup(names...)
+		field := c.root.Lookup(strings.Split(name, c.options.gsep)...)
 
 		// Cached references are pointers to the flag set value.
 		refv := c.refs[f.Name]
 		v := reflect.ValueOf(refv).Elem().Interface()
-		err = field.Set(v)
+		err = c.setItem(lname, name, field, v, SourceFlags, f.Value.String())
 		if err != nil {
-			err = errors.Errorf("flag %s: %v", f.Name, err)
+			err = c.collect(c.fieldError(name, SourceFlags, f.Name, err))
 		}
-		delete(c.trans, f.Name)
 	})
 	return
 }
+
+// isCompositeField returns whether the field is a slice or a map.
+func isCompositeField(field *structs.StructField) bool {
+	t := field.Type()
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map:
+		return structs.SeparatorsLen(t) > 0
+	}
+	return false
+}
+
+// compositeValue implements pflag.Value for slice and map fields.
+// The first flag occurrence replaces the default value and subsequent
+// ones add items to the slice or the map.
+// Each occurrence may define multiple items using the field separators,
+// e.g. --hosts a,b or --labels k1:v1,k2:v2.
+type compositeValue struct {
+	value   reflect.Value
+	seps    []rune
+	changed bool
+}
+
+func newCompositeValue(field *structs.StructField) *compositeValue {
+	t := field.Type()
+	value := reflect.New(t).Elem()
+	value.Set(reflect.ValueOf(field.Interface()))
+	return &compositeValue{value: value, seps: field.Separators()}
+}
+
+func (v *compositeValue) Set(s string) error {
+	if !v.changed {
+		v.value.Set(reflect.Zero(v.value.Type()))
+		v.changed = true
+	}
+	// Slices and maps are appended to.
+	return structs.UnmarshalValue(v.value, s, v.seps)
+}
+
+func (v *compositeValue) String() string {
+	s, err := structs.MarshalValue(v.value.Interface(), v.seps)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", s)
+}
+
+func (v *compositeValue) Type() string {
+	return v.value.Type().String()
+}
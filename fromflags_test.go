@@ -0,0 +1,554 @@
+package construct_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type cfgSliceFlags struct {
+	TTL  []time.Duration
+	Size []constructs.BytesSize
+}
+
+func (*cfgSliceFlags) Init() error                                            { return nil }
+func (*cfgSliceFlags) Usage(name string) string                               { return "" }
+func (*cfgSliceFlags) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgSliceFlags) FlagsShort(name string) string                          { return "" }
+
+func TestSliceFlags(t *testing.T) {
+	c := cfgSliceFlags{TTL: []time.Duration{time.Hour}}
+	args := []string{"--ttl", "30s", "--ttl", "1m", "--size", "10MB", "--size=1KB"}
+	if err := construct.LoadArgs(&c, args); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.TTL, []time.Duration{30 * time.Second, time.Minute}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; expected %v", got, want)
+	}
+	if got, want := c.Size, []constructs.BytesSize{10e6, 1e3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; expected %v", got, want)
+	}
+
+	// Default values are kept if the flag is not set.
+	c = cfgSliceFlags{TTL: []time.Duration{time.Hour}}
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.TTL, []time.Duration{time.Hour}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; expected %v", got, want)
+	}
+}
+
+type cfgExplicitFlags struct {
+	Force   bool `cfg:",explicit"`
+	Verbose bool
+}
+
+func (*cfgExplicitFlags) Init() error                                            { return nil }
+func (*cfgExplicitFlags) Usage(name string) string                               { return "" }
+func (*cfgExplicitFlags) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgExplicitFlags) FlagsShort(name string) string                          { return "" }
+
+func TestExplicitBoolFlags(t *testing.T) {
+	opt := construct.OptionFlagsUsage(func(err error, _ func(io.Writer) error) error {
+		return err
+	})
+
+	var c cfgExplicitFlags
+	if err := construct.LoadArgs(&c, []string{"--force"}, opt); err == nil {
+		t.Error("expected error on explicit flag without value")
+	}
+
+	c = cfgExplicitFlags{}
+	if err := construct.LoadArgs(&c, []string{"--force=true", "--verbose"}, opt); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Force || !c.Verbose {
+		t.Errorf("got %+v; expected both flags set", c)
+	}
+}
+
+type cfgCompositeFlags struct {
+	Hosts  []string
+	Ports  []int
+	Labels map[string]string
+}
+
+func (*cfgCompositeFlags) Init() error                                            { return nil }
+func (*cfgCompositeFlags) Usage(name string) string                               { return "" }
+func (*cfgCompositeFlags) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgCompositeFlags) FlagsShort(name string) string                          { return "" }
+
+func TestCompositeFlags(t *testing.T) {
+	c := cfgCompositeFlags{Labels: map[string]string{"default": "x"}}
+	args := []string{
+		"--hosts", "a,b,c",
+		"--ports", "1", "--ports", "2,3",
+		"--labels", "k:v,k2:v2", "--labels", "k3:v3",
+	}
+	if err := construct.LoadArgs(&c, args); err != nil {
+		t.Fatal(err)
+	}
+	want := cfgCompositeFlags{
+		Hosts:  []string{"a", "b", "c"},
+		Ports:  []int{1, 2, 3},
+		Labels: map[string]string{"k": "v", "k2": "v2", "k3": "v3"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v; expected %+v", c, want)
+	}
+}
+
+type cfgUsageDefaults struct {
+	Timeout  time.Duration
+	Host     string
+	Workers  int
+	Verbose  bool
+	Password string `cfg:",secret"`
+}
+
+func (*cfgUsageDefaults) Init() error                                            { return nil }
+func (*cfgUsageDefaults) Usage(name string) string                               { return "the " + name }
+func (*cfgUsageDefaults) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgUsageDefaults) FlagsShort(name string) string                          { return "" }
+
+func TestUsageDefaults(t *testing.T) {
+	var buf bytes.Buffer
+	opt := construct.OptionFlagsUsage(func(err error, usage func(io.Writer) error) error {
+		return usage(&buf)
+	})
+	c := cfgUsageDefaults{Timeout: 30 * time.Second, Host: "localhost", Password: "secret"}
+	if err := construct.LoadArgs(&c, []string{"--help"}, opt); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"the Timeout (default 30s)\n",
+		"the Host (default \"localhost\")\n",
+		"the Workers\n",
+		"the Verbose\n",
+		"the Password\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+type cfgCountFlags struct {
+	Verbose int  `cfg:",count"`
+	Quiet   uint `cfg:",count"`
+}
+
+func (*cfgCountFlags) Init() error                                            { return nil }
+func (*cfgCountFlags) Usage(name string) string                               { return "" }
+func (*cfgCountFlags) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgCountFlags) FlagsShort(name string) string                          { return name[:1] }
+
+func TestCountFlags(t *testing.T) {
+	var c cfgCountFlags
+	if err := construct.LoadArgs(&c, []string{"-vvv", "--quiet", "-q"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Verbose != 3 || c.Quiet != 2 {
+		t.Errorf("got %+v; expected 3 and 2", c)
+	}
+
+	c = cfgCountFlags{Verbose: 1}
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Verbose != 1 {
+		t.Errorf("got %d; expected the default value", c.Verbose)
+	}
+}
+
+func TestNegativeFlags(t *testing.T) {
+	opt := construct.OptionNegativeFlags(true)
+	c := cfgExplicitFlags{Verbose: true}
+	if err := construct.LoadArgs(&c, []string{"--no-verbose", "--no-force"}, opt); err != nil {
+		t.Fatal(err)
+	}
+	if c.Force || c.Verbose {
+		t.Errorf("got %+v; expected both flags unset", c)
+	}
+
+	uopt := construct.OptionFlagsUsage(func(err error, _ func(io.Writer) error) error {
+		return err
+	})
+	c = cfgExplicitFlags{}
+	err := construct.LoadArgs(&c, []string{"--verbose", "--no-verbose"}, opt, uopt)
+	if want := "flags --verbose and --no-verbose are mutually exclusive"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got %v; expected %q", err, want)
+	}
+	c = cfgExplicitFlags{}
+	err = construct.LoadArgs(&c, []string{"-verbose", "-no-verbose"}, opt, uopt, construct.OptionFlagsStdlib())
+	if want := "flags -verbose and -no-verbose are mutually exclusive"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got %v; expected %q", err, want)
+	}
+	if err := construct.LoadArgs(&c, []string{"--no-verbose"}, uopt); err == nil {
+		t.Error("expected an error without negative flags")
+	}
+}
+
+// hostPort implements pflag.Value.
+type hostPort struct {
+	Host string
+	Port int
+}
+
+func (hp *hostPort) String() string { return fmt.Sprintf("%s:%d", hp.Host, hp.Port) }
+func (hp *hostPort) Type() string   { return "host:port" }
+func (hp *hostPort) Set(s string) error {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return err
+	}
+	hp.Host = host
+	hp.Port, err = strconv.Atoi(port)
+	return err
+}
+
+type cfgValueFlags struct {
+	constructs.ConfigFileJSON
+	Listen  hostPort
+	Backend hostPort
+}
+
+func (*cfgValueFlags) Init() error                                            { return nil }
+func (*cfgValueFlags) Usage(name string) string                               { return "" }
+func (*cfgValueFlags) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgValueFlags) FlagsShort(name string) string                          { return "" }
+
+func TestValueFlags(t *testing.T) {
+	var c cfgValueFlags
+	c.Name = filepath.Join(t.TempDir(), "config.json")
+	c.ToSave = true
+	if err := os.WriteFile(c.Name, []byte(`{"Backend": "db:5432"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := construct.LoadArgs(&c, []string{"--listen", "localhost:80"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (hostPort{"localhost", 80}); c.Listen != want {
+		t.Errorf("got %v; expected %v", c.Listen, want)
+	}
+	if want := (hostPort{"db", 5432}); c.Backend != want {
+		t.Errorf("got %v; expected %v", c.Backend, want)
+	}
+
+	data, err := os.ReadFile(c.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"Listen": "localhost:80"`; !strings.Contains(string(data), want) {
+		t.Errorf("missing %s in saved config:\n%s", want, data)
+	}
+
+	opt := construct.OptionFlagsUsage(func(err error, _ func(io.Writer) error) error {
+		return err
+	})
+	c = cfgValueFlags{}
+	if err := construct.LoadArgs(&c, []string{"--listen", "invalid"}, opt); err == nil {
+		t.Error("expected an error on invalid value")
+	}
+}
+
+type cfgNetFlags struct {
+	IPs  []net.IP
+	MAC  net.HardwareAddr
+	Nets []*net.IPNet
+}
+
+func (*cfgNetFlags) Init() error                                            { return nil }
+func (*cfgNetFlags) Usage(name string) string                               { return "" }
+func (*cfgNetFlags) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgNetFlags) FlagsShort(name string) string                          { return "" }
+
+func TestNetFlags(t *testing.T) {
+	var c cfgNetFlags
+	args := []string{
+		"--ips", "10.0.0.1,::1", "--ips", "10.0.0.2",
+		"--mac", "00:11:22:33:44:55",
+		"--nets", "10.0.0.0/8", "--nets", "192.168.0.0/16",
+	}
+	if err := construct.LoadArgs(&c, args); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(c.IPs), "[10.0.0.1 ::1 10.0.0.2]"; got != want {
+		t.Errorf("got %s; expected %s", got, want)
+	}
+	if got, want := c.MAC.String(), "00:11:22:33:44:55"; got != want {
+		t.Errorf("got %s; expected %s", got, want)
+	}
+	if got, want := fmt.Sprint(c.Nets), "[10.0.0.0/8 192.168.0.0/16]"; got != want {
+		t.Errorf("got %s; expected %s", got, want)
+	}
+}
+
+type cfgResponseFlags struct {
+	Name string
+	Tag  []string
+}
+
+func (*cfgResponseFlags) Init() error                                            { return nil }
+func (*cfgResponseFlags) Usage(name string) string                               { return "" }
+func (*cfgResponseFlags) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgResponseFlags) FlagsShort(name string) string                          { return "" }
+
+func TestResponseFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"main.args":     "# Canned invocation.\n--name \"John \\\"Doe\\\"\"\n@sub/more.args\n",
+		"sub/more.args": "--tag 'a b' --tag c\\ d\n  # Indented comment.\n--tag #fff",
+		"loop.args":     "--tag x @loop.args",
+		"stop.args":     "--verbose -- @loop.args",
+	} {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var c cfgResponseFlags
+	args := []string{"@" + filepath.Join(dir, "main.args"), "--tag", "e"}
+	if err := construct.LoadArgs(&c, args, construct.OptionResponseFiles()); err != nil {
+		t.Fatal(err)
+	}
+	want := cfgResponseFlags{`John "Doe"`, []string{"a b", "c d", "#fff", "e"}}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v; expected %+v", c, want)
+	}
+
+	c = cfgResponseFlags{}
+	args = []string{"@" + filepath.Join(dir, "loop.args")}
+	err := construct.LoadArgs(&c, args, construct.OptionResponseFiles())
+	if err == nil || !strings.Contains(err.Error(), "includes itself") {
+		t.Errorf("got error %v; expected a recursion error", err)
+	}
+
+	// The -- argument of a response file stops the expansion of the next arguments.
+	var i cfgInterspersedFlags
+	args = []string{"@" + filepath.Join(dir, "stop.args"), "@loop.args"}
+	if err := construct.LoadArgs(&i, args, construct.OptionResponseFiles()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"@loop.args", "@loop.args"}; !i.Verbose || !reflect.DeepEqual(i.args, want) {
+		t.Errorf("got %v %v; expected true %v", i.Verbose, i.args, want)
+	}
+}
+
+type ResponseMail struct {
+	Tmpl string
+}
+
+func (*ResponseMail) Init() error                                            { return nil }
+func (*ResponseMail) Usage(name string) string                               { return "" }
+func (*ResponseMail) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*ResponseMail) FlagsShort(name string) string                          { return "t" }
+
+type cfgResponseCmd struct {
+	Name         string
+	ResponseMail `cfg:"mail"`
+}
+
+func (*cfgResponseCmd) Init() error                                            { return nil }
+func (*cfgResponseCmd) Usage(name string) string                               { return "" }
+func (*cfgResponseCmd) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgResponseCmd) FlagsShort(name string) string                          { return "" }
+
+func TestResponseFilesValues(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub.args")
+	if err := os.WriteFile(sub, []byte("--tmpl x.tmpl"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The flag values are not expanded.
+	var c cfgResponseFlags
+	args := []string{"--name", "@mail.tmpl", "--tag=@a", "--tag", "@b"}
+	if err := construct.LoadArgs(&c, args, construct.OptionResponseFiles()); err != nil {
+		t.Fatal(err)
+	}
+	want := cfgResponseFlags{"@mail.tmpl", []string{"@a", "@b"}}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v; expected %+v", c, want)
+	}
+
+	// Including the ones of the subcommands.
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"mail", "--tmpl", "@mail.tmpl"}, "@mail.tmpl"},
+		{[]string{"mail", "-t", "@mail.tmpl"}, "@mail.tmpl"},
+		{[]string{"--name", "n", "mail", "@" + sub}, "x.tmpl"},
+	} {
+		var c cfgResponseCmd
+		if err := construct.LoadArgs(&c, tc.args, construct.OptionResponseFiles()); err != nil {
+			t.Fatalf("%v: %v", tc.args, err)
+		}
+		if c.Tmpl != tc.want {
+			t.Errorf("%v: got %q; expected %q", tc.args, c.Tmpl, tc.want)
+		}
+	}
+}
+
+type cfgInterspersedFlags struct {
+	Verbose bool
+	args    []string
+}
+
+func (*cfgInterspersedFlags) Init() error              { return nil }
+func (*cfgInterspersedFlags) Usage(name string) string { return "" }
+func (c *cfgInterspersedFlags) FlagsDone(cmds []construct.Config, args []string) error {
+	c.args = args
+	return nil
+}
+func (*cfgInterspersedFlags) FlagsShort(name string) string { return "" }
+
+func TestInterspersedFlags(t *testing.T) {
+	for _, tc := range []struct {
+		options []construct.Option
+		verbose bool
+		args    []string
+	}{
+		{nil, true, []string{"a", "b"}},
+		{[]construct.Option{construct.OptionFlagsInterspersed(false)}, false, []string{"a", "--verbose", "b"}},
+	} {
+		var c cfgInterspersedFlags
+		if err := construct.LoadArgs(&c, []string{"a", "--verbose", "b"}, tc.options...); err != nil {
+			t.Fatal(err)
+		}
+		if c.Verbose != tc.verbose || !reflect.DeepEqual(c.args, tc.args) {
+			t.Errorf("got %v %v; expected %v %v", c.Verbose, c.args, tc.verbose, tc.args)
+		}
+	}
+}
+
+func TestStdlibFlags(t *testing.T) {
+	var c cfgResponseFlags
+	args := []string{"-name", "-x", "-tag=a", "--tag", "b"}
+	if err := construct.LoadArgs(&c, args, construct.OptionFlagsStdlib()); err != nil {
+		t.Fatal(err)
+	}
+	want := cfgResponseFlags{"-x", []string{"a", "b"}}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v; expected %+v", c, want)
+	}
+
+	// The parsing stops at the first positional argument.
+	var i cfgInterspersedFlags
+	if err := construct.LoadArgs(&i, []string{"a", "-verbose"}, construct.OptionFlagsStdlib()); err != nil {
+		t.Fatal(err)
+	}
+	if i.Verbose || !reflect.DeepEqual(i.args, []string{"a", "-verbose"}) {
+		t.Errorf("got %v %v; expected false [a -verbose]", i.Verbose, i.args)
+	}
+
+	// Flags without value are set as with the flag package.
+	var n cfgCountFlags
+	if err := construct.LoadArgs(&n, []string{"-verbose", "-verbose", "-quiet=3"}, construct.OptionFlagsStdlib()); err != nil {
+		t.Fatal(err)
+	}
+	if n.Verbose != 2 || n.Quiet != 3 {
+		t.Errorf("got %+v; expected verbose 2 and quiet 3", n)
+	}
+
+	var buf bytes.Buffer
+	err := construct.LoadArgs(&c, []string{"-nme", "x"}, construct.OptionFlagsStdlib(), construct.OptionFlagsWriter(&buf))
+	if e, ok := err.(*construct.UnknownFlagError); !ok || e.Flag != "-nme" {
+		t.Fatalf("got error %v; expected unknown flag -nme", err)
+	}
+
+	buf.Reset()
+	var h cfgHidden
+	err = construct.LoadArgs(&h, []string{"-h"}, construct.OptionFlagsStdlib(), construct.OptionFlagsWriter(&buf))
+	if err != construct.ErrHelp {
+		t.Fatalf("got error %v; expected ErrHelp", err)
+	}
+	if s := buf.String(); !strings.Contains(s, " -verbose") || strings.Contains(s, "--verbose") {
+		t.Errorf("invalid usage:\n%s", s)
+	}
+}
+
+type RunSub struct {
+	Name string
+	log  *[]string
+}
+
+func (*RunSub) Init() error              { return nil }
+func (*RunSub) Usage(name string) string { return "" }
+func (c *RunSub) FlagsDone(cmds []construct.Config, args []string) error {
+	*c.log = append(*c.log, "done")
+	if c.Name == "fail" {
+		return errors.New("failed")
+	}
+	return nil
+}
+func (*RunSub) FlagsShort(name string) string { return "" }
+func (c *RunSub) PostRun(cmds []construct.Config, err error) error {
+	*c.log = append(*c.log, "sub post")
+	return err
+}
+
+type cfgRun struct {
+	RunSub
+	Deny bool
+	log  []string
+}
+
+func (*cfgRun) Init() error              { return nil }
+func (*cfgRun) Usage(name string) string { return "" }
+func (*cfgRun) FlagsDone(cmds []construct.Config, args []string) error {
+	return errors.New("main invoked")
+}
+func (*cfgRun) FlagsShort(name string) string { return "" }
+func (c *cfgRun) PreRun(cmds []construct.Config, args []string) error {
+	c.log = append(c.log, fmt.Sprintf("pre %d %v", len(cmds), args))
+	if c.Deny {
+		return errors.New("denied")
+	}
+	return nil
+}
+func (c *cfgRun) PostRun(cmds []construct.Config, err error) error {
+	c.log = append(c.log, fmt.Sprintf("post %v", err))
+	return nil
+}
+
+func TestRunHooks(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		log  []string
+		err  string
+	}{
+		{[]string{"runsub", "x"}, []string{"pre 2 [x]", "done", "sub post", "post <nil>"}, ""},
+		{[]string{"runsub", "--name", "fail"}, []string{"pre 2 []", "done", "sub post", "post failed"}, ""},
+		{[]string{"--deny", "runsub"}, []string{"pre 2 []"}, "denied"},
+	} {
+		var c cfgRun
+		c.RunSub.log = &c.log
+		err := construct.LoadArgs(&c, tc.args)
+		if got := fmt.Sprint(err); err != nil && got != tc.err || err == nil && tc.err != "" {
+			t.Fatalf("%v: got error %v; expected %q", tc.args, err, tc.err)
+		}
+		if !reflect.DeepEqual(c.log, tc.log) {
+			t.Errorf("%v: got %q; expected %q", tc.args, c.log, tc.log)
+		}
+	}
+}
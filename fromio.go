@@ -1,7 +1,15 @@
 package construct
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pierrec/construct/internal/structs"
 	"github.com/pkg/errors"
@@ -39,34 +47,342 @@ type Store interface {
 	StructTag() string
 }
 
-func ioLoad(from FromIO, LookupFn LookupFn) (Store, error) {
-	if from == nil {
-		return nil, nil
+// TagStore is optionally implemented by Stores to customize the format of
+// config items based on their struct tag, which is supplied before their value is set.
+type TagStore interface {
+	// SetTag defines the struct tag for the given key.
+	SetTag(tag reflect.StructTag, keys ...string)
+}
+
+// KeysStore is optionally implemented by Stores able to list their keys.
+// It is required by OptionStrictIO.
+type KeysStore interface {
+	// Keys returns the keys of all the values in the store.
+	Keys() [][]string
+}
+
+// IONamer is optionally implemented by Configs with FromIO sources to set the
+// keys of the config items in their Stores, e.g. a single "server.port" key for
+// the Port item of the Server group instead of the Port key under the Server one.
+// The keys of the config items of the subcommands using the parent FromIO
+// sources are prefixed with the subcommands names.
+type IONamer interface {
+	// IOKeys returns the keys in the Stores of the config item identified by
+	// keys: its group names followed by its name.
+	// Return nil to use the default keys.
+	IOKeys(keys []string) []string
+}
+
+// CommentStore is optionally implemented by Stores keeping the comments read
+// from their source. These comments, typically written by hand, are preserved
+// when saving instead of being replaced by the config items usage.
+type CommentStore interface {
+	// Comment returns the comment read for the key, without the comment prefix.
+	Comment(keys ...string) string
+}
+
+// ioDiscarded reports whether the field is discarded by its noio tag flag
+// or the store struct tag.
+func ioDiscarded(store Store, field *structs.StructField) bool {
+	if _, ok := field.Flag("noio"); ok {
+		return true
+	}
+	key := field.Tag().Get(store.StructTag())
+	return len(key) > 0 && key[0] == '-'
+}
+
+// ioSet sets the value of the config item in the store, after its struct tag if supported.
+func ioSet(store Store, field *structs.StructField, v interface{}, keys ...string) error {
+	if value := reflect.ValueOf(v); field.IsPointer() && value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			// Unset config items are not stored.
+			return nil
+		}
+		v = value.Elem().Interface()
+	}
+	if _, ok := v.(time.Time); ok {
+		if _, ok := field.Flag("time"); ok {
+			// Format the time with its layout.
+			mv, err := field.MarshalValue()
+			if err != nil {
+				return err
+			}
+			v = mv
+		}
+	}
+	if ts, ok := store.(TagStore); ok {
+		ts.SetTag(field.Tag(), keys...)
+	}
+	return store.Set(v, keys...)
+}
+
+// FromIOContext is optionally implemented by FromIO sources supporting
+// cancellation, see LoadContext. LoadContext is then used instead of Load.
+type FromIOContext interface {
+	LoadContext(ctx context.Context) (io.ReadCloser, error)
+}
+
+// FromIOMerge is optionally implemented by FromIO sources made of several inputs
+// in the same format, such as config files found in multiple locations.
+// LoadAll is then used instead of Load.
+type FromIOMerge interface {
+	// LoadAll returns the sources for the data in order: the values of a source
+	// override the ones of the previous sources.
+	// Their Store must implement KeysStore.
+	LoadAll() ([]io.ReadCloser, error)
+}
+
+// FromIOMulti is implemented by configs loaded from several FromIO sources,
+// possibly in different formats, e.g. a system file, a user file and runtime
+// overrides. It takes precedence over FromIO.
+//
+// The config items not set by the command line flags, the environment variables
+// or the FromRemote source are set from the layered sources. Once loaded, the
+// config is saved to every source which Save method returns a destination.
+// Sensitive config items are only set if all the sources implement SecureIO.
+type FromIOMulti interface {
+	// IOSources returns the sources in order: the values of a source override
+	// the ones of the previous sources.
+	// Their Store must implement KeysStore.
+	IOSources() []FromIO
+}
+
+// layerStores returns the store made of the stores loaded from the sources froms,
+// the values of a store overriding the ones of the previous stores.
+// Missing stores are nil.
+func layerStores(froms []FromIO, stores []Store, lookup LookupFn) (Store, error) {
+	if len(stores) == 1 {
+		return stores[0], nil
 	}
-	src, err := from.Load()
+	var layered Store
+	for i, s := range stores {
+		if s == nil {
+			continue
+		}
+		if layered == nil {
+			layered = froms[i].New(lookup)
+		}
+		if err := mergeStore(layered, s, ""); err != nil {
+			return nil, err
+		}
+	}
+	return layered, nil
+}
+
+// SecureIO is optionally implemented by FromIO sources trusted with
+// sensitive config items, such as secrets backends.
+type SecureIO interface {
+	// Secure returns whether or not the source may provide sensitive config items.
+	Secure() bool
+}
+
+// isSecure returns whether or not the source is trusted with sensitive config items.
+func isSecure(from interface{}) bool {
+	s, ok := from.(SecureIO)
+	return ok && s.Secure()
+}
+
+// isSensitive returns whether or not the field is tagged as sensitive.
+func isSensitive(field *structs.StructField) bool {
+	_, ok := field.Flag("sensitive")
+	return ok
+}
+
+// isSecret returns whether or not the field value must not be disclosed,
+// i.e. it is tagged as secret, secretref or sensitive.
+func isSecret(field *structs.StructField) bool {
+	_, ok := field.Flag("secret")
+	_, ref := field.Flag("secretref")
+	return ok || ref || isSensitive(field)
+}
+
+// NewStoreFn is the function signature used to create a Store for a given format.
+type NewStoreFn func(lookup LookupFn) Store
+
+var stores = struct {
+	sync.RWMutex
+	m map[string]NewStoreFn
+}{m: make(map[string]NewStoreFn)}
+
+// RegisterStore makes a Store available under the given format name.
+// It panics if the format is already registered or if fn is nil.
+//
+// The constructs package registers its Stores when imported.
+func RegisterStore(format string, fn NewStoreFn) {
+	if fn == nil {
+		panic("construct: nil Store for format " + format)
+	}
+	stores.Lock()
+	defer stores.Unlock()
+	if _, ok := stores.m[format]; ok {
+		panic("construct: Store already registered for format " + format)
+	}
+	stores.m[format] = fn
+}
+
+// NewStore returns a new Store for the given registered format.
+func NewStore(format string, lookup LookupFn) (Store, error) {
+	fn, err := storeFn(format)
 	if err != nil {
 		return nil, err
 	}
-	if src == nil {
-		return nil, nil
+	return fn(lookup), nil
+}
+
+// storeFn returns the function creating the Store of the registered format.
+func storeFn(format string) (NewStoreFn, error) {
+	stores.RLock()
+	fn, ok := stores.m[format]
+	stores.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unknown store format: %s", format)
 	}
-	defer src.Close()
+	return fn, nil
+}
+
+// Stores returns the sorted list of the registered formats.
+func Stores() []string {
+	stores.RLock()
+	defer stores.RUnlock()
+	formats := make([]string, 0, len(stores.m))
+	for format := range stores.m {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
 
-	store := from.New(LookupFn)
-	if _, err := store.ReadFrom(src); err != nil {
+func ioLoad(ctx context.Context, from FromIO, LookupFn LookupFn, include string) (Store, error) {
+	if from == nil {
+		return nil, nil
+	}
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
+	var srcs []io.ReadCloser
+	switch fc := from.(type) {
+	case FromIOMerge:
+		all, err := fc.LoadAll()
+		if err != nil {
+			return nil, err
+		}
+		srcs = all
+	case FromIOContext:
+		src, err := fc.LoadContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		srcs = append(srcs, src)
+	default:
+		src, err := from.Load()
+		if err != nil {
+			return nil, err
+		}
+		srcs = append(srcs, src)
+	}
+	defer func() {
+		for _, src := range srcs {
+			if src != nil {
+				src.Close()
+			}
+		}
+	}()
+
+	var store Store
+	for _, src := range srcs {
+		if src == nil {
+			continue
+		}
+		s := from.New(LookupFn)
+		if _, err := s.ReadFrom(src); err != nil {
+			return nil, err
+		}
+		if include != "" {
+			// Files are included relative to the directory of the including one.
+			dir := "."
+			including := make(map[string]bool)
+			if f, ok := src.(interface{ Name() string }); ok {
+				dir = filepath.Dir(f.Name())
+				if abs, err := filepath.Abs(f.Name()); err == nil {
+					including[abs] = true
+				}
+			}
+			if err := ioInclude(from, LookupFn, s, include, dir, including); err != nil {
+				return nil, err
+			}
+		}
+		if store == nil {
+			store = s
+			continue
+		}
+		if err := mergeStore(store, s, ""); err != nil {
+			return nil, err
+		}
+	}
 	return store, nil
 }
 
-func ioComment(conf Config, store Store, keys ...string) error {
+// mergeStore sets all the values of src into dst, except the ones under the
+// top level key exclude.
+func mergeStore(dst, src Store, exclude string) error {
+	ks, ok := src.(KeysStore)
+	if !ok {
+		return errors.Errorf("%T does not list its keys", src)
+	}
+	for _, keys := range ks.Keys() {
+		if keys[0] == exclude {
+			continue
+		}
+		v, err := src.Get(keys...)
+		if err != nil {
+			return errors.Errorf("%s: %v", strings.Join(keys, "."), err)
+		}
+		if err := dst.Set(v, keys...); err != nil {
+			return errors.Errorf("%s: %v", strings.Join(keys, "."), err)
+		}
+	}
+	return nil
+}
+
+// ioComment sets the comment for the config item identified by keys
+// from its usage and, if requested, its source.
+func (c *config) ioComment(conf Config, store Store, keys ...string) error {
 	name := keys[len(keys)-1]
-	if comment := conf.Usage(name); comment != "" {
+	comment := conf.Usage(name)
+	if cs, ok := store.(CommentStore); ok && comment == "" {
+		// Keep the comment read for the config item without usage,
+		// as it was not generated from it.
+		comment = cs.Comment(keys...)
+		if c.options.ssource {
+			// The source is saved again below.
+			comment = trimSourceComment(comment)
+		}
+	}
+	if c.options.ssource && name != "" {
+		src := c.sources[strings.Join(keys, c.options.gsep)]
+		if comment != "" {
+			comment += "\n"
+		}
+		comment += "source: " + src.String()
+	}
+	if comment != "" {
 		return store.SetComment(comment, keys...)
 	}
 	return nil
 }
 
+// trimSourceComment returns comment without its last line if it is a source
+// as saved with OptionSaveSources.
+func trimSourceComment(comment string) string {
+	i := strings.LastIndexByte(comment, '\n') + 1
+	for src := SourceDefault; src <= SourceSet; src++ {
+		if comment[i:] == "source: "+src.String() {
+			return strings.TrimSuffix(comment[:i], "\n")
+		}
+	}
+	return comment
+}
+
 func (c *config) ioSave(store Store, from FromIO, LookupFn LookupFn) error {
 	dest, err := from.Save()
 	if err != nil || dest == nil {
@@ -76,27 +392,43 @@ func (c *config) ioSave(store Store, from FromIO, LookupFn LookupFn) error {
 	if store == nil {
 		store = from.New(LookupFn)
 	}
+	store = c.ioStore(store, nil)
+	sensitive := sensitiveSkip
+	if isSecure(from) {
+		sensitive = sensitiveKeep
+	}
+	return c.ioWrite(store, dest, sensitive)
+}
 
+// ioWrite encodes the config items with their comments into store and writes it to w.
+func (c *config) ioWrite(store Store, w io.Writer, sensitive int) error {
 	// Global comment.
-	if err := ioComment(c.raw, store, "", ""); err != nil {
+	if err := c.ioComment(c.raw, store, "", ""); err != nil {
 		return err
 	}
-
-	if err := ioEncode(c.raw, store, nil, c.root); err != nil {
+	if err := c.ioEncode(c.raw, store, nil, c.root, sensitive); err != nil {
 		return err
 	}
-	_, err = store.WriteTo(dest)
+	_, err := store.WriteTo(w)
 
 	return err
 }
 
-// ioEncode encodes root into the Store storage format.
-func ioEncode(conf Config, store Store, keys []string, root *structs.StructStruct) error {
-	tag := store.StructTag()
+// RedactedValue replaces the values of sensitive config items when they must not be disclosed.
+const RedactedValue = "REDACTED"
+
+// Handling of sensitive and secret fields when encoding them.
+const (
+	sensitiveSkip   = iota // Do not encode them, unless secrets read from the FromIO source.
+	sensitiveKeep          // Encode them as is.
+	sensitiveRedact        // Encode them with RedactedValue.
+)
 
+// ioEncode encodes root into the Store storage format.
+// Sensitive fields are handled according to the sensitive mode.
+func (c *config) ioEncode(conf Config, store Store, keys []string, root *structs.StructStruct, sensitive int) error {
 	for _, field := range root.Fields() {
-		if key := field.Tag().Get(tag); len(key) > 0 && key[0] == '-' {
-			// Skip discarded fields.
+		if ioDiscarded(store, field) {
 			continue
 		}
 		if c, _ := getCommand(field); c != nil {
@@ -111,18 +443,45 @@ func ioEncode(conf Config, store Store, keys []string, root *structs.StructStruc
 				ks = ks[:len(ks)-1]
 			}
 			conf := emb.Interface().(Config)
-			if err := ioEncode(conf, store, ks, emb); err != nil {
+			if err := c.ioEncode(conf, store, ks, emb, sensitive); err != nil {
 				return err
 			}
 			continue
 		}
 
+		if _, ok := field.Flag("deprecated"); ok {
+			continue
+		}
 		v := field.Interface()
-		if err := store.Set(v, ks...); err != nil {
+		if raw, ok := c.unexpandedValue(field, ks); ok && sensitive != sensitiveRedact {
+			// Keep the environment variables references when saving the config,
+			// the resolved values being shown otherwise.
+			v = raw
+		}
+		if ref, ok := c.secretrefs[strings.Join(ks, c.options.gsep)]; ok {
+			// Keep the reference to the secret.
+			v = ref
+		}
+		if isSecret(field) {
+			switch sensitive {
+			case sensitiveSkip:
+				// Secrets read from the source are kept so that they are not lost.
+				src, ok := c.sources[strings.Join(ks, c.options.gsep)]
+				if isSensitive(field) || !ok || src != SourceFile {
+					continue
+				}
+			case sensitiveRedact:
+				v = RedactedValue
+			}
+		}
+		if err := ioSet(store, field, v, ks...); err != nil {
 			return errors.Errorf("value %v: %v", v, err)
 		}
 
-		if err := ioComment(conf, store, ks...); err != nil {
+		if err := c.ioComment(conf, store, ks...); err != nil {
+			return err
+		}
+		if err := ioCommentMap(store, field, ks); err != nil {
 			return err
 		}
 	}
@@ -130,30 +489,172 @@ func ioEncode(conf Config, store Store, keys []string, root *structs.StructStruc
 	return nil
 }
 
-func (c *config) updateIO(store Store) error {
+// ioCommentMap sets the comments of the fields of the map entries of the
+// config item identified by keys, from their usage if the map values
+// implement Config.
+func ioCommentMap(store Store, field *structs.StructField, keys []string) error {
+	value := reflect.ValueOf(field.Interface())
+	if value.Kind() != reflect.Map || value.Type().Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	for _, key := range value.MapKeys() {
+		elem := reflect.New(value.Type().Elem())
+		elem.Elem().Set(value.MapIndex(key))
+		conf, ok := elem.Interface().(Config)
+		if !ok {
+			return nil
+		}
+		ks := append(keys[:len(keys):len(keys)], fmt.Sprintf("%v", key.Interface()))
+		for i, n := 0, elem.Elem().NumField(); i < n; i++ {
+			f := elem.Elem().Type().Field(i)
+			if f.PkgPath != "" {
+				// Unexported field.
+				continue
+			}
+			name := f.Name
+			if comment := conf.Usage(name); comment != "" {
+				if err := store.SetComment(comment, append(ks, name)...); err != nil {
+					return err
+				}
+			}
+		}
+		if comment := conf.Usage(""); comment != "" {
+			if err := store.SetComment(comment, ks...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkIOKeys returns an error listing the keys of the store, prefixed with prefix,
+// that do not map to any config item.
+// Keys of subcommands are checked when they are invoked.
+func (c *config) checkIOKeys(store Store, prefix []string) error {
+	ks, ok := store.(KeysStore)
+	if cs, isCase := store.(*caseStore); isCase {
+		store = cs.store
+		ks, ok = cs.keysStore()
+	}
+	if !ok {
+		return errors.Errorf("%T does not list its keys", store)
+	}
+
+	// The config items names are consumed by the sources: rebuild them.
+	all := newConfigFromStruct(c.root, c.raw, nil)
+	all.options = c.options
+	if err := all.buildKeys(c.root.Fields(), "", false); err != nil {
+		return err
+	}
+	keyName := func(keys []string) string {
+		return strings.ToLower(strings.Join(keys, "\x00"))
+	}
+	items := make(map[string]bool)
+	groups := make(map[string]bool)
+	for _, name := range all.trans {
+		keys := all.fromNameAll(name, c.options.gsep)
+		items[keyName(keys)] = true
+		for i := 1; i < len(keys); i++ {
+			groups[keyName(keys[:i])] = true
+		}
+	}
+	cmds := make(map[string]bool)
+	for _, field := range c.root.Fields() {
+		if s, _ := getCommand(field); s != nil {
+			cmds[strings.ToLower(s.Name())] = true
+		}
+	}
+
+	var unknown []string
+	for _, keys := range ks.Keys() {
+		if len(keys) <= len(prefix) || keyName(keys[:len(prefix)]) != keyName(prefix) {
+			continue
+		}
+		if len(prefix) == 0 && c.options.incl != "" && keys[0] == c.options.incl {
+			continue
+		}
+		keys = keys[len(prefix):]
+		if cmds[strings.ToLower(keys[0])] || groups[keyName(keys)] {
+			continue
+		}
+		known := false
+		for i := range keys {
+			// Values of map config items are nested under their key.
+			if known = items[keyName(keys[:i+1])]; known {
+				break
+			}
+		}
+		if !known {
+			unknown = append(unknown, strings.Join(keys, "."))
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return &UnknownKeyError{unknown}
+}
+
+// updateIO sets the config items from the store, where their keys are prefixed with prefix.
+func (c *config) updateIO(store Store, secure bool, prefix []string) error {
 	if store == nil {
 		return nil
 	}
 
-	for _, name := range c.trans {
-		keys := c.fromNameAll(name, c.options.gsep)
-		field := c.root.Lookup(keys...)
+	for lname, name := range c.items() {
+		fkeys := strings.Split(name, c.options.gsep)
+		field := c.root.Lookup(fkeys...)
+		keys := append(prefix[:len(prefix):len(prefix)], fkeys...)
+		sensitive := isSensitive(field) && !secure
 		if !store.Has(keys...) {
+			_, deprecated := field.Flag("deprecated")
+			secret := isSecret(field) && !secure
+			if secret || deprecated || ioDiscarded(store, field) {
+				// Never expose secret items.
+				continue
+			}
 			// Add the config item to the store for saving.
 			v := field.Interface()
-			if err := store.Set(v, keys...); err != nil {
+			if err := ioSet(store, field, v, keys...); err != nil {
 				return err
 			}
 
 			continue
 		}
+		if sensitive {
+			if err := c.collect(c.fieldError(name, SourceFile, "", ErrNotSecure)); err != nil {
+				return err
+			}
+			continue
+		}
 		v, err := store.Get(keys...)
 		if err != nil {
-			return errors.Errorf("%s: %v", name, err)
+			if err := c.collect(c.fieldError(name, SourceFile, "", err)); err != nil {
+				return err
+			}
+			continue
+		}
+		if c.options.expand {
+			xv, err := c.expandValue(v)
+			if err != nil {
+				if err := c.collect(c.fieldError(name, SourceFile, "", err)); err != nil {
+					return err
+				}
+				continue
+			}
+			if !reflect.DeepEqual(xv, v) {
+				if c.unexpanded == nil {
+					c.unexpanded = make(map[string]interface{})
+				}
+				c.unexpanded[name] = v
+				v = xv
+			}
 		}
 
-		if err := field.Set(v); err != nil {
-			return err
+		if err := c.setItem(lname, name, field, v, SourceFile, fmt.Sprintf("%v", v)); err != nil {
+			if err := c.collect(c.fieldError(name, SourceFile, "", err)); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
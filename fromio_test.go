@@ -0,0 +1,421 @@
+package construct_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type cfgStrict struct {
+	constructs.ConfigFileJSON
+	RemoteDB `cfg:"DB"`
+	Level    string
+}
+
+func (*cfgStrict) Init() error              { return nil }
+func (*cfgStrict) Usage(name string) string { return "" }
+
+func TestStrictIO(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	data := `{"DB": {"Port": 5432, "Hots": "x"}, "Levl": "debug", "Level": "info"}`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := cfgStrict{}
+	c.Name = name
+	err := construct.LoadArgs(&c, nil, construct.OptionStrictIO(nil))
+	if want := "unknown config keys: DB.Hots, Levl"; err == nil || err.Error() != want {
+		t.Fatalf("got %v; expected %s", err, want)
+	}
+
+	var warning error
+	c = cfgStrict{}
+	c.Name = name
+	err = construct.LoadArgs(&c, nil, construct.OptionStrictIO(func(err error) { warning = err }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warning == nil {
+		t.Error("missing warning")
+	}
+	if c.Port != 5432 || c.Level != "info" {
+		t.Errorf("config not loaded: %+v", c)
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"config.json":        `{"include": ["base.json", "conf.d/*.json"], "Level": "info", "DB": {"Host": "main"}}`,
+		"base.json":          `{"Level": "debug", "DB": {"Port": 1}}`,
+		"conf.d/10-db.json":  `{"DB": {"Port": 5432}}`,
+		"conf.d/20-lvl.json": `{"Level": "error", "include": "../extra.json"}`,
+		"extra.json":         `{"DB": {"Host": "extra"}}`,
+	} {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := cfgStrict{}
+	c.Name = filepath.Join(dir, "config.json")
+	include := construct.OptionInclude("include")
+	if err := construct.LoadArgs(&c, nil, include, construct.OptionStrictIO(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "extra" || c.Port != 5432 || c.Level != "error" {
+		t.Errorf("includes not merged: %+v", c)
+	}
+
+	// Files are only included on request.
+	c = cfgStrict{}
+	c.Name = filepath.Join(dir, "config.json")
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "main" || c.Level != "info" {
+		t.Errorf("unexpected includes: %+v", c)
+	}
+
+	// Include cycle.
+	name := filepath.Join(dir, "extra.json")
+	if err := os.WriteFile(name, []byte(`{"include": "config.json"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c = cfgStrict{}
+	c.Name = filepath.Join(dir, "config.json")
+	if err := construct.LoadArgs(&c, nil, include); err == nil {
+		t.Error("expected an include cycle error")
+	}
+}
+
+type cfgExpand struct {
+	constructs.ConfigFileJSON
+	RemoteDB `cfg:"DB"`
+	Level    string
+}
+
+func (*cfgExpand) Init() error              { return nil }
+func (*cfgExpand) Usage(name string) string { return "" }
+
+func TestExpandEnv(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	data := `{"DB": {"Host": "${EXPAND_HOST}:$$1"}, "Level": "$EXPAND_LEVEL"}`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EXPAND_HOST", "dbhost")
+
+	c := cfgExpand{}
+	c.Name = name
+	c.ToSave = true
+	snap := filepath.Join(t.TempDir(), "snapshot.json")
+	err := construct.LoadArgs(&c, nil, construct.OptionExpandEnv(false), construct.OptionSnapshot(snap, "json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "dbhost:$1" || c.Level != "$EXPAND_LEVEL" {
+		t.Errorf("invalid expansion: %+v", c)
+	}
+	saved, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"${EXPAND_HOST}:$$1"`; !strings.Contains(string(saved), want) {
+		t.Errorf("reference not saved: %s", saved)
+	}
+	// The snapshot records the resolved values.
+	saved, err = os.ReadFile(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"dbhost:$1"`; !strings.Contains(string(saved), want) {
+		t.Errorf("resolved value not in snapshot: %s", saved)
+	}
+
+	c = cfgExpand{}
+	c.Name = name
+	err = construct.LoadArgs(&c, nil, construct.OptionExpandEnv(true))
+	if err == nil || !strings.Contains(err.Error(), "undefined environment variable EXPAND_LEVEL") {
+		t.Errorf("got %v; expected an undefined variable error", err)
+	}
+}
+
+type cfgTimeLayout struct {
+	constructs.ConfigFileJSON
+	Since time.Time `cfg:",time=2006-01-02"`
+}
+
+func (*cfgTimeLayout) Init() error              { return nil }
+func (*cfgTimeLayout) Usage(name string) string { return "" }
+
+func TestTimeLayout(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(name, []byte(`{"Since": "2020-03-04"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgTimeLayout{}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC); !c.Since.Equal(want) {
+		t.Errorf("got %v; expected %v", c.Since, want)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"Since": "2020-03-04"`; !strings.Contains(string(data), want) {
+		t.Errorf("missing %s in saved config:\n%s", want, data)
+	}
+}
+
+type Listener struct {
+	Addr string
+	TLS  bool
+}
+
+type cfgListeners struct {
+	constructs.ConfigFileTOML
+	Listeners map[string]Listener `sep:",="`
+}
+
+func (*cfgListeners) Init() error                                            { return nil }
+func (*cfgListeners) Usage(name string) string                               { return "" }
+func (*cfgListeners) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgListeners) FlagsShort(name string) string                          { return "" }
+
+func TestStructMap(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.toml")
+	data := "[Listeners.web]\nAddr = \":80\"\n\n[Listeners.api]\nAddr = \":443\"\nTLS = true\n"
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var c cfgListeners
+	c.Name = name
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Listener{"web": {":80", false}, "api": {":443", true}}
+	if !reflect.DeepEqual(c.Listeners, want) {
+		t.Errorf("got %+v; expected %+v", c.Listeners, want)
+	}
+
+	// Command line flags address the fields by key.
+	name = filepath.Join(filepath.Dir(name), "saved.toml")
+	c = cfgListeners{}
+	c.Name = name
+	c.ToSave = true
+	args := []string{"--listeners", "web.addr=:8080", "--listeners", "admin.addr=:9000,admin.tls=true"}
+	if err := construct.LoadArgs(&c, args); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]Listener{"web": {":8080", false}, "admin": {":9000", true}}
+	if !reflect.DeepEqual(c.Listeners, want) {
+		t.Errorf("got %+v; expected %+v", c.Listeners, want)
+	}
+
+	// Saved as tables.
+	c = cfgListeners{}
+	c.Name = name
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Listeners, want) {
+		t.Errorf("got %+v; expected %+v", c.Listeners, want)
+	}
+
+	// So do environment variables.
+	t.Setenv("LISTENERS", "web.tls=true")
+	c = cfgListeners{}
+	c.Name = name
+	if err := construct.LoadArgs(&c, nil, construct.OptionEnvPrefix("")); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Listener{"", true}); c.Listeners["web"] != want {
+		t.Errorf("got %+v; expected %+v", c.Listeners["web"], want)
+	}
+}
+
+type cfgMerge struct {
+	constructs.ConfigFileJSON
+	Append  []string       `cfg:",merge=append"`
+	Union   []string       `cfg:",merge=union"`
+	Replace []string       `cfg:",merge=replace"`
+	Labels  map[string]int `cfg:",merge=union"`
+}
+
+func (*cfgMerge) Init() error                                            { return nil }
+func (*cfgMerge) Usage(name string) string                               { return "" }
+func (*cfgMerge) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgMerge) FlagsShort(name string) string                          { return "" }
+
+func TestMerge(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	data := `{"Append": ["a", "b"], "Union": ["a", "b"], "Replace": ["a", "b"], "Labels": {"x": 1, "y": 2}}`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("APPEND", "b,c")
+	t.Setenv("UNION", "b,c")
+	t.Setenv("REPLACE", "b,c")
+
+	var c cfgMerge
+	c.Name = name
+	args := []string{"--append", "d", "--union", "a", "--replace", "d", "--labels", "y:3"}
+	if err := construct.LoadArgs(&c, args, construct.OptionEnvPrefix("")); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		got, want interface{}
+	}{
+		{c.Append, []string{"a", "b", "b", "c", "d"}},
+		{c.Union, []string{"a", "b", "c"}},
+		{c.Replace, []string{"d"}},
+		{c.Labels, map[string]int{"x": 1, "y": 3}},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("got %v; expected %v", tc.got, tc.want)
+		}
+	}
+
+	type invalid struct {
+		cfgMerge
+		Port int `cfg:",merge=append"`
+	}
+	if err := construct.LoadArgs(&invalid{}, nil); err == nil {
+		t.Error("expected an error on non slice field")
+	}
+}
+
+type cfgMultiIO struct {
+	System constructs.ConfigFileTOML `cfg:"-"`
+	User   constructs.ConfigFileJSON `cfg:"-"`
+	Host   string
+	Port   int
+	Debug  bool
+}
+
+func (*cfgMultiIO) Init() error              { return nil }
+func (*cfgMultiIO) Usage(name string) string { return "" }
+func (c *cfgMultiIO) IOSources() []construct.FromIO {
+	return []construct.FromIO{&c.System, &c.User}
+}
+
+func TestFromIOMulti(t *testing.T) {
+	dir := t.TempDir()
+	system := filepath.Join(dir, "system.toml")
+	if err := os.WriteFile(system, []byte("Host = \"system\"\nPort = 80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	user := filepath.Join(dir, "user.json")
+	if err := os.WriteFile(user, []byte(`{"Port": 8080}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DEBUG", "true")
+
+	var c cfgMultiIO
+	c.System.Name = system
+	c.User.Name = user
+	c.User.ToSave = true
+	if err := construct.LoadArgs(&c, nil, construct.OptionEnvPrefix("")); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "system" || c.Port != 8080 || !c.Debug {
+		t.Errorf("got %s:%d %v; expected system:8080 true", c.Host, c.Port, c.Debug)
+	}
+
+	// Only the user file is saved.
+	data, err := os.ReadFile(system)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "Host = \"system\"\nPort = 80\n" {
+		t.Errorf("system file modified:\n%s", got)
+	}
+	data, err = os.ReadFile(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); !strings.Contains(got, `"Host": "system"`) || !strings.Contains(got, `"Port": 8080`) {
+		t.Errorf("unexpected user file:\n%s", got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.ini")
+	data := "# operator note\nHost = localhost\n# old usage\nPort = 80\n\n[Extra]\n# unknown section\nKey = keep\n"
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgSources{}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# operator note\nHost",
+		"# listening port\nPort",
+		"[Extra]\n# unknown section\nKey = keep\n",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(string(got), "old usage") {
+		t.Errorf("usage not updated in:\n%s", got)
+	}
+}
+
+type cfgSaveSecret struct {
+	constructs.ConfigFileINI
+	User     string
+	Password string `cfg:",secret"`
+}
+
+func (*cfgSaveSecret) Init() error              { return nil }
+func (*cfgSaveSecret) Usage(name string) string { return "" }
+
+func TestSaveSecret(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.ini")
+	if err := os.WriteFile(name, []byte("User = bob\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := cfgSaveSecret{Password: "hunter2"}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.User != "bob" || c.Password != "hunter2" {
+		t.Errorf("invalid config: %+v", c)
+	}
+	// The secret default value is not added to the saved file.
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") || strings.Contains(string(data), "Password") {
+		t.Errorf("secret saved:\n%s", data)
+	}
+}
@@ -0,0 +1,75 @@
+package construct
+
+import (
+	"context"
+	"strings"
+)
+
+// RemoteClient defines the interface of the clients of remote key/value stores
+// such as etcd, Consul or any HTTP endpoint.
+type RemoteClient interface {
+	// Get returns the value of the key and whether or not it exists.
+	Get(key string) (value string, ok bool, err error)
+}
+
+// RemoteClientContext is optionally implemented by RemoteClients supporting
+// cancellation, see LoadContext. GetContext is then used instead of Get.
+type RemoteClientContext interface {
+	GetContext(ctx context.Context, key string) (value string, ok bool, err error)
+}
+
+// FromRemote defines the interface to set values from a remote key/value store.
+//
+// The key of a config item is made of the prefix followed by its path
+// separated by slashes, e.g. "myapp/Group/Field" for the Field item in the
+// Group group with the "myapp" prefix.
+//
+// Sensitive config items are only set if the FromRemote source implements SecureIO.
+type FromRemote interface {
+	// Remote returns the client used to fetch the config items values
+	// and the prefix of their keys.
+	Remote() (client RemoteClient, prefix string)
+}
+
+// updateRemote sets the config items from the remote source, where their keys are prefixed with prefix.
+func (c *config) updateRemote(from FromRemote, prefix []string) error {
+	client, kprefix := from.Remote()
+	if client == nil {
+		return nil
+	}
+	secure := isSecure(from)
+	for lname, name := range c.items() {
+		keys := strings.Split(name, c.options.gsep)
+		field := c.root.Lookup(keys...)
+		if isSensitive(field) && !secure {
+			continue
+		}
+		key := strings.Join(append(prefix[:len(prefix):len(prefix)], keys...), "/")
+		if kprefix != "" {
+			key = strings.TrimSuffix(kprefix, "/") + "/" + key
+		}
+		var v string
+		var ok bool
+		var err error
+		if cc, isCtx := client.(RemoteClientContext); isCtx {
+			v, ok, err = cc.GetContext(c.ctx, key)
+		} else {
+			v, ok, err = client.Get(key)
+		}
+		if err != nil {
+			if err := c.collect(c.fieldError(name, SourceRemote, key, err)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := c.setItem(lname, name, field, v, SourceRemote, v); err != nil {
+			if err := c.collect(c.fieldError(name, SourceRemote, key, err)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
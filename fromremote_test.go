@@ -0,0 +1,64 @@
+package construct_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type mapClient map[string]string
+
+func (m mapClient) Get(key string) (string, bool, error) {
+	v, ok := m[key]
+	return v, ok, nil
+}
+
+type RemoteDB struct {
+	Host string
+	Port int
+}
+
+func (*RemoteDB) Init() error              { return nil }
+func (*RemoteDB) Usage(name string) string { return "" }
+
+type cfgRemote struct {
+	constructs.ConfigFileJSON
+	RemoteDB `cfg:"DB"`
+	Level    string
+	client   mapClient
+}
+
+func (*cfgRemote) Init() error              { return nil }
+func (*cfgRemote) Usage(name string) string { return "" }
+func (*cfgRemote) Env(name string) string {
+	return "REMOTE_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+func (c *cfgRemote) Remote() (construct.RemoteClient, string) { return c.client, "app/" }
+
+func TestFromRemote(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	data := `{"Level": "file", "DB": {"Host": "filehost", "Port": 1}}`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("REMOTE_LEVEL", "env")
+
+	c := cfgRemote{client: mapClient{
+		"app/Level":   "remote",
+		"app/DB/Host": "remotehost",
+	}}
+	c.Name = name
+	if err := construct.LoadArgs(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.RemoteDB, (RemoteDB{"remotehost", 1}); got != want {
+		t.Errorf("got %+v; expected %+v", got, want)
+	}
+	if c.Level != "env" {
+		t.Errorf("got %s; expected env", c.Level)
+	}
+}
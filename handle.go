@@ -0,0 +1,162 @@
+package construct
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/pierrec/construct/internal/structs"
+	"github.com/pkg/errors"
+)
+
+// Handle gives access to information about a loaded config.
+type Handle struct {
+	// Configs processed while loading: the main one followed by the invoked subcommands.
+	confs []*config
+}
+
+// LoadHandle is equivalent to LoadArgs and returns a Handle on the loaded config.
+func LoadHandle(config Config, args []string, options ...Option) (*Handle, error) {
+	h := &Handle{}
+	if err := loadArgs(context.Background(), config, args, options, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// lookup returns the config item or group identified by keys, the config it
+// belongs to and its keys relative to that config.
+func (h *Handle) lookup(keys []string) (*config, *structs.StructField, []string) {
+	for i, c := range h.confs {
+		if i > 0 {
+			// Config items of subcommands are prefixed with the subcommand name.
+			if len(keys) == 0 || keys[0] != c.root.Name() {
+				return nil, nil, nil
+			}
+			keys = keys[1:]
+		}
+		if len(keys) == 0 {
+			return nil, nil, nil
+		}
+		if cmd := c.root.Lookup(keys[0]); cmd != nil {
+			if s, _ := getCommand(cmd); s != nil {
+				// Config item of a subcommand.
+				continue
+			}
+		}
+		field := c.root.Lookup(keys...)
+		if field == nil {
+			return nil, nil, nil
+		}
+		return c, field, keys
+	}
+	return nil, nil, nil
+}
+
+// Changed reports whether the config item identified by keys was explicitly
+// set on the command line, or with Set.
+// keys is the path to the config item: group and subcommand names followed
+// by the field name, as in Store.
+func (h *Handle) Changed(keys ...string) bool {
+	c, field, keys := h.lookup(keys)
+	if c == nil || field.Embedded() != nil {
+		return false
+	}
+	name := strings.Join(keys, c.options.gsep)
+	if c.sources[name] == SourceSet {
+		return true
+	}
+	if c.fs == nil {
+		return false
+	}
+	fname := c.flagName(name)
+	return c.fs.Changed(fname) || c.isNegated(fname) && c.fs.Changed("no-"+fname)
+}
+
+// Source returns the source which explicitly set the config item identified
+// by keys, as in Changed, or SourceDefault if none of them did, even if the value
+// they provided is the zero value. It also returns SourceDefault if the config item
+// does not exist.
+func (h *Handle) Source(keys ...string) Source {
+	c, field, keys := h.lookup(keys)
+	if c == nil || field.Embedded() != nil {
+		return SourceDefault
+	}
+	if src, ok := c.sources[strings.Join(keys, c.options.gsep)]; ok {
+		return src
+	}
+	return SourceDefault
+}
+
+// Set assigns value to the config item identified by its dotted path,
+// e.g. "Group.Field". If value is not of the config item type, it is
+// converted the same way as values from other sources, so that strings
+// are parsed. The config is then validated as when loaded and a
+// ValidationError is returned if it fails.
+//
+// The source of the config item becomes SourceSet.
+//
+// The config item is left untouched if an error occurs.
+// The Init methods are not invoked again and the caller is responsible for
+// synchronizing Set with concurrent accesses to the config.
+func (h *Handle) Set(path string, value interface{}) error {
+	c, field, keys := h.lookup(strings.Split(path, "."))
+	if field == nil || field.Embedded() != nil {
+		return errors.Errorf("unknown config item %s", path)
+	}
+	v := reflect.ValueOf(field.PtrValue()).Elem()
+	prev := reflect.New(v.Type()).Elem()
+	prev.Set(v)
+	if value != nil && reflect.TypeOf(value).AssignableTo(v.Type()) {
+		v.Set(reflect.ValueOf(value))
+	} else {
+		// Slices and maps values are appended to: start from an empty value.
+		v.Set(reflect.Zero(v.Type()))
+		if err := field.Set(value); err != nil {
+			v.Set(prev)
+			return errors.Errorf("%s: %v", path, err)
+		}
+	}
+	if err := c.validate(); err != nil {
+		v.Set(prev)
+		return err
+	}
+	name := strings.Join(keys, c.options.gsep)
+	raw, err := c.marshalItem(name)
+	if err != nil {
+		v.Set(prev)
+		return err
+	}
+	delete(c.trans, strings.ToLower(name))
+	c.setSource(name, SourceSet, raw)
+	return nil
+}
+
+// Get returns the current value of the config item identified by its dotted
+// path, e.g. "Group.Field", and whether or not it exists.
+// Inlined structs are not part of the path. If the path identifies a group of
+// config items, a pointer to its struct is returned.
+func (h *Handle) Get(path string) (interface{}, bool) {
+	_, field, _ := h.lookup(strings.Split(path, "."))
+	if field == nil {
+		return nil, false
+	}
+	if emb := field.Embedded(); emb != nil {
+		return emb.Interface(), true
+	}
+	return field.Interface(), true
+}
+
+// Save saves the config to its FromIO sources as Load does, typically when
+// loaded with OptionNoSave. It does nothing if the config has no FromIO source.
+func (h *Handle) Save() error {
+	for _, c := range h.confs {
+		if c.save == nil {
+			continue
+		}
+		if err := c.save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package construct_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pierrec/construct"
+)
+
+func TestHandleChanged(t *testing.T) {
+	c := cfgCmds{V: 1}
+	h, err := construct.LoadHandle(&c, []string{"--v", "1", "install"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		keys []string
+		want bool
+	}{
+		{[]string{"V"}, true},
+		{[]string{"Install", "Force"}, false},
+		{[]string{"Inspect", "Deep"}, false},
+		{[]string{"Unknown"}, false},
+	} {
+		if got := h.Changed(tc.keys...); got != tc.want {
+			t.Errorf("%v: got %v; expected %v", tc.keys, got, tc.want)
+		}
+	}
+
+	c = cfgCmds{}
+	h, err = construct.LoadHandle(&c, []string{"install", "--force"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Changed("V") {
+		t.Error("V: unexpected changed flag")
+	}
+	if !h.Changed("Install", "Force") {
+		t.Error("Install Force: expected changed flag")
+	}
+}
+
+func TestHandleChangedNegated(t *testing.T) {
+	c := cfgExplicitFlags{Verbose: true}
+	h, err := construct.LoadHandle(&c, []string{"--no-verbose"}, construct.OptionNegativeFlags(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Verbose {
+		t.Error("Verbose: expected negated flag")
+	}
+	if !h.Changed("Verbose") {
+		t.Error("Verbose: expected changed flag")
+	}
+	if got, want := h.Source("Verbose"), construct.SourceFlags; got != want {
+		t.Errorf("got %v; expected %v", got, want)
+	}
+	if h.Changed("Force") {
+		t.Error("Force: unexpected changed flag")
+	}
+}
+
+func TestHandleSource(t *testing.T) {
+	var c cfgHandle
+	h, err := construct.LoadHandle(&c, nil,
+		construct.OptionEnvPrefix("APP"),
+		construct.OptionEnv(map[string]string{"APP_V": "0"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		keys []string
+		want construct.Source
+	}{
+		{[]string{"V"}, construct.SourceEnv},
+		{[]string{"Group", "Name"}, construct.SourceDefault},
+		{[]string{"Group"}, construct.SourceDefault},
+		{[]string{"Unknown"}, construct.SourceDefault},
+	} {
+		if got := h.Source(tc.keys...); got != tc.want {
+			t.Errorf("%v: got %v; expected %v", tc.keys, got, tc.want)
+		}
+	}
+
+	var cmds cfgCmds
+	h, err = construct.LoadHandle(&cmds, []string{"install", "--force=false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.Source("Install", "Force"); got != construct.SourceFlags {
+		t.Errorf("Install Force: got %v; expected %v", got, construct.SourceFlags)
+	}
+}
+
+type HandleGroup struct {
+	Name  string
+	Ports []int
+}
+
+func (*HandleGroup) Init() error              { return nil }
+func (*HandleGroup) Usage(name string) string { return "" }
+
+type cfgHandle struct {
+	HandleGroup `cfg:"Group"`
+	V           int
+}
+
+func (*cfgHandle) Init() error              { return nil }
+func (*cfgHandle) Usage(name string) string { return "" }
+
+func TestHandleSet(t *testing.T) {
+	c := cfgHandle{HandleGroup{"a", []int{1}}, 1}
+	h, err := construct.LoadHandle(&c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		path  string
+		value interface{}
+	}{
+		{"Group.Name", "b"},
+		{"Group.Ports", "2,3"},
+		{"V", 2},
+	} {
+		if err := h.Set(tc.path, tc.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := cfgHandle{HandleGroup{"b", []int{2, 3}}, 2}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v; expected %+v", c, want)
+	}
+	if got := h.Source("Group", "Ports"); got != construct.SourceSet {
+		t.Errorf("got source %v; expected %v", got, construct.SourceSet)
+	}
+	if !h.Changed("V") {
+		t.Error("V: expected changed config item")
+	}
+	r, err := h.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantItems := []construct.ReportItem{
+		{Key: "Group-Name", Source: construct.SourceSet, Raw: "b"},
+		{Key: "Group-Ports", Source: construct.SourceSet, Raw: "2,3"},
+		{Key: "V", Source: construct.SourceSet, Raw: "2"},
+	}
+	if !reflect.DeepEqual(r.Items, wantItems) {
+		t.Errorf("got %+v; expected %+v", r.Items, wantItems)
+	}
+
+	if err := h.Set("Group.Ports", "x"); err == nil {
+		t.Error("expected error on invalid value")
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("value changed on error: %+v", c)
+	}
+	if err := h.Set("Group.Unknown", 1); err == nil {
+		t.Error("expected error on unknown config item")
+	}
+}
+
+type cfgHandleValid struct {
+	Level string `cfg:",choices=debug|info"`
+}
+
+func (*cfgHandleValid) Init() error              { return nil }
+func (*cfgHandleValid) Usage(name string) string { return "" }
+
+func TestHandleSetInvalid(t *testing.T) {
+	c := cfgHandleValid{"info"}
+	h, err := construct.LoadHandle(&c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Set("Level", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	err = h.Set("Level", "warn")
+	if _, ok := err.(*construct.ValidationError); !ok {
+		t.Fatalf("got error %v; expected a ValidationError", err)
+	}
+	if c.Level != "debug" {
+		t.Errorf("value changed on error: %q", c.Level)
+	}
+}
+
+type cfgHandleInline struct {
+	HandleGroup `cfg:",inline"`
+	V           int
+}
+
+func (*cfgHandleInline) Init() error              { return nil }
+func (*cfgHandleInline) Usage(name string) string { return "" }
+
+func TestHandleGet(t *testing.T) {
+	c := cfgHandle{HandleGroup{"a", []int{1}}, 1}
+	h, err := construct.LoadHandle(&c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		path string
+		want interface{}
+		ok   bool
+	}{
+		{"Group.Name", "a", true},
+		{"Group.Ports", []int{1}, true},
+		{"V", 1, true},
+		{"Group", &c.HandleGroup, true},
+		{"Group.Unknown", nil, false},
+		{"Name", nil, false},
+	} {
+		got, ok := h.Get(tc.path)
+		if ok != tc.ok || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, %v; expected %v, %v", tc.path, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	ci := cfgHandleInline{HandleGroup{"a", nil}, 1}
+	h, err = construct.LoadHandle(&ci, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := h.Get("Name"); !ok || got != "a" {
+		t.Errorf("inlined Name: got %v, %v", got, ok)
+	}
+}
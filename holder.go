@@ -0,0 +1,135 @@
+package construct
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// Holder holds the latest loaded config and allows reloading it.
+// It is safe for concurrent use.
+//
+// Configs held by a Holder must not be modified once loaded.
+type Holder struct {
+	current atomic.Value
+
+	mu       sync.Mutex
+	template reflect.Value // Copy of the config before it was first loaded.
+	args     []string
+	options  []Option
+	loads    uint64 // Number of started loads.
+	stored   uint64 // Number of the load of the current config.
+}
+
+// OptionHolder stores the loaded config into the Holder so that it can
+// be accessed concurrently and reloaded.
+func OptionHolder(h *Holder) Option {
+	return func(c *config) error {
+		c.options.holder = h
+		return nil
+	}
+}
+
+// Current returns the latest loaded config.
+// It returns nil if none was loaded yet.
+func (h *Holder) Current() Config {
+	c, _ := h.current.Load().(Config)
+	return c
+}
+
+// Reload loads a new instance of the config from its initial values using the same
+// arguments and options as the first load and atomically replaces the current config
+// with it. The current config is left untouched if an error occurs, or if a
+// load started after this one already replaced it.
+//
+// As with Watch, the FlagsDone, PreRun and PostRun methods are not invoked
+// and the FromIO sources are not saved on reload.
+//
+// The slices and maps of the initial values are copied for each instance,
+// except the ones held by unexported fields, which are shared.
+func (h *Holder) Reload() error {
+	h.mu.Lock()
+	template := h.template
+	args, options := h.args, h.options
+	h.mu.Unlock()
+	if !template.IsValid() {
+		return errors.Errorf("no config to reload")
+	}
+
+	config := reflect.New(template.Type())
+	deepCopy(config.Elem(), template)
+	options = append(options[:len(options):len(options)], optionReload())
+	return LoadArgs(config.Interface().(Config), args, options...)
+}
+
+// prepare records the initial config before its first load
+// and returns the number of the starting load.
+func (h *Holder) prepare(config Config, args []string, options []Option) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.loads++
+	if h.template.IsValid() {
+		return h.loads
+	}
+	src := reflect.ValueOf(config).Elem()
+	h.template = reflect.New(src.Type()).Elem()
+	deepCopy(h.template, src)
+	h.args = args
+	h.options = options
+	return h.loads
+}
+
+// store makes config, loaded by the given load, the current one
+// unless the current one was loaded by a more recent load.
+func (h *Holder) store(config Config, load uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if load < h.stored {
+		return
+	}
+	h.stored = load
+	h.current.Store(config)
+}
+
+// deepCopy sets dst to a copy of src, duplicating its slices and maps.
+// Unexported fields cannot be set by reflection: their values are copied as
+// part of their struct, so that their slices and maps are shared with src.
+func deepCopy(dst, src reflect.Value) {
+	dst.Set(src)
+	switch src.Kind() {
+	case reflect.Struct:
+		for i, n := 0, src.NumField(); i < n; i++ {
+			if f := dst.Field(i); f.CanSet() {
+				deepCopy(f, src.Field(i))
+			}
+		}
+	case reflect.Array:
+		for i, n := 0, src.Len(); i < n; i++ {
+			deepCopy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		n := src.Len()
+		s := reflect.MakeSlice(src.Type(), n, n)
+		for i := 0; i < n; i++ {
+			deepCopy(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			v := reflect.New(src.Type().Elem()).Elem()
+			deepCopy(v, iter.Value())
+			m.SetMapIndex(iter.Key(), v)
+		}
+		dst.Set(m)
+	}
+}
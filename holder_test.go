@@ -0,0 +1,186 @@
+package construct_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type cfgHolder struct {
+	constructs.ConfigFileINI
+	Port  int
+	Check int
+	Hosts []string
+}
+
+func (*cfgHolder) Init() error              { return nil }
+func (*cfgHolder) Usage(name string) string { return "" }
+
+func TestHolderReload(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.ini")
+	write := func(port int) {
+		p := strconv.Itoa(port)
+		data := "Port = " + p + "\nCheck = " + p + "\nHosts = a,b\n"
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(1)
+
+	var h construct.Holder
+	c := &cfgHolder{Hosts: []string{"x"}}
+	c.Name = name
+	if err := construct.LoadArgs(c, nil, construct.OptionHolder(&h)); err != nil {
+		t.Fatal(err)
+	}
+	if h.Current() != c {
+		t.Fatal("loaded config not held")
+	}
+	nhosts := len(c.Hosts)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				c := h.Current().(*cfgHolder)
+				if c.Port != c.Check || len(c.Hosts) != nhosts {
+					t.Errorf("inconsistent config: %+v", c)
+					return
+				}
+			}
+		}()
+	}
+	for port := 2; port < 10; port++ {
+		write(port)
+		if err := h.Reload(); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := h.Current().(*cfgHolder).Port, port; got != want {
+			t.Errorf("got %d; expected %d", got, want)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// The initial config is not modified by reloads.
+	if c.Port != 1 {
+		t.Errorf("initial config modified: %+v", c)
+	}
+}
+
+func TestHolderConcurrentReloads(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "config.ini")
+	write := func(port int) {
+		// Replace the file atomically as it is read by concurrent reloads.
+		p := strconv.Itoa(port)
+		data := "Port = " + p + "\nCheck = " + p + "\n"
+		tmp := filepath.Join(dir, "config.tmp")
+		if err := os.WriteFile(tmp, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(0)
+
+	var h construct.Holder
+	c := &cfgHolder{}
+	c.Name = name
+	if err := construct.LoadArgs(c, nil, construct.OptionHolder(&h)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The config loaded last from the last data must win.
+	const last = 20
+	var wg sync.WaitGroup
+	for port := 1; port <= last; port++ {
+		write(port)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := h.Reload(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := h.Current().(*cfgHolder); got.Port != last || got.Check != last {
+		t.Errorf("got %+v; expected port %d", got, last)
+	}
+}
+
+type cfgHolderRun struct {
+	constructs.ConfigFileINI
+	Port int
+	runs []string
+}
+
+func (*cfgHolderRun) Init() error                   { return nil }
+func (*cfgHolderRun) Usage(name string) string      { return "" }
+func (*cfgHolderRun) FlagsShort(name string) string { return "" }
+func (c *cfgHolderRun) FlagsDone(cmds []construct.Config, args []string) error {
+	c.runs = append(c.runs, "FlagsDone")
+	return nil
+}
+func (c *cfgHolderRun) PreRun(cmds []construct.Config, args []string) error {
+	c.runs = append(c.runs, "PreRun")
+	return nil
+}
+func (c *cfgHolderRun) PostRun(cmds []construct.Config, err error) error {
+	c.runs = append(c.runs, "PostRun")
+	return err
+}
+
+func TestHolderReloadNoRun(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.ini")
+	if err := os.WriteFile(name, []byte("Port = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var h construct.Holder
+	c := &cfgHolderRun{}
+	c.Name = name
+	c.ToSave = true
+	if err := construct.LoadArgs(c, nil, construct.OptionHolder(&h)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"PreRun", "FlagsDone", "PostRun"}
+	if !reflect.DeepEqual(c.runs, want) {
+		t.Fatalf("got %v; expected %v", c.runs, want)
+	}
+
+	// The hooks are not run and the file not saved on reload.
+	const data = "Port = 2\n"
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	cur := h.Current().(*cfgHolderRun)
+	if cur.Port != 2 {
+		t.Errorf("got %d; expected 2", cur.Port)
+	}
+	if len(cur.runs) != 0 || !reflect.DeepEqual(c.runs, want) {
+		t.Errorf("hooks run on reload: %v %v", cur.runs, c.runs)
+	}
+	if got, err := os.ReadFile(name); err != nil || string(got) != data {
+		t.Errorf("got %q (%v); expected %q", got, err, data)
+	}
+}
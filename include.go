@@ -0,0 +1,91 @@
+package construct
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ioInclude merges into store the files it includes with key, relative to dir.
+// The files being included are tracked in including to detect cycles.
+func ioInclude(from FromIO, lookup LookupFn, store Store, key, dir string, including map[string]bool) error {
+	if !store.Has(key) {
+		return nil
+	}
+	v, err := store.Get(key)
+	if err != nil {
+		return errors.Errorf("%s: %v", key, err)
+	}
+	for _, pattern := range includePatterns(v) {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		names, err := filepath.Glob(pattern)
+		if err != nil {
+			return errors.Errorf("%s %s: %v", key, pattern, err)
+		}
+		if len(names) == 0 && !strings.ContainsAny(pattern, `*?[\`) {
+			return errors.Errorf("%s %s: file not found", key, pattern)
+		}
+		for _, name := range names {
+			if err := includeFile(from, lookup, store, key, name, including); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// includeFile merges the file name and the ones it includes into store.
+func includeFile(from FromIO, lookup LookupFn, store Store, key, name string, including map[string]bool) error {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	if including[abs] {
+		return errors.Errorf("%s %s: include cycle", key, name)
+	}
+	including[abs] = true
+	defer delete(including, abs)
+
+	f, err := os.Open(name)
+	if err != nil {
+		return errors.Errorf("%s: %v", key, err)
+	}
+	defer f.Close()
+	s := from.New(lookup)
+	if _, err := s.ReadFrom(f); err != nil {
+		return errors.Errorf("%s %s: %v", key, name, err)
+	}
+	if err := ioInclude(from, lookup, s, key, filepath.Dir(name), including); err != nil {
+		return err
+	}
+	return mergeStore(store, s, key)
+}
+
+// includePatterns returns the included files patterns from the value of the include key,
+// either a list or a comma separated string.
+func includePatterns(v interface{}) []string {
+	var patterns []string
+	switch w := v.(type) {
+	case nil:
+	case string:
+		for _, s := range strings.Split(w, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				patterns = append(patterns, s)
+			}
+		}
+	case []string:
+		patterns = w
+	case []interface{}:
+		for _, item := range w {
+			patterns = append(patterns, fmt.Sprintf("%v", item))
+		}
+	default:
+		patterns = append(patterns, fmt.Sprintf("%v", w))
+	}
+	return patterns
+}
@@ -0,0 +1,131 @@
+// Package dotenv parses dotenv (.env) files.
+package dotenv
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Item is a variable defined in a dotenv file.
+type Item struct {
+	Key, Value string
+}
+
+// Parse reads the variables defined in the dotenv formatted data of r,
+// in the order they are defined.
+//
+// Each line defines a variable as KEY=VALUE, optionally prefixed with export.
+// Empty lines and lines starting with # are ignored.
+// Values may be enclosed in double quotes, in which case \n, \t, \" and \\ are
+// unescaped, or in single quotes, in which case they are used verbatim.
+// Unquoted values end at the first " #" comment and are trimmed.
+func Parse(r io.Reader) ([]Item, error) {
+	var items []Item
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(line, "export ") {
+			line = strings.TrimSpace(line[len("export "):])
+		}
+		i := strings.IndexByte(line, '=')
+		if i <= 0 {
+			return nil, errors.Errorf("line %d: missing variable assignment", n)
+		}
+		key := strings.TrimSpace(line[:i])
+		value, err := parseValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, errors.Errorf("line %d: %s: %v", n, key, err)
+		}
+		items = append(items, Item{key, value})
+	}
+	return items, sc.Err()
+}
+
+func parseValue(s string) (string, error) {
+	if s == "" {
+		return s, nil
+	}
+	switch q := s[0]; q {
+	case '"', '\'':
+		end := closingQuote(s, q)
+		if end < 0 {
+			return "", errors.Errorf("unterminated quoted value")
+		}
+		if rest := strings.TrimSpace(s[end+1:]); rest != "" && rest[0] != '#' {
+			return "", errors.Errorf("unexpected data after quoted value")
+		}
+		s = s[1:end]
+		if q == '\'' {
+			return s, nil
+		}
+		return unescape(s), nil
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// closingQuote returns the index of the quote q ending the value quoted in s,
+// or -1 if there is none. Double quotes can be escaped with a backslash.
+func closingQuote(s string, q byte) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case q:
+			return i
+		case '\\':
+			if q == '"' {
+				i++
+			}
+		}
+	}
+	return -1
+}
+
+func unescape(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i == len(s)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// Quote returns value as is if it can be used verbatim in a dotenv file,
+// or enclosed in double quotes and escaped otherwise.
+func Quote(value string) string {
+	if value != "" && strings.IndexFunc(value, func(r rune) bool {
+		switch r {
+		case ' ', '\t', '\n', '\r', '"', '\'', '\\', '#', '=':
+			return true
+		}
+		return false
+	}) < 0 {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + r.Replace(value) + `"`
+}
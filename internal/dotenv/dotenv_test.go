@@ -0,0 +1,56 @@
+package dotenv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	data := `# comment
+A=1
+export B = two words # comment
+C="quoted # not a comment\nnew line"
+D='single \n quoted'
+E=
+F='a' # it's
+G="a \"b\"" # "c"
+`
+	items, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Item{
+		{"A", "1"},
+		{"B", "two words"},
+		{"C", "quoted # not a comment\nnew line"},
+		{"D", `single \n quoted`},
+		{"E", ""},
+		{"F", "a"},
+		{"G", `a "b"`},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("got %q; expected %q", items, want)
+	}
+
+	for _, data := range []string{"A", "=1", `A="1`, `A="1" x`, `A='1' x'`} {
+		if _, err := Parse(strings.NewReader(data)); err == nil {
+			t.Errorf("%q: expected error", data)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for _, value := range []string{"", "a", "two words", `"quoted" \ # x`, "multi\nline", "a=b"} {
+		items, err := Parse(strings.NewReader("A=" + Quote(value)))
+		if err != nil {
+			t.Fatalf("%q: %v", value, err)
+		}
+		if got := items[0].Value; got != value {
+			t.Errorf("got %q; expected %q", got, value)
+		}
+	}
+	if got := Quote("plain"); got != "plain" {
+		t.Errorf("got %q; expected plain", got)
+	}
+}
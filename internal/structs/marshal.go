@@ -2,33 +2,79 @@ package structs
 
 import (
 	"encoding"
+	"encoding/base64"
 	"fmt"
 	htemplate "html/template"
+	"math/big"
 	"net"
 	"net/url"
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
+// FloatFormat defines how floats are formatted, as per strconv.FormatFloat.
+type FloatFormat struct {
+	Fmt  byte
+	Prec int
+}
+
+// DefaultFloatFormat formats floats with the minimum number of digits
+// necessary to represent them exactly and without exponent.
+var DefaultFloatFormat = FloatFormat{'f', -1}
+
+// FormatFloat formats the float f of the given bit size with DefaultFloatFormat.
+func FormatFloat(f float64, bitSize int) string {
+	return strconv.FormatFloat(f, DefaultFloatFormat.Fmt, DefaultFloatFormat.Prec, bitSize)
+}
+
+// parseFloatFormat parses a float format defined as a strconv.FormatFloat
+// format optionally followed by the precision, e.g. "f2".
+func parseFloatFormat(s string) (FloatFormat, error) {
+	if s == "" || !strings.ContainsRune("beEfgGxX", rune(s[0])) {
+		return FloatFormat{}, errors.Errorf("invalid float format %q", s)
+	}
+	ff := FloatFormat{s[0], -1}
+	if len(s) > 1 {
+		prec, err := strconv.Atoi(s[1:])
+		if err != nil || prec < 0 {
+			return FloatFormat{}, errors.Errorf("invalid float format %q", s)
+		}
+		ff.Prec = prec
+	}
+	return ff, nil
+}
+
 // MarshalValue converts v into a higher level value or a string as follows:
 //  - int, int8, int16, int32 -> int64
 //  - uint, uint8, uint16, uint32 -> uint64
-//  - float32 -> float64
+//  - float32, float64 -> string formatted with DefaultFloatFormat
 //  - any slice/map/array -> string
 //  - time.Time, *text/template.Template, *html/template.Template, *regexp.RegExp, *url.URL -> string
-//  - *net.IPAddr, *net.IPNet -> string
+//    (templates read from a file are marshaled as the file reference, see TemplateFilePrefix)
+//  - net.IP, *net.IPAddr, net.IPNet, *net.IPNet, net.HardwareAddr -> string
+//  - big.Int, big.Float, big.Rat and pointers to them -> string
 //  - encoding.TextMarshaler -> string
+//  - encoding.BinaryMarshaler -> base64 encoded string
+//  - Value -> string
 //
 // The following types are returned as is:
-//  - bool, time.Duration, float64, int64, string, uint64
+//  - bool, time.Duration, int64, string, uint64
 //
 // sliceSep, mapKeySep
 func MarshalValue(v interface{}, seps []rune) (interface{}, error) {
+	return marshalValue(v, seps, DefaultFloatFormat, nil)
+}
+
+// marshalValue is MarshalValue with the float format ff, decomposing the
+// struct values of maps with tags.
+func marshalValue(v interface{}, seps []rune, ff FloatFormat, tags *tagIDs) (interface{}, error) {
 	// v = indirect(v)
 	var sep rune
 	if len(seps) > 0 {
@@ -39,10 +85,12 @@ func MarshalValue(v interface{}, seps []rune) (interface{}, error) {
 	switch w := v.(type) {
 	case nil:
 		// May error further down.
-	case bool, time.Duration, float64, int64, string, uint64:
+	case bool, time.Duration, int64, string, uint64:
 		return w, nil
 	case float32:
-		return float64(w), nil
+		return strconv.FormatFloat(float64(w), ff.Fmt, ff.Prec, 32), nil
+	case float64:
+		return strconv.FormatFloat(w, ff.Fmt, ff.Prec, 64), nil
 	case int:
 		return int64(w), nil
 	case int8:
@@ -77,12 +125,12 @@ func MarshalValue(v interface{}, seps []rune) (interface{}, error) {
 		if w == nil {
 			return "", nil
 		}
-		return w.Tree.Root.String(), nil
+		return templateString(w.Name(), w.Tree.Root.String()), nil
 	case *htemplate.Template:
 		if w == nil {
 			return "", nil
 		}
-		return w.Tree.Root.String(), nil
+		return templateString(w.Name(), w.Tree.Root.String()), nil
 	case *net.IPAddr:
 		if w == nil {
 			return "", nil
@@ -93,6 +141,16 @@ func MarshalValue(v interface{}, seps []rune) (interface{}, error) {
 			return "", nil
 		}
 		return w.String(), nil
+	case net.IPNet:
+		return w.String(), nil
+	case net.HardwareAddr:
+		return w.String(), nil
+	case big.Int:
+		return w.String(), nil
+	case big.Float:
+		return w.Text('g', -1), nil
+	case big.Rat:
+		return w.RatString(), nil
 
 	case encoding.TextMarshaler:
 		bts, err := w.MarshalText()
@@ -100,6 +158,20 @@ func MarshalValue(v interface{}, seps []rune) (interface{}, error) {
 			return nil, err
 		}
 		return string(bts), nil
+	case encoding.BinaryMarshaler:
+		bts, err := w.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString(bts), nil
+	case Value:
+		return w.String(), nil
+	}
+	if value := reflect.ValueOf(v); value.IsValid() && reflect.PtrTo(value.Type()).Implements(valueType) {
+		// String method with a pointer receiver.
+		ptr := reflect.New(value.Type())
+		ptr.Elem().Set(value)
+		return ptr.Interface().(Value).String(), nil
 	}
 
 	if sep == 0 {
@@ -115,7 +187,7 @@ func MarshalValue(v interface{}, seps []rune) (interface{}, error) {
 		lst = make([]string, n)
 		for i := 0; i < n; i++ {
 			v := value.Index(i)
-			w, err := MarshalValue(v.Interface(), seps)
+			w, err := marshalValue(v.Interface(), seps, ff, tags)
 			if err != nil {
 				return nil, err
 			}
@@ -129,12 +201,19 @@ func MarshalValue(v interface{}, seps []rune) (interface{}, error) {
 		} else {
 			sep = MapKeySeparator
 		}
+		if isStructMap(value.Type()) {
+			var err error
+			if lst, err = marshalStructMap(value, sep, ff, tags); err != nil {
+				return nil, err
+			}
+			break
+		}
 		keycsv := newcsvreadwriter(sep)
 		keys := value.MapKeys()
 		lst = make([]string, len(keys))
 		for i, key := range keys {
 			v := value.MapIndex(key)
-			w, err := MarshalValue(v.Interface(), seps)
+			w, err := marshalValue(v.Interface(), seps, ff, tags)
 			if err != nil {
 				return nil, err
 			}
@@ -155,6 +234,105 @@ func MarshalValue(v interface{}, seps []rune) (interface{}, error) {
 	return csv.write(lst...)
 }
 
+// HumanValue returns v with its time.Duration values, including the items of
+// slices, arrays and maps, replaced by their human readable form, e.g. 10s.
+// Other values are returned as is.
+func HumanValue(v interface{}) interface{} {
+	value := reflect.ValueOf(v)
+	if !value.IsValid() {
+		return v
+	}
+	t := value.Type()
+	if ht := humanType(t); ht != t {
+		return humanValue(value, ht).Interface()
+	}
+	return v
+}
+
+// humanType returns the type of the human readable form of the values of type t.
+func humanType(t reflect.Type) reflect.Type {
+	switch t.Kind() {
+	case reflect.Int64:
+		if t == durationType {
+			return reflect.TypeOf("")
+		}
+	case reflect.Ptr:
+		if et := humanType(t.Elem()); et != t.Elem() {
+			return reflect.PtrTo(et)
+		}
+	case reflect.Slice:
+		if et := humanType(t.Elem()); et != t.Elem() {
+			return reflect.SliceOf(et)
+		}
+	case reflect.Array:
+		if et := humanType(t.Elem()); et != t.Elem() {
+			return reflect.ArrayOf(t.Len(), et)
+		}
+	case reflect.Map:
+		if et := humanType(t.Elem()); et != t.Elem() {
+			return reflect.MapOf(t.Key(), et)
+		}
+	}
+	return t
+}
+
+// humanValue converts value to its human readable form of type ht.
+func humanValue(value reflect.Value, ht reflect.Type) reflect.Value {
+	res := reflect.New(ht).Elem()
+	switch value.Kind() {
+	case reflect.Int64:
+		res.SetString(value.Interface().(time.Duration).String())
+	case reflect.Ptr:
+		if !value.IsNil() {
+			res.Set(reflect.New(ht.Elem()))
+			res.Elem().Set(humanValue(value.Elem(), ht.Elem()))
+		}
+	case reflect.Slice:
+		if value.IsNil() {
+			break
+		}
+		res.Set(reflect.MakeSlice(ht, value.Len(), value.Len()))
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			res.Index(i).Set(humanValue(value.Index(i), ht.Elem()))
+		}
+	case reflect.Map:
+		if value.IsNil() {
+			break
+		}
+		res.Set(reflect.MakeMapWithSize(ht, value.Len()))
+		for _, key := range value.MapKeys() {
+			res.SetMapIndex(key, humanValue(value.MapIndex(key), ht.Elem()))
+		}
+	}
+	return res
+}
+
+// marshalStructMap returns the items of the map with struct values value as
+// <key>.<field><sep><value>, sorted.
+func marshalStructMap(value reflect.Value, sep rune, ff FloatFormat, tags *tagIDs) ([]string, error) {
+	var lst []string
+	for _, key := range value.MapKeys() {
+		elem := reflect.New(value.Type().Elem())
+		elem.Elem().Set(value.MapIndex(key))
+		fields, err := tags.fieldsOf(elem.Interface())
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range fields {
+			w, err := marshalValue(field.Indirect(), field.seps, ff, field.tags)
+			if err != nil {
+				return nil, errors.Errorf("%v.%s: %v", key.Interface(), field.Name(), err)
+			}
+			item := fmt.Sprintf("%v.%s%c%v", key.Interface(), field.Name(), sep, w)
+			lst = append(lst, item)
+		}
+	}
+	sort.Strings(lst)
+	return lst, nil
+}
+
 // From html/template/content.go
 // Copyright 2011 The Go Authors. All rights reserved.
 // indirect returns the value, after dereferencing as many times
@@ -173,3 +351,46 @@ func indirect(a interface{}) interface{} {
 	}
 	return v.Interface()
 }
+
+// TemplateFilePrefix prefixes the template values referencing a file,
+// e.g. "@path/to/file.tmpl", instead of defining the template text.
+const TemplateFilePrefix = "@"
+
+// templateString returns the template file reference if the template was
+// read from a file, or its text otherwise.
+func templateString(name, text string) string {
+	switch {
+	case strings.HasPrefix(name, TemplateFilePrefix):
+		return name
+	case strings.HasPrefix(text, TemplateFilePrefix):
+		return TemplateFilePrefix + text
+	}
+	return text
+}
+
+// TimeLayoutSeparator separates the layouts of the "time" struct tag flag.
+const TimeLayoutSeparator = "|"
+
+// parseTime parses s using the first matching layout of the layouts separated
+// by TimeLayoutSeparator.
+func parseTime(s, layouts string) (time.Time, error) {
+	var err error
+	for _, layout := range strings.Split(layouts, TimeLayoutSeparator) {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if strings.Contains(layouts, TimeLayoutSeparator) {
+		return time.Time{}, errors.Errorf("cannot parse %q with layouts %s", s, layouts)
+	}
+	return time.Time{}, err
+}
+
+// formatTime formats t using the first of the layouts separated by TimeLayoutSeparator.
+func formatTime(t time.Time, layouts string) string {
+	if i := strings.Index(layouts, TimeLayoutSeparator); i >= 0 {
+		layouts = layouts[:i]
+	}
+	return t.Format(layouts)
+}
@@ -0,0 +1,132 @@
+package structs
+
+import (
+	"encoding"
+	"reflect"
+)
+
+// DefaultItemSeparators lists the separators used for the items of
+// nested slice and map fields for which none was explicitly set.
+// DefaultKeySeparators is its counterpart for map keys.
+// Separators already in use by the field are skipped.
+var (
+	DefaultItemSeparators = []rune{SliceSeparator, ';', '|', '/'}
+	DefaultKeySeparators  = []rune{MapKeySeparator, '=', '#'}
+)
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+	valueType = reflect.TypeOf((*Value)(nil)).Elem()
+)
+
+// Value is implemented by types setting themselves from a string, such as the
+// flag.Value and pflag.Value types. It is used when the value type does not
+// implement encoding.TextMarshaler and encoding.TextUnmarshaler.
+type Value interface {
+	String() string
+	Set(string) error
+}
+
+// IsScalar returns whether the type t is (de)serialized as a single value.
+func IsScalar(t reflect.Type) bool {
+	return isScalar(t)
+}
+
+// isScalar returns whether the type t is (de)serialized as a single value.
+func isScalar(t reflect.Type) bool {
+	switch t {
+	case durationType, timeType, urlType, texttemplateType, htmltemplateType,
+		regexpType, ipaddrType, ipnetType, ipnetValueType, hwaddrType:
+		return true
+	}
+	return t.Implements(textMarshalerType) ||
+		reflect.PtrTo(t).Implements(textUnmarshalerType) ||
+		t.Implements(binaryMarshalerType) ||
+		reflect.PtrTo(t).Implements(binaryUnmarshalerType) ||
+		reflect.PtrTo(t).Implements(valueType)
+}
+
+// SeparatorsLen returns the number of separators required to (de)serialize
+// a value of type t as a single string.
+//
+// Separators are ordered from the outermost level to the innermost one:
+//  - a slice or an array uses one separator for its items
+//  - a map uses one separator for its items, then one for its keys
+func SeparatorsLen(t reflect.Type) int {
+	if isScalar(t) {
+		return 0
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return 1 + SeparatorsLen(t.Elem())
+	case reflect.Map:
+		return 2 + SeparatorsLen(t.Elem())
+	}
+	return 0
+}
+
+// separators completes seps with default separators so that there is one
+// for every nesting level of the type t.
+// seps is either empty or already complete when set from struct tags.
+func separators(t reflect.Type, seps []rune) []rune {
+	n := SeparatorsLen(t)
+	if len(seps) >= n {
+		return seps
+	}
+	res := make([]rune, len(seps), n)
+	copy(res, seps)
+	for i := len(seps); i < n; i++ {
+		defaults := DefaultItemSeparators
+		if isKeySeparator(t, i) {
+			defaults = DefaultKeySeparators
+		}
+		for _, sep := range defaults {
+			if !containsRune(res, sep) {
+				res = append(res, sep)
+				break
+			}
+		}
+		if len(res) == i {
+			// No default left.
+			break
+		}
+	}
+	return res
+}
+
+// isKeySeparator returns whether the i-th separator of the type t
+// is used for map keys.
+func isKeySeparator(t reflect.Type, i int) bool {
+	for !isScalar(t) {
+		switch t.Kind() {
+		case reflect.Slice, reflect.Array:
+			i--
+		case reflect.Map:
+			if i == 1 {
+				return true
+			}
+			i -= 2
+		default:
+			return false
+		}
+		if i < 0 {
+			return false
+		}
+		t = t.Elem()
+	}
+	return false
+}
+
+func containsRune(runes []rune, r rune) bool {
+	for _, rr := range runes {
+		if rr == r {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package structs
 
 import (
+	"fmt"
 	"reflect"
 
 	"github.com/pkg/errors"
@@ -10,6 +11,11 @@ import (
 // If v is a string but value is not, then Set attempts to deserialize it
 // using UnmarshalValue().
 func Set(value reflect.Value, v interface{}, seps []rune) error {
+	return set(value, v, seps, nil)
+}
+
+// set is Set decomposing the struct values of maps with tags.
+func set(value reflect.Value, v interface{}, seps []rune, tags *tagIDs) error {
 	if !value.CanSet() {
 		return errCannotSet
 	}
@@ -21,7 +27,12 @@ func Set(value reflect.Value, v interface{}, seps []rune) error {
 		value.Set(zero)
 		return nil
 	case string:
-		return UnmarshalValue(value, v, seps)
+		return unmarshalValue(value, v, seps, tags)
+	case int64, uint64, float64:
+		if t := value.Type(); t == bigIntType || t == bigFloatType || t == bigRatType {
+			// Numbers decoded by the FromIO sources.
+			return unmarshalValue(value, fmt.Sprint(v), seps, tags)
+		}
 	}
 
 	val := reflect.ValueOf(v)
@@ -49,8 +60,8 @@ func convert(a, b reflect.Value) (_ reflect.Value, err error) {
 
 // setFromMap populates value, which must be a pointer to a struct,
 // with values corresponding to its fields by name.
-func setFromMap(value interface{}, values map[string]interface{}) error {
-	fields, err := fieldsOf(value, "", "")
+func setFromMap(value interface{}, values map[string]interface{}, tags *tagIDs) error {
+	fields, err := tags.fieldsOf(value)
 	if err != nil {
 		return err
 	}
@@ -67,3 +78,42 @@ func setFromMap(value interface{}, values map[string]interface{}) error {
 	}
 	return nil
 }
+
+// isStructMap returns whether or not t is a map with struct values,
+// which are not serialized as single values.
+func isStructMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Elem().Kind() == reflect.Struct && !isScalar(t.Elem())
+}
+
+// setMap replaces the map value by the one built from values.
+// The items of maps with struct values are maps of the struct fields by name.
+func setMap(value reflect.Value, values map[string]interface{}, seps []rune, tags *tagIDs) error {
+	t := value.Type()
+	if len(seps) > 2 {
+		seps = seps[2:]
+	} else {
+		seps = nil
+	}
+	m := reflect.MakeMapWithSize(t, len(values))
+	for k, v := range values {
+		key := reflect.New(t.Key()).Elem()
+		if err := UnmarshalValue(key, k, nil); err != nil {
+			return errors.Errorf("%s: %v", k, err)
+		}
+		elem := reflect.New(t.Elem())
+		if isStructMap(t) {
+			fields, ok := v.(map[string]interface{})
+			if !ok {
+				return errors.Errorf("%s: cannot assign a non map to a struct", k)
+			}
+			if err := setFromMap(elem.Interface(), fields, tags); err != nil {
+				return errors.Errorf("%s: %v", k, err)
+			}
+		} else if err := set(elem.Elem(), v, seps, tags); err != nil {
+			return errors.Errorf("%s: %v", k, err)
+		}
+		m.SetMapIndex(key, elem.Elem())
+	}
+	value.Set(m)
+	return nil
+}
@@ -3,6 +3,7 @@ package structs
 import (
 	"fmt"
 	htemplate "html/template"
+	"math/big"
 	"net"
 	"net/url"
 	"reflect"
@@ -36,20 +37,50 @@ var (
 	durationType     = reflect.TypeOf(time.Second)
 	timeType         = reflect.TypeOf(time.Time{})
 	urlType          = reflect.TypeOf(new(url.URL))
+	urlValueType     = reflect.TypeOf(url.URL{})
 	texttemplateType = reflect.TypeOf(template.New(""))
 	htmltemplateType = reflect.TypeOf(htemplate.New(""))
 	regexpType       = reflect.TypeOf(regexp.MustCompile("."))
 	ipaddrType       = reflect.TypeOf(new(net.IPAddr))
 	ipnetType        = reflect.TypeOf(new(net.IPNet))
+	ipnetValueType   = reflect.TypeOf(net.IPNet{})
+	hwaddrType       = reflect.TypeOf(net.HardwareAddr(nil))
+	bigIntType       = reflect.TypeOf(big.Int{})
+	bigFloatType     = reflect.TypeOf(big.Float{})
+	bigRatType       = reflect.TypeOf(big.Rat{})
 )
 
+// groupType is the interface implemented by the named struct fields processed
+// as groups of fields instead of single values, i.e. construct.Config.
+var groupType = reflect.TypeOf((*interface {
+	Init() error
+	Usage(string) string
+})(nil)).Elem()
+
+// isGroup returns whether or not the named struct field type t is a group of fields.
+func isGroup(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(groupType) && !isScalar(t)
+}
+
+// pointerTypes lists the supported types which values are pointers.
+var pointerTypes = map[reflect.Type]bool{
+	urlType:          true,
+	texttemplateType: true,
+	htmltemplateType: true,
+	regexpType:       true,
+	ipaddrType:       true,
+	ipnetType:        true,
+}
+
 // NewStruct recursively decomposes the input struct into its fields
 // and embedded structs.
 // Fields tags with "-" will be skipped.
 // Fields tags with a non empty value will be renamed to that value.
+// Fields without a name in their tag are named after the first of the
+// nametags setting it, if any.
 //
 // The input must be a pointer to a struct.
-func NewStruct(s interface{}, tagid, septagid string) (*StructStruct, error) {
+func NewStruct(s interface{}, tagid, septagid string, nametags ...string) (*StructStruct, error) {
 	if s, ok := s.(*StructStruct); ok {
 		return s, nil
 	}
@@ -61,7 +92,7 @@ func NewStruct(s interface{}, tagid, septagid string) (*StructStruct, error) {
 	if v.Elem().Kind() != reflect.Struct {
 		return nil, errNoStruct
 	}
-	fields, err := fieldsOf(s, tagid, septagid)
+	fields, err := fieldsOf(s, tagid, septagid, nametags...)
 	if err != nil {
 		return nil, err
 	}
@@ -80,8 +111,27 @@ type StructField struct {
 	field    *reflect.StructField
 	value    reflect.Value
 	tag      reflect.StructTag
+	flags    map[string]string
 	seps     []rune
 	embedded *StructStruct
+	tags     *tagIDs
+}
+
+// tagIDs holds the struct tags used to decompose the structs, so that the
+// struct values of maps and slices are decomposed the same way.
+type tagIDs struct {
+	tagid    string
+	septagid string
+	nametags []string
+}
+
+// fieldsOf returns the fields of v decomposed with the struct tags.
+// A nil t does not use any struct tag.
+func (t *tagIDs) fieldsOf(v interface{}) ([]*StructField, error) {
+	if t == nil {
+		return fieldsOf(v, "", "")
+	}
+	return fieldsOf(v, t.tagid, t.septagid, t.nametags...)
 }
 
 // Name returns the field name.
@@ -94,11 +144,29 @@ func (f *StructField) Embedded() *StructStruct {
 	return f.embedded
 }
 
+// Anonymous returns whether or not the field is an embedded Go struct field,
+// which methods are promoted to its parent struct.
+func (f *StructField) Anonymous() bool {
+	return f.field.Anonymous
+}
+
 // Set assigns the given value to the field.
 // If the value is a string but the field is not,
 // then its value is deserialized using encoding.Unmarshaler
 // or in a best effort way.
 func (f *StructField) Set(v interface{}) error {
+	if f.IsPointer() {
+		return f.setPointer(v)
+	}
+	if s, ok := v.(string); ok && f.value.Type() == timeType {
+		if layouts, ok := f.Flag("time"); ok {
+			t, err := parseTime(s, layouts)
+			if err != nil {
+				return errors.Errorf("%v: %v", f, err)
+			}
+			v = t
+		}
+	}
 	switch v := v.(type) {
 	case []interface{}:
 		if f.value.Kind() != reflect.Slice {
@@ -111,17 +179,23 @@ func (f *StructField) Set(v interface{}) error {
 			if !v.CanAddr() {
 				v = v.Addr()
 			}
-			if err := Set(v, item, nil); err != nil {
+			if err := set(v, item, nil, f.tags); err != nil {
 				return errors.Errorf("%v: %v", f, err)
 			}
 		}
 		f.value.Set(sliceValues)
 	case map[string]interface{}:
+		if f.value.Kind() == reflect.Map {
+			if err := setMap(f.value, v, f.seps, f.tags); err != nil {
+				return errors.Errorf("%v: %v", f, err)
+			}
+			return nil
+		}
 		if f.value.Kind() != reflect.Struct {
 			return errors.Errorf("%v: cannot assign a map to a non struct field", f)
 		}
-		s := f.value.Addr()
-		return setFromMap(s, v)
+		s := f.value.Addr().Interface()
+		return setFromMap(s, v, f.tags)
 	case []map[string]interface{}:
 		if f.value.Kind() != reflect.Slice {
 			return errors.Errorf("%v: cannot assign a slice map to a non slice field", f)
@@ -136,40 +210,133 @@ func (f *StructField) Set(v interface{}) error {
 			if !v.CanAddr() {
 				v = v.Addr()
 			}
-			if err := setFromMap(v.Interface(), item); err != nil {
+			if err := setFromMap(v.Addr().Interface(), item, f.tags); err != nil {
 				return errors.Errorf("%v: %v", f, err)
 			}
 		}
 		f.value.Set(sliceValues)
 	default:
-		return Set(f.value, v, f.seps)
+		return set(f.value, v, f.seps, f.tags)
 	}
 	return nil
 }
 
+// setPointer assigns v to the value pointed to by the field, allocating it.
+// A nil v resets the pointer.
+func (f *StructField) setPointer(v interface{}) error {
+	t := f.value.Type()
+	switch {
+	case v == nil:
+		f.value.Set(reflect.Zero(t))
+		return nil
+	case reflect.TypeOf(v) == t:
+		f.value.Set(reflect.ValueOf(v))
+		return nil
+	}
+	ptr := reflect.New(t.Elem())
+	if !f.value.IsNil() {
+		// Keep the current value for partial updates.
+		ptr.Elem().Set(f.value.Elem())
+	}
+	elem := *f
+	elem.value = ptr.Elem()
+	if err := elem.Set(v); err != nil {
+		return err
+	}
+	f.value.Set(ptr)
+	return nil
+}
+
 // Interface returns the interface value of the field.
 func (f *StructField) Interface() interface{} {
 	return f.value.Interface()
 }
 
+// IsPointer returns whether the field is a pointer to a value, as opposed to
+// the supported pointer types such as *url.URL.
+// A nil pointer means that the field value was not provided.
+func (f *StructField) IsPointer() bool {
+	t := f.value.Type()
+	return t.Kind() == reflect.Ptr && !pointerTypes[t]
+}
+
+// Indirect returns the interface value of the field, dereferenced if it
+// is a pointer field. The zero value of the pointed type is returned for nil pointers.
+func (f *StructField) Indirect() interface{} {
+	if !f.IsPointer() {
+		return f.value.Interface()
+	}
+	if f.value.IsNil() {
+		return reflect.Zero(f.value.Type().Elem()).Interface()
+	}
+	return f.value.Elem().Interface()
+}
+
 // PtrValue returns the interface pointer value of the field.
 func (f *StructField) PtrValue() interface{} {
 	return f.value.Addr().Interface()
 }
 
+// Type returns the type of the field.
+func (f *StructField) Type() reflect.Type {
+	return f.value.Type()
+}
+
 // Tag returns the tags defined on the field.
 func (f *StructField) Tag() reflect.StructTag {
 	return f.tag
 }
 
+// Flag returns the value of the struct tag flag and whether or not it is set.
+func (f *StructField) Flag(name string) (string, bool) {
+	v, ok := f.flags[name]
+	return v, ok
+}
+
+// URL returns the field value as a URL if its type is convertible to url.URL,
+// or a pointer to it, and nil otherwise or if the pointer is nil.
+func (f *StructField) URL() *url.URL {
+	v := f.value
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.Type().ConvertibleTo(urlValueType) {
+		return nil
+	}
+	u := v.Convert(urlValueType).Interface().(url.URL)
+	return &u
+}
+
+// isURL returns whether or not t is convertible to url.URL or is a pointer to such a type.
+func isURL(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.ConvertibleTo(urlValueType)
+}
+
 // Separators returns the field separators.
 func (f *StructField) Separators() []rune {
 	return f.seps
 }
 
-// MarshalValue returns the field value marshaled by MarshalValue().
+// MarshalValue returns the field value marshaled by MarshalValue(),
+// dereferenced for pointer fields, using the float format set by the "float" struct tag flag
+// and the time layout set by the "time" struct tag flag if any.
 func (f *StructField) MarshalValue() (interface{}, error) {
-	return MarshalValue(f.Interface(), f.seps)
+	ff := DefaultFloatFormat
+	if s, ok := f.Flag("float"); ok {
+		ff, _ = parseFloatFormat(s)
+	}
+	if layouts, ok := f.Flag("time"); ok {
+		if t, ok := f.Indirect().(time.Time); ok {
+			return formatTime(t, layouts), nil
+		}
+	}
+	return marshalValue(f.Indirect(), f.seps, ff, f.tags)
 }
 
 // StructStruct represents a decomposed struct.
@@ -328,7 +495,8 @@ func (s *StructStruct) Call(m string, args []interface{}) ([]interface{}, bool)
 }
 
 // List the fields of the input which must be a pointer to a struct.
-func fieldsOf(v interface{}, tagid, septagid string) (res []*StructField, err error) {
+func fieldsOf(v interface{}, tagid, septagid string, nametags ...string) (res []*StructField, err error) {
+	tags := &tagIDs{tagid, septagid, nametags}
 	value := reflect.ValueOf(v).Elem()
 	vType := value.Type()
 	for i, n := 0, value.NumField(); i < n; i++ {
@@ -345,24 +513,71 @@ func fieldsOf(v interface{}, tagid, septagid string) (res []*StructField, err er
 		tagvalues := strings.Split(tagval, ",")
 
 		// The name is the first item in a coma separated list.
-		switch tagvalues[0] {
+		// If not set, it is taken from the first name tag setting it.
+		name := tagvalues[0]
+		for _, nametag := range nametags {
+			if name != "" {
+				break
+			}
+			name = strings.Split(tag.Get(nametag), ",")[0]
+		}
+		switch name {
 		case "":
 		case "-":
 			continue
 		default:
 			// Set the field name according to the struct tag.
-			fname = tagvalues[0]
+			fname = name
 		}
 
 		// Apply the tag flags.
 		var inline bool
+		var flags map[string]string
 		for _, flag := range tagvalues[1:] {
+			var flagval string
+			if i := strings.IndexByte(flag, '='); i >= 0 {
+				flag, flagval = flag[:i], flag[i+1:]
+			}
 			switch flag {
 			case "inline":
 				inline = true
+			case "sensitive", "secret", "noenv", "noio", "explicit", "required", "count", "hidden":
+			case "float":
+				if _, err := parseFloatFormat(flagval); err != nil {
+					return nil, errors.Errorf("%s: %v", fname, err)
+				}
+			case "secretref":
+				if t := value.Type(); t.Kind() != reflect.String && (t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.String) {
+					return nil, errors.Errorf("%s: secret reference on non string field", fname)
+				}
+			case "merge":
+				switch flagval {
+				case "replace", "append", "union":
+				default:
+					return nil, errors.Errorf("%s: invalid merge strategy %q", fname, flagval)
+				}
+				if k := value.Kind(); k != reflect.Slice && k != reflect.Map {
+					return nil, errors.Errorf("%s: merge strategy on non slice or map field", fname)
+				}
+			case "time", "url":
+				switch t := value.Type(); {
+				case flag == "time" && t != timeType && t != reflect.PtrTo(timeType):
+					return nil, errors.Errorf("%s: time layout on non time.Time field", fname)
+				case flag == "url" && !isURL(t):
+					return nil, errors.Errorf("%s: url constraints on non URL field", fname)
+				}
+				fallthrough
+			case "deprecated", "choices", "main":
+				if flagval == "" {
+					return nil, errors.Errorf("%s: missing value for %s", fname, flag)
+				}
 			default:
 				return nil, errors.Errorf("unkown tag flag %s", flag)
 			}
+			if flags == nil {
+				flags = make(map[string]string)
+			}
+			flags[flag] = flagval
 		}
 
 		var fs *StructStruct
@@ -379,10 +594,10 @@ func fieldsOf(v interface{}, tagid, septagid string) (res []*StructField, err er
 				continue
 			}
 
-			if field.Anonymous {
-				// Embedded field: recursively descend into its fields.
+			if field.Anonymous || isGroup(field.Type) {
+				// Embedded field or group: recursively descend into its fields.
 				v := value.Addr().Interface()
-				fields, err := fieldsOf(v, tagid, septagid)
+				fields, err := fieldsOf(v, tagid, septagid, nametags...)
 				if err != nil {
 					return nil, errors.Errorf("%s: %v", fname, err)
 				}
@@ -391,7 +606,12 @@ func fieldsOf(v interface{}, tagid, septagid string) (res []*StructField, err er
 			}
 		}
 		seps := []rune(tag.Get(septagid))
-		res = append(res, &StructField{fname, &field, value, tag, seps, fs})
+		if n := SeparatorsLen(field.Type); len(seps) > 0 && len(seps) != n {
+			return nil, errors.Errorf("%s: invalid separators %q: %d expected for type %v",
+				fname, string(seps), n, field.Type)
+		}
+		seps = separators(field.Type, seps)
+		res = append(res, &StructField{fname, &field, value, tag, flags, seps, fs, tags})
 	}
 	return
 }
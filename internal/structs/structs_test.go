@@ -0,0 +1,443 @@
+package structs
+
+import (
+	"bytes"
+	htemplate "html/template"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestNestedSeparators(t *testing.T) {
+	type T struct {
+		M map[string][]map[int]string `sep:" :,;="`
+	}
+	var v T
+	s, err := NewStruct(&v, "cfg", "sep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := s.Lookup("M")
+
+	const in = "a:1=x;2=y,3=z b:4=w"
+	if err := field.Set(in); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]map[int]string{
+		"a": {{1: "x", 2: "y"}, {3: "z"}},
+		"b": {{4: "w"}},
+	}
+	if !reflect.DeepEqual(v.M, want) {
+		t.Fatalf("got %v; expected %v", v.M, want)
+	}
+
+	out, err := field.MarshalValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out, in; got != want {
+		t.Errorf("got %q; expected %q", got, want)
+	}
+}
+
+func TestDefaultNestedSeparators(t *testing.T) {
+	type T struct {
+		M map[string][]int
+	}
+	var v T
+	s, err := NewStruct(&v, "cfg", "sep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := s.Lookup("M")
+	if got, want := string(field.Separators()), ",:;"; got != want {
+		t.Fatalf("got %q; expected %q", got, want)
+	}
+
+	const in = "a:1;2,b:3"
+	if err := field.Set(in); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]int{"a": {1, 2}, "b": {3}}
+	if !reflect.DeepEqual(v.M, want) {
+		t.Fatalf("got %v; expected %v", v.M, want)
+	}
+}
+
+func TestSeparatorsLen(t *testing.T) {
+	for _, tc := range []struct {
+		v   interface{}
+		err bool
+	}{
+		{&struct {
+			S []int `sep:";"`
+		}{}, false},
+		{&struct {
+			M map[string]int `sep:";="`
+		}{}, false},
+		{&struct {
+			M map[string][]int `sep:" :,"`
+		}{}, false},
+		{&struct {
+			I int `sep:","`
+		}{}, true},
+		{&struct {
+			S []int `sep:";,"`
+		}{}, true},
+		{&struct {
+			M map[string]int `sep:";"`
+		}{}, true},
+		{&struct {
+			M map[string][]int `sep:" :"`
+		}{}, true},
+	} {
+		_, err := NewStruct(tc.v, "cfg", "sep")
+		if got, want := err != nil, tc.err; got != want {
+			t.Errorf("%T: got error %v", tc.v, err)
+		}
+	}
+}
+
+func TestFloatFormat(t *testing.T) {
+	type T struct {
+		F  float64
+		FE float64 `cfg:",float=e2"`
+		F2 float32 `cfg:",float=f2"`
+		L  []float64
+	}
+	v := T{1e6, 1e6, 0.5, []float64{1e6, 0.25}}
+	s, err := NewStruct(&v, "cfg", "sep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, want string
+	}{
+		{"F", "1000000"},
+		{"FE", "1.00e+06"},
+		{"F2", "0.50"},
+		{"L", "1000000,0.25"},
+	} {
+		got, err := s.Lookup(tc.name).MarshalValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %v; expected %s", tc.name, got, tc.want)
+		}
+	}
+
+	type invalid struct {
+		F float64 `cfg:",float=z"`
+	}
+	if _, err := NewStruct(&invalid{}, "cfg", "sep"); err == nil {
+		t.Error("expected error on invalid float format")
+	}
+}
+
+// binaryPoint implements encoding.BinaryMarshaler and encoding.BinaryUnmarshaler.
+type binaryPoint struct{ X, Y byte }
+
+func (p binaryPoint) MarshalBinary() ([]byte, error) { return []byte{p.X, p.Y}, nil }
+
+func (p *binaryPoint) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return errors.Errorf("invalid point length %d", len(data))
+	}
+	p.X, p.Y = data[0], data[1]
+	return nil
+}
+
+func TestBinaryMarshaler(t *testing.T) {
+	type T struct {
+		P binaryPoint
+		L []binaryPoint
+	}
+	var v T
+	s, err := NewStruct(&v, "cfg", "sep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Lookup("P").Set("AQI="); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Lookup("L").Set("AwQ=,BQY="); err != nil {
+		t.Fatal(err)
+	}
+	want := T{binaryPoint{1, 2}, []binaryPoint{{3, 4}, {5, 6}}}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %v; expected %v", v, want)
+	}
+
+	for name, want := range map[string]string{"P": "AQI=", "L": "AwQ=,BQY="} {
+		got, err := s.Lookup(name).MarshalValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got %v; expected %s", name, got, want)
+		}
+	}
+
+	if err := s.Lookup("P").Set("AQ=="); err == nil {
+		t.Error("expected an error on invalid binary data")
+	}
+}
+
+func TestNetTypes(t *testing.T) {
+	type T struct {
+		IP   net.IP
+		IPs  []net.IP
+		MAC  net.HardwareAddr
+		MACs []net.HardwareAddr
+		Addr *net.IPAddr
+		Net  net.IPNet
+		Nets []*net.IPNet
+	}
+	var v T
+	s, err := NewStruct(&v, "cfg", "sep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, in string
+	}{
+		{"IP", "10.0.0.1"},
+		{"IPs", "10.0.0.1,::1"},
+		{"MAC", "00:11:22:33:44:55"},
+		{"MACs", "00:11:22:33:44:55,66:77:88:99:aa:bb"},
+		{"Addr", "fe80::1%eth0"},
+		{"Net", "10.0.0.0/8"},
+		{"Nets", "10.0.0.0/8,192.168.0.0/16"},
+	} {
+		field := s.Lookup(tc.name)
+		if err := field.Set(tc.in); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		out, err := field.MarshalValue()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if out != tc.in {
+			t.Errorf("%s: got %v; expected %s", tc.name, out, tc.in)
+		}
+	}
+	if v.Addr.Zone != "eth0" || v.MACs[1][5] != 0xbb || !v.Net.Contains(net.IPv4(10, 1, 2, 3)) {
+		t.Errorf("invalid values: %+v", v)
+	}
+
+	for name, in := range map[string]string{"MAC": "00:11", "Addr": "x", "Net": "10.0.0.0"} {
+		if err := s.Lookup(name).Set(in); err == nil {
+			t.Errorf("%s: expected an error for %q", name, in)
+		}
+	}
+}
+
+func TestTimeLayout(t *testing.T) {
+	type T struct {
+		Date time.Time  `cfg:",time=2006-01-02|2006-01-02 15:04"`
+		Ptr  *time.Time `cfg:",time=15:04"`
+	}
+	var v T
+	s, err := NewStruct(&v, "cfg", "sep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Lookup("Date").Set("2020-03-04 05:06"); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2020, 3, 4, 5, 6, 0, 0, time.UTC); !v.Date.Equal(want) {
+		t.Errorf("got %v; expected %v", v.Date, want)
+	}
+	if got, err := s.Lookup("Date").MarshalValue(); err != nil || got != "2020-03-04" {
+		t.Errorf("got %v (%v); expected 2020-03-04", got, err)
+	}
+	if err := s.Lookup("Ptr").Set("12:30"); err != nil {
+		t.Fatal(err)
+	}
+	if v.Ptr == nil || v.Ptr.Hour() != 12 || v.Ptr.Minute() != 30 {
+		t.Errorf("invalid time: %v", v.Ptr)
+	}
+	if err := s.Lookup("Date").Set("04/03/2020"); err == nil {
+		t.Error("expected an error on invalid date")
+	}
+
+	type invalid struct {
+		D time.Duration `cfg:",time=15:04"`
+	}
+	if _, err := NewStruct(&invalid{}, "cfg", "sep"); err == nil {
+		t.Error("expected an error on non time field")
+	}
+}
+
+func TestTemplateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "construct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "mail.tmpl")
+	if err := ioutil.WriteFile(fname, []byte("Hello {{.}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	type T struct {
+		Text *template.Template
+		HTML *htemplate.Template
+	}
+	var v T
+	s, err := NewStruct(&v, "cfg", "sep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Text", "HTML"} {
+		field := s.Lookup(name)
+		if err := field.Set("@" + fname); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := field.MarshalValue(); err != nil || got != "@"+fname {
+			t.Errorf("%s: got %v (%v); expected @%s", name, got, err, fname)
+		}
+		if err := field.Set("@@{{.}}"); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := field.MarshalValue(); err != nil || got != "@@{{.}}" {
+			t.Errorf("%s: got %v (%v); expected @@{{.}}", name, got, err)
+		}
+		if err := field.Set("@" + filepath.Join(dir, "missing")); err == nil {
+			t.Errorf("%s: expected an error on missing file", name)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := s.Lookup("Text").Set("@" + fname); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Text.Execute(&buf, "world"); err != nil || buf.String() != "Hello world" {
+		t.Errorf("got %q (%v); expected %q", buf.String(), err, "Hello world")
+	}
+}
+
+func TestBigTypes(t *testing.T) {
+	type T struct {
+		Int   *big.Int
+		Float big.Float
+		Rat   *big.Rat
+	}
+	var v T
+	s, err := NewStruct(&v, "cfg", "sep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{"Int", "123456789012345678901234567890", "123456789012345678901234567890"},
+		{"Int", int64(-42), "-42"},
+		{"Float", "3.14159265358979323846264338327950288", "3.14159265358979323846264338327950288"},
+		{"Float", 1.5, "1.5"},
+		{"Rat", "1/3", "1/3"},
+		{"Rat", "0.25", "1/4"},
+		{"Rat", uint64(7), "7"},
+	} {
+		field := s.Lookup(tc.name)
+		if err := field.Set(tc.in); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got, err := field.MarshalValue(); err != nil || got != tc.want {
+			t.Errorf("%s: got %v (%v); expected %s", tc.name, got, err, tc.want)
+		}
+	}
+	if err := s.Lookup("Int").Set("1.5"); err == nil {
+		t.Error("expected an error on invalid integer")
+	}
+}
+
+func TestStructMap(t *testing.T) {
+	type Listener struct {
+		Addr string
+		TLS  bool
+	}
+	type T struct {
+		M map[string]Listener `sep:",="`
+	}
+	var v T
+	s, err := NewStruct(&v, "cfg", "sep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := s.Lookup("M")
+	if err := field.Set("web.addr=:80,web.tls=true,api.Addr=:443"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Listener{"web": {":80", true}, "api": {":443", false}}
+	if !reflect.DeepEqual(v.M, want) {
+		t.Errorf("got %+v; expected %+v", v.M, want)
+	}
+	if got, err := field.MarshalValue(); err != nil || got != "api.Addr=:443,api.TLS=false,web.Addr=:80,web.TLS=true" {
+		t.Errorf("got %v (%v)", got, err)
+	}
+
+	if err := field.Set(map[string]interface{}{"db": map[string]interface{}{"Addr": ":5432"}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]Listener{"db": {":5432", false}}; !reflect.DeepEqual(v.M, want) {
+		t.Errorf("got %+v; expected %+v", v.M, want)
+	}
+
+	for _, s := range []string{"web=:80", "web.port=80", ".addr"} {
+		if err := field.Set(s); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
+
+func TestStructMapTags(t *testing.T) {
+	type Listener struct {
+		Addr    string `cfg:"address"`
+		Ignored string `cfg:"-"`
+		Port    int    `json:"port"`
+	}
+	type T struct {
+		M map[string]Listener `sep:",="`
+	}
+	var v T
+	s, err := NewStruct(&v, "cfg", "sep", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := s.Lookup("M")
+	if err := field.Set("web.address=:80,web.port=80"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Listener{"web": {Addr: ":80", Port: 80}}
+	if !reflect.DeepEqual(v.M, want) {
+		t.Errorf("got %+v; expected %+v", v.M, want)
+	}
+	if got, err := field.MarshalValue(); err != nil || got != "web.address=:80,web.port=80" {
+		t.Errorf("got %v (%v)", got, err)
+	}
+	if err := field.Set("web.ignored=x"); err == nil {
+		t.Error("expected an error on an ignored field")
+	}
+
+	if err := field.Set(map[string]interface{}{"db": map[string]interface{}{"address": ":5432"}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]Listener{"db": {Addr: ":5432"}}; !reflect.DeepEqual(v.M, want) {
+		t.Errorf("got %+v; expected %+v", v.M, want)
+	}
+}
@@ -2,12 +2,17 @@ package structs
 
 import (
 	"encoding"
+	"encoding/base64"
 	htemplate "html/template"
+	"io/ioutil"
+	"math"
+	"math/big"
 	"net"
 	"net/url"
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
@@ -19,6 +24,11 @@ import (
 // seps is the separator list for use for each level.
 // The first one is the one for the current level.
 func UnmarshalValue(value reflect.Value, s string, seps []rune) error {
+	return unmarshalValue(value, s, seps, nil)
+}
+
+// unmarshalValue is UnmarshalValue decomposing the struct values of maps with tags.
+func unmarshalValue(value reflect.Value, s string, seps []rune, tags *tagIDs) error {
 	var sep rune
 	if len(seps) > 0 {
 		sep = seps[0]
@@ -34,14 +44,22 @@ func UnmarshalValue(value reflect.Value, s string, seps []rune) error {
 		value.Set(reflect.ValueOf(v))
 		return nil
 	case htmltemplateType:
-		v, err := htemplate.New("").Parse(s)
+		name, text, err := templateText(s)
+		if err != nil {
+			return err
+		}
+		v, err := htemplate.New(name).Parse(text)
 		if err != nil {
 			return err
 		}
 		value.Set(reflect.ValueOf(v))
 		return nil
 	case texttemplateType:
-		v, err := template.New("").Parse(s)
+		name, text, err := templateText(s)
+		if err != nil {
+			return err
+		}
+		v, err := template.New(name).Parse(text)
 		if err != nil {
 			return err
 		}
@@ -62,6 +80,18 @@ func UnmarshalValue(value reflect.Value, s string, seps []rune) error {
 		}
 		value.Set(reflect.ValueOf(v))
 		return nil
+	case bigFloatType:
+		// Keep the precision of the input.
+		prec := uint(float64(len(s)) * math.Log2(10))
+		if prec < 64 {
+			prec = 64
+		}
+		v, _, err := big.ParseFloat(s, 0, prec, big.ToNearestEven)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(v).Elem())
+		return nil
 	case durationType:
 		v, err := time.ParseDuration(s)
 		if err != nil {
@@ -70,8 +100,15 @@ func UnmarshalValue(value reflect.Value, s string, seps []rune) error {
 		value.SetInt(int64(v))
 		return nil
 	case ipaddrType:
-		v := net.ParseIP(s)
-		value.Set(reflect.ValueOf(v))
+		ip, zone := s, ""
+		if i := strings.LastIndexByte(s, '%'); i >= 0 {
+			ip, zone = s[:i], s[i+1:]
+		}
+		v := net.ParseIP(ip)
+		if v == nil {
+			return errors.Errorf("invalid IP address %q", s)
+		}
+		value.Set(reflect.ValueOf(&net.IPAddr{IP: v, Zone: zone}))
 		return nil
 	case ipnetType:
 		_, v, err := net.ParseCIDR(s)
@@ -80,11 +117,35 @@ func UnmarshalValue(value reflect.Value, s string, seps []rune) error {
 		}
 		value.Set(reflect.ValueOf(v))
 		return nil
+	case ipnetValueType:
+		_, v, err := net.ParseCIDR(s)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(*v))
+		return nil
+	case hwaddrType:
+		v, err := net.ParseMAC(s)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(v))
+		return nil
 	}
 
 	if dec, ok := ptrValue(value).Interface().(encoding.TextUnmarshaler); ok {
 		return dec.UnmarshalText([]byte(s))
 	}
+	if dec, ok := ptrValue(value).Interface().(encoding.BinaryUnmarshaler); ok {
+		bts, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		return dec.UnmarshalBinary(bts)
+	}
+	if dec, ok := ptrValue(value).Interface().(Value); ok {
+		return dec.Set(s)
+	}
 
 	switch value.Kind() {
 	default:
@@ -142,7 +203,7 @@ func UnmarshalValue(value reflect.Value, s string, seps []rune) error {
 			if v.Kind() == reflect.Ptr {
 				v = v.Elem()
 			}
-			if err := UnmarshalValue(v, s, seps); err != nil {
+			if err := unmarshalValue(v, s, seps, tags); err != nil {
 				return errors.Errorf("%s: %v", s, err)
 			}
 		}
@@ -164,7 +225,7 @@ func UnmarshalValue(value reflect.Value, s string, seps []rune) error {
 		}
 		for _, s := range values {
 			v := reflect.New(elem).Elem()
-			if err := UnmarshalValue(v, s, seps); err != nil {
+			if err := unmarshalValue(v, s, seps, tags); err != nil {
 				return errors.Errorf("%s: %v", s, err)
 			}
 			sliceValues = reflect.Append(sliceValues, v)
@@ -194,6 +255,13 @@ func UnmarshalValue(value reflect.Value, s string, seps []rune) error {
 		} else {
 			sep = MapKeySeparator
 		}
+		if isStructMap(vType) {
+			if err := unmarshalStructMap(mapValues, values, sep, tags); err != nil {
+				return err
+			}
+			value.Set(mapValues)
+			return nil
+		}
 		keyreader := newcsvreadwriter(sep)
 		for _, s := range values {
 			data, err := keyreader.read(s)
@@ -208,7 +276,7 @@ func UnmarshalValue(value reflect.Value, s string, seps []rune) error {
 				return errors.Errorf("%s: %v", s, err)
 			}
 			v := reflect.New(elemType).Elem()
-			if err := UnmarshalValue(v, data[1], seps); err != nil {
+			if err := unmarshalValue(v, data[1], seps, tags); err != nil {
 				return errors.Errorf("%s: %v", s, err)
 			}
 			mapValues.SetMapIndex(key, v)
@@ -218,6 +286,51 @@ func UnmarshalValue(value reflect.Value, s string, seps []rune) error {
 	return nil
 }
 
+// unmarshalStructMap sets the struct values of the map value from the items
+// defined as <key>.<field><sep><value>.
+// The field names are case insensitive.
+func unmarshalStructMap(value reflect.Value, items []string, sep rune, tags *tagIDs) error {
+	t := value.Type()
+	for _, item := range items {
+		j := -1
+		i := strings.IndexRune(item, sep)
+		if i > 0 {
+			j = strings.LastIndexByte(item[:i], '.')
+		}
+		if j < 0 {
+			return errors.Errorf("%s: %v", item, errInvalidMapKey)
+		}
+		key := reflect.New(t.Key()).Elem()
+		if err := UnmarshalValue(key, item[:j], nil); err != nil {
+			return errors.Errorf("%s: %v", item, err)
+		}
+		elem := reflect.New(t.Elem())
+		if v := value.MapIndex(key); v.IsValid() {
+			elem.Elem().Set(v)
+		}
+		fields, err := tags.fieldsOf(elem.Interface())
+		if err != nil {
+			return err
+		}
+		name := item[j+1 : i]
+		var field *StructField
+		for _, f := range fields {
+			if strings.EqualFold(f.Name(), name) {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			return errors.Errorf("%s: unknown field %s", item, name)
+		}
+		if err := field.Set(item[i+len(string(sep)):]); err != nil {
+			return errors.Errorf("%s: %v", item, err)
+		}
+		value.SetMapIndex(key, elem.Elem())
+	}
+	return nil
+}
+
 // ptrValue returns the interface of the pointer value.
 func ptrValue(value reflect.Value) reflect.Value {
 	if value.Kind() != reflect.Ptr && value.CanAddr() {
@@ -225,3 +338,21 @@ func ptrValue(value reflect.Value) reflect.Value {
 	}
 	return value
 }
+
+// templateText returns the name and text of the template defined by s.
+// If s references a file, i.e. starts with TemplateFilePrefix, the text is
+// read from it and the name is s so that it is preserved when marshaled.
+// A doubled TemplateFilePrefix escapes a template text starting with it.
+func templateText(s string) (name, text string, err error) {
+	switch {
+	case strings.HasPrefix(s, TemplateFilePrefix+TemplateFilePrefix):
+		return "", s[len(TemplateFilePrefix):], nil
+	case !strings.HasPrefix(s, TemplateFilePrefix):
+		return "", s, nil
+	}
+	buf, err := ioutil.ReadFile(s[len(TemplateFilePrefix):])
+	if err != nil {
+		return "", "", errors.Errorf("template: %v", err)
+	}
+	return s, string(buf), nil
+}
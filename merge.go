@@ -0,0 +1,122 @@
+package construct
+
+import (
+	"reflect"
+
+	"github.com/pierrec/construct/internal/structs"
+)
+
+// Merge strategies of the config items set by several sources,
+// as defined by the merge struct tag flag.
+const (
+	// MergeReplace keeps the value of the source with the highest priority.
+	MergeReplace = "replace"
+	// MergeAppend appends the slice items of the sources with the highest
+	// priority to the ones of the sources with a lower priority, e.g. the
+	// items of a command line flag are appended to the ones of a file.
+	// Map items of the sources with the highest priority override the other ones.
+	MergeAppend = "append"
+	// MergeUnion is like MergeAppend but skips the duplicate slice items.
+	MergeUnion = "union"
+)
+
+// mergeStrategy returns the merge strategy of the field.
+func mergeStrategy(field *structs.StructField) string {
+	if s, ok := field.Flag("merge"); ok {
+		return s
+	}
+	return MergeReplace
+}
+
+// items returns the config items to be set by a source: the ones not set yet
+// and the ones merging the values of all their sources.
+// The map keys are the normalized names and the values the untouched names.
+func (c *config) items() map[string]string {
+	if len(c.merges) == 0 {
+		return c.trans
+	}
+	items := make(map[string]string, len(c.trans)+len(c.merges))
+	for lname, name := range c.trans {
+		items[lname] = name
+	}
+	for lname, name := range c.merges {
+		items[lname] = name
+	}
+	return items
+}
+
+// setItem sets the config item from the value v read from the source src.
+// If the config item was set by a source with a higher priority, both values
+// are merged according to its merge strategy.
+func (c *config) setItem(lname, name string, field *structs.StructField, v interface{}, src Source, raw string) error {
+	strategy := mergeStrategy(field)
+	if strategy == MergeReplace {
+		delete(c.trans, lname)
+		if err := setField(field, v, raw); err != nil {
+			return err
+		}
+		c.setSource(name, src, raw)
+		return nil
+	}
+	if _, ok := c.sources[name]; !ok {
+		// First source setting the config item.
+		delete(c.trans, lname)
+		if c.merges == nil {
+			c.merges = make(map[string]string)
+		}
+		c.merges[lname] = name
+		if err := setField(field, v, raw); err != nil {
+			return err
+		}
+		c.setSource(name, src, raw)
+		return nil
+	}
+
+	high := reflect.ValueOf(field.Interface())
+	if err := field.Set(nil); err != nil {
+		return err
+	}
+	if err := setField(field, v, raw); err != nil {
+		return err
+	}
+	low := reflect.ValueOf(field.Interface())
+	return field.Set(mergeValues(low, high, strategy).Interface())
+}
+
+// mergeValues merges the slices or maps low and high, the latter having precedence.
+func mergeValues(low, high reflect.Value, strategy string) reflect.Value {
+	switch low.Kind() {
+	case reflect.Slice:
+		res := reflect.MakeSlice(low.Type(), 0, low.Len()+high.Len())
+		for _, v := range []reflect.Value{low, high} {
+			for i := 0; i < v.Len(); i++ {
+				item := v.Index(i)
+				if strategy == MergeUnion && containsValue(res, item) {
+					continue
+				}
+				res = reflect.Append(res, item)
+			}
+		}
+		return res
+	case reflect.Map:
+		res := reflect.MakeMapWithSize(low.Type(), low.Len()+high.Len())
+		for _, v := range []reflect.Value{low, high} {
+			iter := v.MapRange()
+			for iter.Next() {
+				res.SetMapIndex(iter.Key(), iter.Value())
+			}
+		}
+		return res
+	}
+	return high
+}
+
+// containsValue returns whether or not the slice lst contains v.
+func containsValue(lst, v reflect.Value) bool {
+	for i := 0; i < lst.Len(); i++ {
+		if reflect.DeepEqual(lst.Index(i).Interface(), v.Interface()) {
+			return true
+		}
+	}
+	return false
+}
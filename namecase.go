@@ -0,0 +1,269 @@
+package construct
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/pierrec/construct/internal/structs"
+)
+
+// NameCase defines how the names of the config items, and of their groups,
+// are converted for a source.
+type NameCase int
+
+const (
+	// CaseLower lower cases the names, e.g. MaxConns is converted to maxconns.
+	CaseLower NameCase = iota
+	// CasePreserve keeps the names as defined in the Config struct.
+	CasePreserve
+	// CaseKebab separates the words of the names with dashes, e.g. MaxConns
+	// is converted to max-conns.
+	CaseKebab
+	// CaseSnake separates the words of the names with underscores, e.g. MaxConns
+	// is converted to max_conns.
+	CaseSnake
+)
+
+// convert returns name in the case.
+func (nc NameCase) convert(name string) string {
+	switch nc {
+	case CasePreserve:
+		return name
+	case CaseKebab:
+		return strings.ToLower(strings.Join(splitWords(name), "-"))
+	case CaseSnake:
+		return strings.ToLower(strings.Join(splitWords(name), "_"))
+	}
+	return strings.ToLower(name)
+}
+
+// splitWords splits a camel cased name into its words, acronyms being kept
+// together, e.g. HTTPServerID is split into HTTP, Server and ID.
+// Underscores, dashes and spaces also separate words.
+func splitWords(name string) []string {
+	rs := []rune(name)
+	var words []string
+	start := 0
+	for i, r := range rs {
+		switch {
+		case r == '_' || r == '-' || r == ' ':
+			if i > start {
+				words = append(words, string(rs[start:i]))
+			}
+			start = i + 1
+			continue
+		case i == start || !unicode.IsUpper(r):
+			continue
+		}
+		prev := rs[i-1]
+		next := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+		if !unicode.IsUpper(prev) || next {
+			words = append(words, string(rs[start:i]))
+			start = i
+		}
+	}
+	if start < len(rs) {
+		words = append(words, string(rs[start:]))
+	}
+	return words
+}
+
+// flagName returns the command line flag name of the config item name.
+func (c *config) flagName(name string) string {
+	parts := strings.Split(name, c.options.gsep)
+	if namer, ok := c.raw.(FlagsNamer); ok {
+		if fname := namer.FlagName(parts); fname != "" {
+			return fname
+		}
+	}
+	if c.mains[strings.ToLower(name)] {
+		// The main config item is set with the flag of its group.
+		parts = parts[:len(parts)-1]
+	}
+	for i, part := range parts {
+		parts[i] = c.options.fcase.convert(part)
+	}
+	return strings.Join(parts, c.options.gsep)
+}
+
+// flagItem returns the lower cased config item name of the command line flag.
+func (c *config) flagItem(fname string) string {
+	if lname, ok := c.fitems[fname]; ok {
+		return lname
+	}
+	return fname
+}
+
+// ioKeys maps the keys of the config items to their keys in the FromIO sources.
+type ioKeys struct {
+	nc    NameCase
+	namer IONamer
+	paths map[string][]string // Config items keys by their joined keys in the sources.
+	names map[string]string   // Config items and groups names by their name in the sources.
+}
+
+// ioKeys returns the mapping of the config items keys, prefixed with prefix,
+// to the keys of the FromIO sources, or nil if they are the same.
+func (c *config) ioKeys(prefix []string) *ioKeys {
+	// The FromIO sources belong to the command prefix is relative to.
+	owner := c
+	for range prefix {
+		owner = owner.parent
+	}
+	namer, _ := owner.raw.(IONamer)
+	if namer == nil && c.options.iocase == CasePreserve {
+		return nil
+	}
+	m := &ioKeys{
+		nc:    c.options.iocase,
+		namer: namer,
+		paths: make(map[string][]string),
+		names: make(map[string]string),
+	}
+	var walk func(*structs.StructStruct, []string)
+	walk = func(s *structs.StructStruct, keys []string) {
+		for _, field := range s.Fields() {
+			if cmd, _ := getCommand(field); cmd != nil {
+				continue
+			}
+			name := field.Name()
+			m.names[m.nc.convert(name)] = name
+			ks := append(keys[:len(keys):len(keys)], name)
+			if emb := field.Embedded(); emb != nil {
+				if emb.Inlined() {
+					ks = keys
+				}
+				walk(emb, ks)
+				continue
+			}
+			m.paths[strings.Join(m.to(ks), "\x00")] = ks
+		}
+	}
+	walk(c.root, prefix)
+	return m
+}
+
+// to returns the keys in the FromIO sources of the config item keys.
+func (m *ioKeys) to(keys []string) []string {
+	if m.namer != nil && keys[len(keys)-1] != "" {
+		// The empty key identifies the global comment.
+		if ks := m.namer.IOKeys(keys); len(ks) > 0 {
+			return ks
+		}
+	}
+	res := make([]string, len(keys))
+	for i, key := range keys {
+		res[i] = m.nc.convert(key)
+	}
+	return res
+}
+
+// from returns the config item keys from the keys in the FromIO sources.
+// Unknown keys are kept as is.
+func (m *ioKeys) from(keys []string) []string {
+	if ks, ok := m.paths[strings.Join(keys, "\x00")]; ok {
+		return ks
+	}
+	res := make([]string, len(keys))
+	for i, key := range keys {
+		if name, ok := m.names[key]; ok {
+			key = name
+		}
+		res[i] = key
+	}
+	return res
+}
+
+// ioLookup returns lookup expecting the keys of the FromIO sources.
+func (c *config) ioLookup(lookup LookupFn, prefix []string) LookupFn {
+	m := c.ioKeys(prefix)
+	if m == nil {
+		return lookup
+	}
+	return func(keys ...string) []rune {
+		return lookup(m.from(keys)...)
+	}
+}
+
+// ioStore returns store converting the keys of the config items, prefixed
+// with prefix, to the keys of the FromIO sources, unless it is nil.
+func (c *config) ioStore(store Store, prefix []string) Store {
+	if _, ok := store.(*caseStore); ok || store == nil {
+		return store
+	}
+	if m := c.ioKeys(prefix); m != nil {
+		return &caseStore{store, m}
+	}
+	return store
+}
+
+// newIOStore returns the Store created by newStore, converting the keys of the
+// config items to the keys of the FromIO sources.
+func (c *config) newIOStore(newStore NewStoreFn) Store {
+	return c.ioStore(newStore(c.ioLookup(c.lookup, nil)), nil)
+}
+
+// caseStore converts the keys of the config items to the keys of the FromIO sources.
+type caseStore struct {
+	store Store
+	keys  *ioKeys
+}
+
+var _ TagStore = (*caseStore)(nil)
+var _ CommentStore = (*caseStore)(nil)
+
+func (s *caseStore) Has(keys ...string) bool { return s.store.Has(s.keys.to(keys)...) }
+
+func (s *caseStore) Get(keys ...string) (interface{}, error) { return s.store.Get(s.keys.to(keys)...) }
+
+func (s *caseStore) Set(value interface{}, keys ...string) error {
+	return s.store.Set(value, s.keys.to(keys)...)
+}
+
+func (s *caseStore) SetComment(comment string, keys ...string) error {
+	return s.store.SetComment(comment, s.keys.to(keys)...)
+}
+
+func (s *caseStore) ReadFrom(r io.Reader) (int64, error) { return s.store.ReadFrom(r) }
+
+func (s *caseStore) WriteTo(w io.Writer) (int64, error) { return s.store.WriteTo(w) }
+
+func (s *caseStore) StructTag() string { return s.store.StructTag() }
+
+func (s *caseStore) SetTag(tag reflect.StructTag, keys ...string) {
+	if ts, ok := s.store.(TagStore); ok {
+		ts.SetTag(tag, s.keys.to(keys)...)
+	}
+}
+
+func (s *caseStore) Comment(keys ...string) string {
+	if cs, ok := s.store.(CommentStore); ok {
+		return cs.Comment(s.keys.to(keys)...)
+	}
+	return ""
+}
+
+// keysStore returns the keys of the store, converted back to the config items keys.
+func (s *caseStore) keysStore() (KeysStore, bool) {
+	ks, ok := s.store.(KeysStore)
+	if !ok {
+		return nil, false
+	}
+	return caseKeys{ks, s.keys}, true
+}
+
+type caseKeys struct {
+	ks   KeysStore
+	keys *ioKeys
+}
+
+func (ck caseKeys) Keys() [][]string {
+	all := ck.ks.Keys()
+	res := make([][]string, len(all))
+	for i, keys := range all {
+		res[i] = ck.keys.from(keys)
+	}
+	return res
+}
@@ -0,0 +1,174 @@
+package construct_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type CaseDB struct {
+	MaxConns int
+	HostName string
+}
+
+func (*CaseDB) Init() error              { return nil }
+func (*CaseDB) Usage(name string) string { return "" }
+
+type cfgCase struct {
+	constructs.ConfigFileJSON
+	CaseDB   `cfg:"DB"`
+	LogLevel string
+}
+
+func (*cfgCase) Init() error                                            { return nil }
+func (*cfgCase) Usage(name string) string                               { return "" }
+func (*cfgCase) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgCase) FlagsShort(name string) string                          { return "" }
+
+func TestNameCase(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	data := `{"db": {"max_conns": 10, "host_name": "h"}, "log_level": "debug"}`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var c cfgCase
+	c.Name = name
+	c.ToSave = true
+	args := []string{"--db-max-conns", "20"}
+	err := construct.LoadArgs(&c, args,
+		construct.OptionFlagsCase(construct.CaseKebab),
+		construct.OptionIOCase(construct.CaseSnake),
+		construct.OptionStrictIO(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MaxConns != 20 || c.HostName != "h" || c.LogLevel != "debug" {
+		t.Errorf("config not loaded: %+v", c)
+	}
+
+	saved, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(saved); !strings.Contains(s, `"max_conns": 20`) || strings.Contains(s, "MaxConns") {
+		t.Errorf("invalid saved config:\n%s", s)
+	}
+}
+
+func TestNameCaseDump(t *testing.T) {
+	c := cfgCase{CaseDB: CaseDB{MaxConns: 10, HostName: "h"}, LogLevel: "debug"}
+	nc := construct.OptionIOCase(construct.CaseSnake)
+	var dump, saved bytes.Buffer
+	if err := construct.Dump(&c, &dump, "json", nc); err != nil {
+		t.Fatal(err)
+	}
+	if err := construct.Sample(&c, "json", &saved, nc); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{dump.String(), saved.String()} {
+		if !strings.Contains(s, `"max_conns": 10`) || strings.Contains(s, "MaxConns") {
+			t.Errorf("invalid keys case:\n%s", s)
+		}
+	}
+
+	newStore := func(lookup construct.LookupFn) construct.Store {
+		store, _ := construct.NewStore("json", lookup)
+		return store
+	}
+	diff, err := construct.Diff(&c, newStore, &dump, nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Errorf("got %+v; expected no differences", diff)
+	}
+}
+
+func TestNameCaseWords(t *testing.T) {
+	var c cfgCase
+	for _, tc := range []struct {
+		nc   construct.NameCase
+		flag string
+	}{
+		{construct.CaseLower, "--db-maxconns"},
+		{construct.CasePreserve, "--DB-MaxConns"},
+		{construct.CaseKebab, "--db-max-conns"},
+		{construct.CaseSnake, "--db-max_conns"},
+	} {
+		cl, err := construct.CommandLine(&cfgCase{CaseDB: CaseDB{MaxConns: 1}}, construct.OptionFlagsCase(tc.nc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tc.flag + " 1"; cl != want {
+			t.Errorf("got %q; expected %q", cl, want)
+		}
+		if err := construct.LoadArgs(&c, []string{tc.flag, "2"}, construct.OptionFlagsCase(tc.nc)); err != nil {
+			t.Fatal(err)
+		}
+		if c.MaxConns != 2 {
+			t.Errorf("%s: got %d; expected 2", tc.flag, c.MaxConns)
+		}
+		c.MaxConns = 0
+	}
+}
+
+type NamerServer struct {
+	Port int
+}
+
+func (*NamerServer) Init() error              { return nil }
+func (*NamerServer) Usage(name string) string { return "" }
+
+type cfgNamer struct {
+	constructs.ConfigFileJSON
+	NamerServer `cfg:"Server"`
+}
+
+func (*cfgNamer) Init() error                                            { return nil }
+func (*cfgNamer) Usage(name string) string                               { return "" }
+func (*cfgNamer) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgNamer) FlagsShort(name string) string                          { return "" }
+func (*cfgNamer) FlagName(keys []string) string {
+	if strings.Join(keys, ".") == "Server.Port" {
+		return "listen"
+	}
+	return ""
+}
+func (*cfgNamer) Env(name string) string {
+	return "MYAPP_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+func (*cfgNamer) IOKeys(keys []string) []string {
+	return []string{strings.ToLower(strings.Join(keys, "."))}
+}
+
+func TestNamers(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(name, []byte(`{"server.port": 8080}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		args []string
+		env  map[string]string
+		port int
+	}{
+		{nil, nil, 8080},
+		{nil, map[string]string{"MYAPP_SERVER_PORT": "8081"}, 8081},
+		{[]string{"--listen", "8082"}, map[string]string{"MYAPP_SERVER_PORT": "8081"}, 8082},
+	} {
+		var c cfgNamer
+		c.Name = name
+		err := construct.LoadArgs(&c, tc.args, construct.OptionEnv(tc.env), construct.OptionStrictIO(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Port != tc.port {
+			t.Errorf("%v %v: got %d; expected %d", tc.args, tc.env, c.Port, tc.port)
+		}
+	}
+}
@@ -1,6 +1,12 @@
 package construct
 
-import "io"
+import (
+	"io"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
 
 // Option is used to customize the behaviour of construct.
 type Option func(*config) error
@@ -15,6 +21,16 @@ func OptionFlagsWriter(w io.Writer) Option {
 	}
 }
 
+// OptionProgramName sets the program name used by GenDocs and Completion.
+//
+// If not set, it defaults to the base name of the running program.
+func OptionProgramName(name string) Option {
+	return func(c *config) error {
+		c.options.prog = name
+		return nil
+	}
+}
+
 // OptionFlagsGroupSep defines the separator for grouped config items in command line flags.
 // Config items are grouped using an embedded struct that does not implement the Config interface.
 //
@@ -26,6 +42,92 @@ func OptionFlagsGroupSep(sep rune) Option {
 	}
 }
 
+// OptionFlagsCase sets the case of the command line flags names, each group name
+// being converted separately, e.g. the MaxConns item of the DB group is set with
+// --db-max-conns using CaseKebab.
+//
+// If not set, it defaults to CaseLower.
+func OptionFlagsCase(nc NameCase) Option {
+	return func(c *config) error {
+		if nc < CaseLower || nc > CaseSnake {
+			return errors.Errorf("invalid flags case: %d", nc)
+		}
+		c.options.fcase = nc
+		return nil
+	}
+}
+
+// OptionIOCase sets the case of the config items and groups keys in the FromIO
+// sources, when they are read and saved, e.g. the MaxConns item is stored as
+// max_conns using CaseSnake.
+//
+// If not set, it defaults to CasePreserve.
+func OptionIOCase(nc NameCase) Option {
+	return func(c *config) error {
+		if nc < CaseLower || nc > CaseSnake {
+			return errors.Errorf("invalid io case: %d", nc)
+		}
+		c.options.iocase = nc
+		return nil
+	}
+}
+
+// OptionTagID sets the struct tag name used to annotate the struct fields
+// instead of TagID, e.g. when the cfg tag is already used by another package.
+func OptionTagID(id string) Option {
+	return func(c *config) error {
+		if id == "" {
+			return errors.New("empty tag id")
+		}
+		c.options.tagid = id
+		return nil
+	}
+}
+
+// OptionSepTagID sets the struct tag name used to specify the separators of
+// slice and map struct fields instead of TagSepID.
+func OptionSepTagID(id string) Option {
+	return func(c *config) error {
+		if id == "" {
+			return errors.New("empty separators tag id")
+		}
+		c.options.septagid = id
+		return nil
+	}
+}
+
+// OptionNameTags names the struct fields which tag does not specify a name
+// after the first of the given struct tags that does, e.g. OptionNameTags("json", "yaml")
+// reuses the existing json and yaml names. Only the name of these tags is used,
+// their flags, such as omitempty, being ignored, and fields named "-" are discarded.
+func OptionNameTags(tags ...string) Option {
+	return func(c *config) error {
+		c.options.nametags = append([]string{}, tags...)
+		return nil
+	}
+}
+
+// OptionInclude sets the top level key listing the files included by the data
+// of a FromIO source, e.g. OptionInclude("include") for include = ["other.toml",
+// "conf.d/*.toml"] in a TOML file. The key is not a config item.
+//
+// The included files use the same format as the including one. Their names may
+// contain glob patterns and are relative to the directory of the including file
+// if it is an *os.File, or to the current directory otherwise.
+// They are merged in order, the values of an included file overriding the ones
+// of the including file and of the previously included ones.
+// Files can be included recursively. Patterns not matching any file are ignored,
+// but a missing file without pattern is an error.
+//
+// The Store of the FromIO source must implement KeysStore.
+// Saving a config with included files saves all the merged values.
+func OptionInclude(key string) Option {
+	return func(c *config) error {
+		c.options.incl = key
+		return nil
+	}
+}
+
 // OptionEnvSep is used to separate grouped config items in environment variables.
 //
 // If not set, it defaults to '_'.
@@ -36,12 +138,350 @@ func OptionEnvSep(sep rune) Option {
 	}
 }
 
+// OptionEnvPrefix sets config items from environment variables which names are
+// derived from the config items names: upper cased, with the group separator
+// replaced by the env separator and prefixed with prefix, e.g. the Port item
+// of the DB group is set from MYAPP_DB_PORT for the MYAPP prefix.
+// No prefix is used if it is empty.
+//
+// If the config also implements FromEnv, the names it returns take precedence.
+func OptionEnvPrefix(prefix string) Option {
+	return func(c *config) error {
+		c.options.envauto = true
+		c.options.envprefix = prefix
+		return nil
+	}
+}
+
 // OptionFlagsUsage defines the function to be called when an error is encountered
 // while parsing command line flags.
 // The supplied error is nil if the help was requested.
+//
+// By default, the error, if any, and the usage are written to the flags Writer
+// and Load returns ErrHelp if the help was requested, the error otherwise.
 func OptionFlagsUsage(usage func(error, func(io.Writer) error) error) Option {
 	return func(c *config) error {
 		c.options.fusage = usage
 		return nil
 	}
 }
+
+// OptionFlagsStdlib parses the command line flags with a FlagSet of the standard
+// flag package instead of pflag: flags are written with a single dash, e.g. -name
+// or -name=value, double dashes being also accepted, bool flags only take a value
+// with -name=value and the shorthands returned by FlagsShort are ignored.
+// The parsing stops at the first positional argument, regardless of
+// OptionFlagsInterspersed. The flags usage lists them with a single dash.
+func OptionFlagsStdlib() Option {
+	return func(c *config) error {
+		c.options.stdflags = true
+		return nil
+	}
+}
+
+// OptionFlagsExit makes the default flags usage function exit the process
+// with status 2 once the usage is written, as the standard flag package does,
+// instead of returning an error from Load.
+func OptionFlagsExit() Option {
+	return func(c *config) error {
+		c.options.fexit = true
+		return nil
+	}
+}
+
+// OptionFlagsUsageTemplate sets the template used to write the flags usage
+// instead of the default layout. The template is executed with a *FlagsUsage.
+//
+// Using a template allows grouping the flags by embedded struct, adding
+// examples or footers. For instance:
+//
+//     {{.Usage}}
+//     {{range .Flags}}  --{{.Name}}	{{.Usage}}{{with .Default}} [{{.}}]{{end}}
+//     {{end}}
+func OptionFlagsUsageTemplate(tmpl *template.Template) Option {
+	return func(c *config) error {
+		c.options.utmpl = tmpl
+		return nil
+	}
+}
+
+// OptionNegativeFlags defines a --no-<name> flag for each bool config item
+// command line flag --<name>, which sets the config item to false.
+// Setting both flags is an error.
+func OptionNegativeFlags(enable bool) Option {
+	return func(c *config) error {
+		c.options.negflags = enable
+		return nil
+	}
+}
+
+// OptionFlagsInterspersed defines whether command line flags may follow the
+// positional arguments, e.g. "cmd file --verbose", instead of the parsing
+// stopping at the first positional argument.
+// It should not be enabled for configs with subcommands, as their name is then
+// parsed as a positional argument of their parent.
+//
+// If not set, it defaults to true if the config has no subcommand, false otherwise.
+func OptionFlagsInterspersed(enable bool) Option {
+	return func(c *config) error {
+		c.options.mixflags = &enable
+		return nil
+	}
+}
+
+// OptionStrictCommands makes Load fail with an UnknownCommandError suggesting
+// the closest subcommands when the first positional argument of a command with
+// subcommands does not match any of them, typically a misspelled subcommand.
+// Otherwise, the positional arguments are passed to FlagsDone.
+func OptionStrictCommands() Option {
+	return func(c *config) error {
+		c.options.scmds = true
+		return nil
+	}
+}
+
+// OptionDeprecatedWriter sets the Writer for the warnings about the deprecated
+// config items set by a source. It defaults to os.Stderr.
+func OptionDeprecatedWriter(w io.Writer) Option {
+	return func(c *config) error {
+		c.options.dout = w
+		return nil
+	}
+}
+
+// CommandMatch defines how subcommands are matched against the command line arguments.
+type CommandMatch int
+
+const (
+	// CommandMatchFold matches subcommands regardless of their case.
+	CommandMatchFold CommandMatch = iota
+	// CommandMatchExact matches subcommands using their exact name, as listed
+	// in the usage, i.e. lower cased.
+	CommandMatchExact
+	// CommandMatchPrefix matches subcommands regardless of their case and
+	// accepts any unambiguous prefix of their name.
+	CommandMatchPrefix
+)
+
+// OptionCommandMatch sets the mode used to match subcommands.
+//
+// If not set, it defaults to CommandMatchFold.
+func OptionCommandMatch(m CommandMatch) Option {
+	return func(c *config) error {
+		c.options.cmatch = m
+		return nil
+	}
+}
+
+// OptionStoreFilter defines a function called on the Store once it has been
+// read from the FromIO source and before its values are used to populate the config.
+// It is typically used to validate the data or add and remove keys.
+//
+// The Store is empty if the FromIO source did not provide any data.
+func OptionStoreFilter(filter func(Store) error) Option {
+	return func(c *config) error {
+		c.options.sfilter = filter
+		return nil
+	}
+}
+
+// OptionSaveSources adds the source of each config item value to its comment
+// when the config is saved, e.g. "source: flag".
+func OptionSaveSources(enable bool) Option {
+	return func(c *config) error {
+		c.options.ssource = enable
+		return nil
+	}
+}
+
+// OptionSnapshot writes the fully resolved config to the file at path using the
+// given registered Store format, once it has been loaded from all its sources
+// and initialized.
+// Sensitive and secret config items are redacted.
+//
+// Unlike the FromIO destination, the snapshot records the config the process
+// runs with and is not meant to be edited.
+func OptionSnapshot(path, format string) Option {
+	return func(c *config) error {
+		c.options.snap.path = path
+		c.options.snap.format = format
+		return nil
+	}
+}
+
+// OptionRequireSource makes Load fail if any of the given sources did not provide
+// any data for the main config, typically when a required config file is missing.
+//
+// A FromIO source is present as soon as its Load method returns a non nil value,
+// even if it does not contain any config item. Environment variables and command
+// line flags sources are present if at least one config item was set from them.
+func OptionRequireSource(sources ...Source) Option {
+	return func(c *config) error {
+		c.options.rsources = sources
+		return nil
+	}
+}
+
+// OptionSources sets the sources of the config items, in decreasing priority
+// order: a config item set by a source is not set by the following ones, unless
+// it merges their values (see the merge struct tag flag).
+// Sources not listed are skipped, e.g. OptionSources(SourceFlags, SourceEnv)
+// ignores the FromIO and FromRemote sources, which are then neither loaded nor saved.
+// Command line flags are always parsed, to process subcommands and the help request.
+// Note that config items set by the sources following the FromIO source, such as
+// the name of a config file, are not available when it is loaded.
+//
+// If not set, it defaults to SourceFlags, SourceEnv, SourceRemote, SourceFile.
+func OptionSources(sources ...Source) Option {
+	return func(c *config) error {
+		seen := make(map[Source]bool)
+		for _, src := range sources {
+			switch src {
+			case SourceFlags, SourceEnv, SourceRemote, SourceFile:
+			default:
+				return errors.Errorf("invalid source: %v", src)
+			}
+			if seen[src] {
+				return errors.Errorf("duplicate source: %v", src)
+			}
+			seen[src] = true
+		}
+		c.options.sources = append([]Source{}, sources...)
+		return nil
+	}
+}
+
+// OptionEnvFunc sets the function looking up the environment variables instead
+// of os.LookupEnv, e.g. to supply them in tests without modifying the process
+// environment. The variables defined by OptionEnvFile are still used.
+func OptionEnvFunc(lookup func(name string) (string, bool)) Option {
+	return func(c *config) error {
+		c.options.envfunc = lookup
+		return nil
+	}
+}
+
+// OptionEnv is equivalent to OptionEnvFunc looking up the environment variables
+// in vars only.
+func OptionEnv(vars map[string]string) Option {
+	return OptionEnvFunc(func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	})
+}
+
+// OptionEnvFile adds a dotenv formatted file defining environment variables.
+// When set multiple times, the variables of the files added last override
+// the ones of the previous files. Actual environment variables override them all.
+//
+// Missing files are skipped, unless required is set.
+func OptionEnvFile(name string, required bool) Option {
+	return func(c *config) error {
+		c.options.envfiles = append(c.options.envfiles, envFile{name, required})
+		return nil
+	}
+}
+
+// OptionWatchInterval sets the interval at which Watch polls the FromIO source.
+//
+// If not set, it defaults to 1s.
+func OptionWatchInterval(d time.Duration) Option {
+	return func(c *config) error {
+		c.options.winterval = d
+		return nil
+	}
+}
+
+// OptionStrictIO makes Load fail when the FromIO source contains keys that do
+// not map to any config item, typically misspelled ones which would otherwise
+// be silently ignored.
+// If warn is not nil, it is called with the error instead and the config is loaded.
+//
+// The Store of the FromIO source must implement KeysStore.
+func OptionStrictIO(warn func(error)) Option {
+	return func(c *config) error {
+		c.options.strictio = true
+		c.options.iowarn = warn
+		return nil
+	}
+}
+
+// OptionNoSave prevents Load from saving the config to its FromIO source once loaded,
+// e.g. for read-only deployments. The config can then be saved explicitly with
+// Handle.Save or Save.
+func OptionNoSave() Option {
+	return func(c *config) error {
+		c.options.nosave = true
+		return nil
+	}
+}
+
+// optionReload loads the config again: its FlagsDone, PreRun and PostRun
+// methods are not invoked and its FromIO sources are not saved.
+func optionReload() Option {
+	return func(c *config) error {
+		c.options.reload = true
+		c.options.nosave = true
+		return nil
+	}
+}
+
+// OptionCollectErrors makes Load report all the config items that could not be
+// set from the sources in a MultiError, along with the validation failures,
+// instead of failing on the first one. Command line flags parsing errors are
+// still reported immediately.
+func OptionCollectErrors() Option {
+	return func(c *config) error {
+		c.options.collect = true
+		return nil
+	}
+}
+
+// OptionResponseFiles replaces the @file command line arguments by the arguments
+// read from the file before they are parsed, e.g. to share canned invocations
+// or work around the command line length limits.
+// The arguments in the file are separated by white spaces and quoted as in a shell,
+// with single or double quotes and backslashes, and lines starting with #, after
+// optional white spaces, are comments. Response files may include other ones,
+// relative to their directory.
+// The arguments following --, including in a response file, are not expanded.
+// Neither are the values of the flags, e.g. @mail.tmpl in --tmpl @mail.tmpl
+// or --tmpl=@mail.tmpl, the latter form being always safe.
+// The arguments of a subcommand are expanded when it is loaded.
+func OptionResponseFiles() Option {
+	return func(c *config) error {
+		c.options.respfiles = true
+		return nil
+	}
+}
+
+// OptionResolver resolves with r the references to secrets prefixed with scheme
+// and a colon, e.g. "vault", held by the string config items tagged with secretref,
+// once they are set from all the sources. Pointers to strings are also supported,
+// other types are rejected.
+// Config items which value is not prefixed with a registered scheme are left as is.
+func OptionResolver(scheme string, r Resolver) Option {
+	return func(c *config) error {
+		if c.options.resolvers == nil {
+			c.options.resolvers = make(map[string]Resolver)
+		}
+		c.options.resolvers[scheme] = r
+		return nil
+	}
+}
+
+// OptionExpandEnv expands the references to environment variables in the string
+// values read from the FromIO source, before they are set: $VAR and ${VAR} are
+// replaced by the value of VAR and $$ by $.
+// Variables are looked up in the environment, then in the env files.
+// References to undefined variables make Load fail if strict is true,
+// otherwise they are left as is.
+//
+// The references are kept when the config is saved, unless the config item was modified.
+func OptionExpandEnv(strict bool) Option {
+	return func(c *config) error {
+		c.options.expand = true
+		c.options.xstrict = strict
+		return nil
+	}
+}
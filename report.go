@@ -0,0 +1,70 @@
+package construct
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// ReportItem describes where the value of a config item comes from.
+type ReportItem struct {
+	// Key is the config item name, with its subcommands and groups separated
+	// by the flags group separator.
+	Key string
+	// Source of the value.
+	Source Source
+	// Raw is the value as read from its source, or the serialized default value.
+	// It is set to RedactedValue for secret and sensitive config items.
+	Raw string
+}
+
+// Report lists the sources of the config items values.
+type Report struct {
+	// Items sorted by key.
+	Items []ReportItem
+}
+
+// Report returns the sources of the values of the loaded config items,
+// including the ones of the invoked subcommands.
+func (h *Handle) Report() (*Report, error) {
+	r := &Report{}
+	for _, c := range h.confs {
+		prefix := strings.Join(c.cmds, c.options.gsep)
+		if prefix != "" {
+			prefix += c.options.gsep
+		}
+		for name, src := range c.sources {
+			raw := c.raws[name]
+			if c.isSecret(name) {
+				raw = RedactedValue
+			}
+			r.Items = append(r.Items, ReportItem{prefix + name, src, raw})
+		}
+		for _, name := range c.trans {
+			raw, err := c.marshalItem(name)
+			if err != nil {
+				return nil, err
+			}
+			if c.isSecret(name) {
+				raw = RedactedValue
+			}
+			r.Items = append(r.Items, ReportItem{prefix + name, SourceDefault, raw})
+		}
+	}
+	sort.Slice(r.Items, func(i, j int) bool {
+		return r.Items[i].Key < r.Items[j].Key
+	})
+	return r, nil
+}
+
+// String returns the report as a table.
+func (r *Report) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 8, 0, 1, ' ', 0)
+	for _, item := range r.Items {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", item.Key, item.Source, item.Raw)
+	}
+	w.Flush()
+	return b.String()
+}
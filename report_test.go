@@ -0,0 +1,62 @@
+package construct_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type cfgReport struct {
+	constructs.ConfigFileJSON
+	RemoteDB `cfg:"DB"`
+	Level    string
+	Debug    bool
+}
+
+func (*cfgReport) Init() error                                            { return nil }
+func (*cfgReport) Usage(name string) string                               { return "" }
+func (*cfgReport) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgReport) FlagsShort(name string) string                          { return "" }
+func (*cfgReport) Env(name string) string {
+	if name == "DB-Host" {
+		return "REPORT_DB_HOST"
+	}
+	return ""
+}
+
+func TestReport(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(name, []byte(`{"DB": {"Port": 5432}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("REPORT_DB_HOST", "envhost")
+
+	c := cfgReport{Level: "info"}
+	c.Name = name
+	h, err := construct.LoadHandle(&c, []string{"--debug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := h.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]construct.ReportItem)
+	for _, item := range r.Items {
+		got[item.Key] = item
+	}
+	for _, want := range []construct.ReportItem{
+		{"DB-Host", construct.SourceEnv, "envhost"},
+		{"DB-Port", construct.SourceFile, "5432"},
+		{"Debug", construct.SourceFlags, "true"},
+		{"Level", construct.SourceDefault, "info"},
+	} {
+		if item := got[want.Key]; !reflect.DeepEqual(item, want) {
+			t.Errorf("got %+v; expected %+v", item, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package construct
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/pierrec/construct/internal/structs"
+)
+
+// Resolver is implemented by the clients of secrets backends, such as
+// HashiCorp Vault, resolving the references to secrets held by the config
+// items tagged with secretref, see OptionResolver.
+type Resolver interface {
+	// Resolve returns the value of the secret referenced by ref, stripped of
+	// its scheme, e.g. kv/data/app#password for vault:kv/data/app#password.
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// resolve replaces the references held by the config items tagged with
+// secretref by the value of the secrets they reference.
+func (c *config) resolve() error {
+	if len(c.options.resolvers) == 0 {
+		return nil
+	}
+	var walk func(s *structs.StructStruct, keys []string) error
+	walk = func(s *structs.StructStruct, keys []string) error {
+		for _, field := range s.Fields() {
+			if cmd, _ := getCommand(field); cmd != nil {
+				// Subcommands are resolved when invoked.
+				continue
+			}
+			ks := append(keys[:len(keys):len(keys)], field.Name())
+			if emb := field.Embedded(); emb != nil {
+				if emb.Inlined() {
+					ks = keys
+				}
+				if err := walk(emb, ks); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, ok := field.Flag("secretref"); !ok {
+				continue
+			}
+			name := strings.Join(ks, c.options.gsep)
+			if err := c.collect(c.resolveItem(name, field)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(c.root, nil)
+}
+
+// resolveItem resolves the reference held by the config item name, if any.
+// The config item is a string or a pointer to a string, as checked when parsing its tag.
+func (c *config) resolveItem(name string, field *structs.StructField) error {
+	rv := reflect.ValueOf(field.Interface())
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	ref := rv.String()
+	i := strings.IndexByte(ref, ':')
+	if i < 0 {
+		return nil
+	}
+	r, ok := c.options.resolvers[ref[:i]]
+	if !ok {
+		return nil
+	}
+	v, err := r.Resolve(c.ctx, ref[i+1:])
+	if err == nil {
+		err = field.Set(v)
+	}
+	if err != nil {
+		return c.fieldError(name, c.sources[name], "", err)
+	}
+	if c.secretrefs == nil {
+		c.secretrefs = make(map[string]string)
+	}
+	c.secretrefs[name] = ref
+	return nil
+}
@@ -0,0 +1,176 @@
+package construct
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// expandResponseFiles replaces the @file arguments by the arguments read from
+// the file, recursively, until the -- argument or, if the config has subcommands,
+// the first positional argument: the arguments of a subcommand are expanded
+// when it is loaded, as its flags are then known.
+// The values of the flags, e.g. @file in --flag @file, are not expanded.
+// Nested response files are relative to the directory of the including file.
+func (c *config) expandResponseFiles(args []string) ([]string, error) {
+	res, _, err := c.expandResponseArgs(args, ".", nil)
+	return res, err
+}
+
+// expandResponseArgs expands the response files in args relative to dir,
+// stack holding the response files being expanded.
+// It reports whether the expansion stopped before the end of the arguments.
+func (c *config) expandResponseArgs(args []string, dir string, stack []string) ([]string, bool, error) {
+	var res []string
+	value := false // The argument is the value of the previous flag.
+	for i, arg := range args {
+		if arg == "--" {
+			return append(res, args[i:]...), true, nil
+		}
+		switch {
+		case value:
+			value = false
+			res = append(res, arg)
+			continue
+		case len(arg) > 1 && arg[0] == '-':
+			value = c.flagValueNext(arg)
+			res = append(res, arg)
+			continue
+		case len(arg) < 2 || arg[0] != '@':
+			if c.hasCommands() {
+				// Subcommand or positional argument.
+				return append(res, args[i:]...), true, nil
+			}
+			res = append(res, arg)
+			continue
+		}
+		name := arg[1:]
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		if abs, err := filepath.Abs(name); err == nil {
+			name = abs
+		}
+		for _, s := range stack {
+			if s == name {
+				return nil, false, errors.Errorf("response file %s includes itself", arg[1:])
+			}
+		}
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, false, errors.Errorf("response file: %v", err)
+		}
+		fargs, err := splitResponse(string(data))
+		if err != nil {
+			return nil, false, errors.Errorf("response file %s: %v", arg[1:], err)
+		}
+		fargs, stop, err := c.expandResponseArgs(fargs, filepath.Dir(name), append(stack, name))
+		if err != nil {
+			return nil, false, err
+		}
+		res = append(res, fargs...)
+		if stop {
+			// The end of the expansion in the response file also applies
+			// to the next arguments.
+			return append(res, args[i+1:]...), true, nil
+		}
+	}
+	return res, false, nil
+}
+
+// flagValueNext returns whether or not the argument following the flag arg
+// is its value, i.e. the flag is not a boolean one and its value is not inlined.
+func (c *config) flagValueNext(arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+	name := strings.TrimPrefix(arg[1:], "-")
+	if strings.HasPrefix(arg, "--") || c.options.stdflags {
+		f := c.fs.Lookup(name)
+		return f != nil && f.NoOptDefVal == ""
+	}
+	// Shorthands, the last one taking the next argument as its value.
+	for i := 0; i < len(name); i++ {
+		f := c.fs.ShorthandLookup(name[i : i+1])
+		if f == nil {
+			return false
+		}
+		if f.NoOptDefVal == "" {
+			return i == len(name)-1
+		}
+	}
+	return false
+}
+
+// splitResponse splits the content of a response file into arguments.
+// Arguments are separated by white spaces and may be quoted: characters are
+// kept as is between single quotes, and backslashes escape the next character
+// between double quotes and outside quotes. Lines starting with #, after
+// optional white spaces, are comments.
+func splitResponse(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	lineStart := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		comment := c == '#' && lineStart
+		switch c {
+		case '\n':
+			lineStart = true
+		case ' ', '\t', '\r':
+		default:
+			lineStart = false
+		}
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case comment:
+			// Comment until the end of the line.
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+			lineStart = true
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			arg.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				arg.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inArg = true
+		case c == '\\' && i+1 < len(s):
+			i++
+			if s[i] != '\n' {
+				// Escaped line breaks continue the line.
+				arg.WriteByte(s[i])
+				inArg = true
+			}
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
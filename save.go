@@ -0,0 +1,67 @@
+package construct
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Save writes the current values of the config items of config to the
+// destination of its FromIO source, with their usage as comments, without
+// loading config first. It is typically used to generate a default config file.
+// Subcommands items are not saved.
+//
+// Nothing is written if the FromIO Save method does not return a destination,
+// e.g. the ToSave field of constructs.ConfigFile must be set.
+// Sensitive and secret config items are only saved to sources implementing SecureIO.
+func Save(config Config, options ...Option) error {
+	from, ok := config.(FromIO)
+	if !ok {
+		return errors.Errorf("%T does not implement FromIO", config)
+	}
+	conf, err := newConfig(config, options)
+	if err != nil {
+		return err
+	}
+	return conf.ioSave(nil, from, conf.ioLookup(conf.lookup, nil))
+}
+
+// SaveTo is equivalent to Save using the Store returned by newStore and the
+// given destination, e.g. SaveTo(config, constructs.NewStoreJSON, w).
+// Sensitive and secret config items are not saved.
+func SaveTo(config Config, newStore NewStoreFn, w io.Writer, options ...Option) error {
+	conf, err := newConfig(config, options)
+	if err != nil {
+		return err
+	}
+	return conf.ioWrite(conf.newIOStore(newStore), w, sensitiveSkip)
+}
+
+// Sample writes an example of the config to w in the given registered Store
+// format, with the usage of the config items as comments and their current
+// values as examples. Sensitive and secret config items values are replaced by
+// RedactedValue. Subcommands items are not included.
+func Sample(config Config, format string, w io.Writer, options ...Option) error {
+	conf, err := newConfig(config, options)
+	if err != nil {
+		return err
+	}
+	fn, err := storeFn(format)
+	if err != nil {
+		return err
+	}
+	return conf.ioWrite(conf.newIOStore(fn), w, sensitiveRedact)
+}
+
+// Dump writes the current values of the config items of config to w in the
+// given registered Store format, typically once it has been loaded to show the
+// effective config. Sensitive and secret config items values are replaced by
+// RedactedValue and durations are written in their human readable form, e.g. 10s.
+// Subcommands items are not included.
+func Dump(config Config, w io.Writer, format string, options ...Option) error {
+	conf, err := newConfig(config, options)
+	if err != nil {
+		return err
+	}
+	return conf.dump(w, format)
+}
@@ -0,0 +1,153 @@
+package construct_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/construct"
+	_ "github.com/pierrec/construct/constructs"
+)
+
+func TestSave(t *testing.T) {
+	c := cfgSources{Host: "localhost", Port: 8080}
+	c.Name = filepath.Join(t.TempDir(), "config.ini")
+	if err := construct.Save(&c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.Name); !os.IsNotExist(err) {
+		t.Fatalf("config saved without ToSave: %v", err)
+	}
+
+	c.ToSave = true
+	if err := construct.Save(&c); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(c.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Host = localhost\n# listening port\nPort = 8080\n"; !strings.Contains(string(data), want) {
+		t.Errorf("got:\n%s\nexpected:\n%s", data, want)
+	}
+
+	if err := construct.Save(&cfgEnvPrefix{}); err == nil {
+		t.Error("expected an error for a config without FromIO")
+	}
+}
+
+type cfgSaveTo struct {
+	Port  int
+	Hosts []string
+	Ports map[string]int
+}
+
+func (*cfgSaveTo) Init() error              { return nil }
+func (*cfgSaveTo) Usage(name string) string { return "" }
+
+func TestSaveTo(t *testing.T) {
+	c := cfgSaveTo{Port: 8080, Hosts: []string{"a", "b"}, Ports: map[string]int{"http": 80}}
+	for _, format := range []string{"json", "yaml", "toml", "ini"} {
+		newStore := func(lookup construct.LookupFn) construct.Store {
+			store, err := construct.NewStore(format, lookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		}
+		var buf bytes.Buffer
+		if err := construct.SaveTo(&c, newStore, &buf); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !strings.Contains(buf.String(), "8080") || !strings.Contains(buf.String(), "http") {
+			t.Errorf("%s: got:\n%s", format, buf.String())
+		}
+	}
+}
+
+func TestNoSave(t *testing.T) {
+	c := cfgSources{Host: "localhost", Port: 8080}
+	c.Name = filepath.Join(t.TempDir(), "config.ini")
+	c.ToSave = true
+	h, err := construct.LoadHandle(&c, nil, construct.OptionNoSave())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.Name); !os.IsNotExist(err) {
+		t.Fatalf("config saved with OptionNoSave: %v", err)
+	}
+
+	if err := h.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(c.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Port = 8080\n"; !strings.Contains(string(data), want) {
+		t.Errorf("got:\n%s\nexpected:\n%s", data, want)
+	}
+}
+
+func TestSample(t *testing.T) {
+	c := cfgSecret{User: "bob", Password: "secret"}
+	for _, tc := range []struct {
+		format string
+		want   []string
+	}{
+		{"toml", []string{`User = "bob"`, `Password = "` + construct.RedactedValue + `"`}},
+		{"yaml", []string{"User: bob", "Password: " + construct.RedactedValue}},
+	} {
+		var buf bytes.Buffer
+		if err := construct.Sample(&c, tc.format, &buf); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s: missing %q in:\n%s", tc.format, want, buf.String())
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := construct.Sample(&cfgSources{Port: 80}, "ini", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "# listening port\nPort = 80\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("missing %q in:\n%s", want, buf.String())
+	}
+
+	if err := construct.Sample(&c, "unknown", &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestDump(t *testing.T) {
+	c := cfgSecret{}
+	err := construct.LoadArgs(&c, nil,
+		construct.OptionEnvPrefix("APP"),
+		construct.OptionEnv(map[string]string{"APP_USER": "alice", "APP_PASSWORD": "secret"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := construct.Dump(&c, &buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"User":     "alice",
+		"Password": construct.RedactedValue,
+		"Token":    construct.RedactedValue,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; expected %v", got, want)
+	}
+}
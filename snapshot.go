@@ -0,0 +1,58 @@
+package construct
+
+import (
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/pierrec/construct/internal/structs"
+)
+
+// snapshot writes the config to the snapshot file, if any.
+func (c *config) snapshot() error {
+	if c.options.snap.path == "" || c.helpRequested {
+		return nil
+	}
+	f, err := os.Create(c.options.snap.path)
+	if err != nil {
+		return err
+	}
+	if err := c.dump(f, c.options.snap.format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dump writes the config items values to w in the given Store format,
+// with sensitive and secret values redacted and durations in their human
+// readable form.
+func (c *config) dump(w io.Writer, format string) error {
+	fn, err := storeFn(format)
+	if err != nil {
+		return err
+	}
+	store := humanStore{c.newIOStore(fn)}
+	if err := c.ioEncode(c.raw, store, nil, c.root, sensitiveRedact); err != nil {
+		return err
+	}
+	_, err = store.WriteTo(w)
+	return err
+}
+
+// humanStore sets the values in the wrapped Store in their human readable form.
+type humanStore struct {
+	Store
+}
+
+var _ TagStore = humanStore{}
+
+func (s humanStore) Set(v interface{}, keys ...string) error {
+	return s.Store.Set(structs.HumanValue(v), keys...)
+}
+
+func (s humanStore) SetTag(tag reflect.StructTag, keys ...string) {
+	if ts, ok := s.Store.(TagStore); ok {
+		ts.SetTag(tag, keys...)
+	}
+}
@@ -0,0 +1,38 @@
+package construct
+
+// Source identifies where the value of a config item comes from.
+type Source int
+
+// The sources of config items values.
+const (
+	SourceDefault Source = iota // Initial value of the config.
+	SourceFile                  // FromIO source.
+	SourceEnv                   // Environment variable.
+	SourceFlags                 // Command line flag.
+	SourceRemote                // FromRemote source.
+	SourceSet                   // Handle.Set once loaded.
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	case SourceFlags:
+		return "flag"
+	case SourceRemote:
+		return "remote"
+	case SourceSet:
+		return "set"
+	}
+	return "unknown"
+}
+
+// setSource records the source of the config item and its value as read from it.
+func (c *config) setSource(name string, src Source, raw string) {
+	c.sources[name] = src
+	c.raws[name] = raw
+}
@@ -0,0 +1,115 @@
+package construct
+
+import (
+	"sort"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	row := make([]int, len(t)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(t); j++ {
+			cur := row[j]
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			row[j] = min3(row[j]+1, row[j-1]+1, prev+cost)
+			prev = cur
+		}
+	}
+	return row[len(t)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// suggest returns the candidates closest to name, regardless of their case,
+// in alphabetical order. Candidates too far from name are discarded.
+func suggest(name string, candidates []string) []string {
+	name = strings.ToLower(name)
+	best := -1
+	var res []string
+	for _, cand := range candidates {
+		d := levenshtein(name, strings.ToLower(cand))
+		if d > 2 || 2*d > len(name) {
+			continue
+		}
+		switch {
+		case best < 0 || d < best:
+			best = d
+			res = append(res[:0], cand)
+		case d == best:
+			res = append(res, cand)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// flagsError returns the error of the command line flags parsing, with the
+// closest flags if the error is about an unknown flag.
+func (c *config) flagsError(err error) error {
+	// The flag set does not provide the unknown flag but in its error message.
+	msg := err.Error()
+	name := strings.TrimPrefix(msg, "unknown flag: --")
+	if name == msg {
+		// Error of the standard flag package.
+		name = strings.TrimPrefix(msg, "flag provided but not defined: -")
+	}
+	if name == msg {
+		return err
+	}
+	var names []string
+	c.fs.VisitAll(func(f *flag.Flag) {
+		if f.Usage == "" || f.Deprecated != "" || f.Hidden {
+			return
+		}
+		names = append(names, f.Name)
+	})
+	prefix := c.flagPrefix()
+	suggestions := suggest(name, names)
+	for i, s := range suggestions {
+		suggestions[i] = prefix + s
+	}
+	return &UnknownFlagError{prefix + name, suggestions}
+}
+
+// commandNames returns the names of the visible subcommands.
+func (c *config) commandNames() []string {
+	var names []string
+	for _, field := range c.root.Fields() {
+		s, sc := getCommand(field)
+		if _, ok := field.Flag("hidden"); s == nil || ok || sc.Usage("") == "" {
+			continue
+		}
+		names = append(names, strings.ToLower(s.Name()))
+	}
+	return names
+}
+
+// unknownCommand returns the UnknownCommandError for name, suggesting the
+// closest subcommands.
+func (c *config) unknownCommand(name string) error {
+	names := c.commandNames()
+	if c.hasHelpCommand() {
+		names = append(names, "help")
+	}
+	return &UnknownCommandError{name, suggest(name, names)}
+}
@@ -0,0 +1,201 @@
+package construct
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	flag "github.com/spf13/pflag"
+)
+
+// FlagsUsage describes the command line usage of a config, as supplied to the
+// template set by OptionFlagsUsageTemplate.
+type FlagsUsage struct {
+	// Command is the path of the subcommand the usage is for, its names being
+	// separated by spaces. It is empty for the main command.
+	Command string
+	// Usage is the main usage of the config.
+	Usage string
+	// Flags in the order they are defined. Hidden flags are not included.
+	Flags []FlagUsage
+	// Globals are the flags of the parent commands of the subcommand, set before its name.
+	Globals []FlagUsage
+	// Commands are the subcommands of the config. Hidden ones are not included.
+	Commands []CommandUsage
+
+	prefix string // Prefix of the flags names.
+	help   bool   // Whether the help command is available.
+}
+
+// FlagUsage describes a command line flag.
+type FlagUsage struct {
+	// Name of the flag, without dashes.
+	Name string
+	// Short is the shorthand of the flag, if any.
+	Short string
+	// Type is the type of the flag value, empty for bool flags.
+	Type string
+	// Usage of the flag.
+	Usage string
+	// Default is the formatted default value, empty if it is the zero value
+	// or if it must not be disclosed.
+	Default string
+	// Choices are the values the flag is restricted to, if any.
+	Choices []string
+	// Negatable is set for bool flags having a negative --no-<name> flag.
+	Negatable bool
+	// Group is the name of the embedded struct group the flag belongs to,
+	// with its parent groups separated by the flags group separator.
+	// It is empty for the config items of the main struct.
+	Group string
+}
+
+// CommandUsage describes a subcommand.
+type CommandUsage struct {
+	// Name used on the command line.
+	Name string
+	// Usage of the subcommand.
+	Usage string
+}
+
+// flagsUsage returns the description of the config flags and subcommands.
+func (c *config) flagsUsage() *FlagsUsage {
+	u := &FlagsUsage{
+		Command: strings.ToLower(strings.Join(c.cmds, " ")),
+		Usage:   c.raw.Usage(""),
+		Flags:   c.flagUsages(),
+		prefix:  c.flagPrefix(),
+		help:    c.hasHelpCommand(),
+	}
+	for p := c.parent; p != nil && p.fs != nil; p = p.parent {
+		u.Globals = append(p.flagUsages(), u.Globals...)
+	}
+
+	for _, field := range c.root.Fields() {
+		s, sc := getCommand(field)
+		if _, ok := field.Flag("hidden"); s == nil || ok {
+			continue
+		}
+		usage := sc.Usage("")
+		if usage == "" {
+			// Hidden command.
+			continue
+		}
+		u.Commands = append(u.Commands, CommandUsage{strings.ToLower(s.Name()), usage})
+	}
+	return u
+}
+
+// flagUsages returns the description of the config flags.
+func (c *config) flagUsages() []FlagUsage {
+	var flags []FlagUsage
+	c.fs.VisitAll(func(f *flag.Flag) {
+		if f.Usage == "" || f.Deprecated != "" || f.Hidden {
+			// Hidden flag.
+			return
+		}
+		fu := FlagUsage{
+			Name:      f.Name,
+			Short:     f.Shorthand,
+			Usage:     f.Usage,
+			Default:   c.flagDefault(f.Name),
+			Negatable: c.isNegated(f.Name),
+		}
+		v := reflect.ValueOf(c.refs[f.Name]).Elem().Interface()
+		if _, ok := v.(bool); !ok && f.Value.Type() != "count" {
+			fu.Type = fmt.Sprintf("%T", v)
+		}
+		keys := c.fromNameAll(c.flagItem(f.Name), c.options.gsep)
+		if field := c.root.Lookup(keys...); field != nil {
+			fu.Choices = enumValues(field)
+		}
+		if len(keys) > 1 {
+			fu.Group = strings.Join(keys[:len(keys)-1], c.options.gsep)
+		}
+		flags = append(flags, fu)
+	})
+	return flags
+}
+
+// write writes the default usage layout to out.
+func (u *FlagsUsage) write(out io.Writer) error {
+	if u.Command != "" {
+		if _, err := fmt.Fprintf(out, "Command: %s\n\n", u.Command); err != nil {
+			return err
+		}
+	}
+	// Main usage.
+	if u.Usage != "" {
+		if _, err := fmt.Fprintf(out, "%s\n\n", u.Usage); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(out, "Options:\n"); err != nil {
+		return err
+	}
+
+	tabw := tabwriter.NewWriter(out, 8, 0, 1, ' ', 0)
+	if err := u.writeFlags(tabw, u.Flags); err != nil {
+		return err
+	}
+	if err := tabw.Flush(); err != nil {
+		return err
+	}
+	if len(u.Globals) > 0 {
+		if _, err := fmt.Fprintf(out, "\nGlobal options:\n"); err != nil {
+			return err
+		}
+		if err := u.writeFlags(tabw, u.Globals); err != nil {
+			return err
+		}
+		if err := tabw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	// Subcommands.
+	if len(u.Commands) > 0 {
+		if _, err := fmt.Fprintf(out, "\nCommands:\n"); err != nil {
+			return err
+		}
+		for _, cmd := range u.Commands {
+			if _, err := fmt.Fprintf(tabw, "\t%s\t%s\n", cmd.Name, cmd.Usage); err != nil {
+				return err
+			}
+		}
+		if u.help {
+			if _, err := fmt.Fprintf(tabw, "\thelp\tshow the help of a command\n"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tabw.Flush()
+}
+
+// writeFlags writes the flags to the tabwriter.
+func (u *FlagsUsage) writeFlags(tabw *tabwriter.Writer, flags []FlagUsage) error {
+	for _, f := range flags {
+		short := f.Short
+		if short != "" {
+			short = "-" + short + ", "
+		}
+		name := f.Name
+		if f.Negatable {
+			name = "[no-]" + name
+		}
+		usage := f.Usage
+		if len(f.Choices) > 0 {
+			usage += " (one of " + strings.Join(f.Choices, ", ") + ")"
+		}
+		if f.Default != "" {
+			usage += " (default " + f.Default + ")"
+		}
+		if _, err := fmt.Fprintf(tabw, " %s\t%s%s\t%s\t%s\n", short, u.prefix, name, f.Type, usage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package construct_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/pierrec/construct"
+)
+
+type UsageDB struct {
+	Host string
+	Port int
+}
+
+func (*UsageDB) Init() error { return nil }
+func (*UsageDB) Usage(name string) string {
+	switch name {
+	case "Host":
+		return "database host"
+	case "Port":
+		return "database port"
+	}
+	return ""
+}
+
+type cfgUsageTemplate struct {
+	UsageDB `cfg:"DB"`
+	Verbose bool
+	Deploy
+}
+
+func (*cfgUsageTemplate) Init() error { return nil }
+func (*cfgUsageTemplate) Usage(name string) string {
+	switch name {
+	case "":
+		return "myapp"
+	case "Verbose":
+		return "verbose mode"
+	}
+	return ""
+}
+func (*cfgUsageTemplate) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgUsageTemplate) FlagsShort(name string) string                          { return "" }
+
+func TestFlagsUsageTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("usage").Parse(`{{.Usage}}
+{{range .Flags}}{{with .Group}}[{{.}}] {{end}}--{{.Name}} {{.Type}}: {{.Usage}}{{with .Default}} ({{.}}){{end}}
+{{end}}{{range .Commands}}{{.Name}}: {{.Usage}}
+{{end}}Footer
+`))
+	var buf bytes.Buffer
+	opts := []construct.Option{
+		construct.OptionFlagsUsageTemplate(tmpl),
+		construct.OptionFlagsUsage(func(err error, usage func(io.Writer) error) error {
+			return usage(&buf)
+		}),
+	}
+	c := cfgUsageTemplate{UsageDB: UsageDB{Port: 5432}}
+	if err := construct.LoadArgs(&c, []string{"--help"}, opts...); err != nil {
+		t.Fatal(err)
+	}
+	want := `myapp
+[DB] --db-host string: database host
+[DB] --db-port int64: database port (5432)
+--verbose : verbose mode
+deploy: deploy the app
+Footer
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, want)
+	}
+}
+
+type cfgHidden struct {
+	Debug   bool `cfg:",hidden"`
+	Verbose bool
+	Deploy  `cfg:",hidden"`
+}
+
+func (*cfgHidden) Init() error                                            { return nil }
+func (*cfgHidden) Usage(name string) string                               { return "the " + name }
+func (*cfgHidden) FlagsDone(cmds []construct.Config, args []string) error { return nil }
+func (*cfgHidden) FlagsShort(name string) string                          { return "" }
+
+func TestHiddenFlags(t *testing.T) {
+	var buf bytes.Buffer
+	opt := construct.OptionFlagsUsage(func(err error, usage func(io.Writer) error) error {
+		return usage(&buf)
+	})
+	var c cfgHidden
+	if err := construct.LoadArgs(&c, []string{"--help"}, opt); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); strings.Contains(s, "debug") || strings.Contains(s, "deploy") || !strings.Contains(s, "--verbose") {
+		t.Errorf("invalid usage:\n%s", s)
+	}
+
+	if err := construct.LoadArgs(&c, []string{"--debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Debug {
+		t.Error("hidden flag not set")
+	}
+
+	buf.Reset()
+	if err := construct.GenDocs(&c, "markdown", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "`--debug`") {
+		t.Errorf("hidden flag not documented:\n%s", buf.String())
+	}
+}
+
+func TestErrHelp(t *testing.T) {
+	var buf bytes.Buffer
+	var c cfgHidden
+	err := construct.LoadArgs(&c, []string{"--help"}, construct.OptionFlagsWriter(&buf))
+	if !errors.Is(err, construct.ErrHelp) {
+		t.Errorf("got error %v; expected ErrHelp", err)
+	}
+	if !strings.Contains(buf.String(), "--verbose") {
+		t.Errorf("invalid usage:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	err = construct.LoadArgs(&c, []string{"--unknown"}, construct.OptionFlagsWriter(&buf))
+	if err == nil || errors.Is(err, construct.ErrHelp) {
+		t.Errorf("got error %v; expected a parsing error", err)
+	}
+	if !strings.Contains(buf.String(), "unknown") {
+		t.Errorf("error not written:\n%s", buf.String())
+	}
+}
+
+func TestCommandHelp(t *testing.T) {
+	want := "Command: deploy\n\ndeploy the app\n\nOptions:\n" +
+		"        --force         force the deployment\n\nGlobal options:\n" +
+		"        --db-host string  database host\n" +
+		"        --db-port int64   database port (default 5432)\n" +
+		"        --verbose         verbose mode\n"
+	for _, args := range [][]string{
+		{"deploy", "--help"},
+		{"help", "deploy"},
+	} {
+		var buf bytes.Buffer
+		c := cfgUsageTemplate{UsageDB: UsageDB{Port: 5432}}
+		err := construct.LoadArgs(&c, args, construct.OptionFlagsWriter(&buf))
+		if err != construct.ErrHelp {
+			t.Fatalf("%v: got error %v; expected ErrHelp", args, err)
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("%v: got:\n%s\nexpected:\n%s", args, got, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	var c cfgUsageTemplate
+	if err := construct.LoadArgs(&c, []string{"help"}, construct.OptionFlagsWriter(&buf)); err != construct.ErrHelp {
+		t.Fatalf("got error %v; expected ErrHelp", err)
+	}
+	if s := buf.String(); strings.HasPrefix(s, "Command:") || !strings.Contains(s, "help ") {
+		t.Errorf("invalid usage:\n%s", s)
+	}
+	if err := construct.LoadArgs(&c, []string{"help", "unknown"}, construct.OptionFlagsWriter(&buf)); err == nil || err == construct.ErrHelp {
+		t.Errorf("got error %v; expected an unknown command error", err)
+	}
+}
@@ -0,0 +1,261 @@
+package construct
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/pierrec/construct/internal/structs"
+)
+
+// Validator is implemented by types validating their own value.
+// Config items which value implements it, as well as Config structs
+// implementing it, are validated once loaded from all sources and before
+// the Init methods are invoked.
+// A Validate method promoted from an embedded struct is only invoked once,
+// its failure being reported for the embedding struct.
+type Validator interface {
+	Validate() error
+}
+
+// FieldFailure describes a config item failing validation.
+type FieldFailure struct {
+	// Path is the dotted path to the config item, e.g. "Group.Field",
+	// or to the Config struct for struct level failures.
+	Path string `json:"path"`
+	// Rule is the name of the failed validation rule, e.g. "valid".
+	Rule string `json:"rule"`
+	// Message describes the failure.
+	Message string `json:"message"`
+}
+
+func (f FieldFailure) String() string {
+	return f.Path + ": " + f.Rule + ": " + f.Message
+}
+
+// ValidationError is returned by Load when config items fail validation.
+// It reports all the failures instead of only the first one.
+type ValidationError struct {
+	failures []FieldFailure
+}
+
+// Errors returns the validation failures in declaration order of the config items.
+func (e *ValidationError) Errors() []FieldFailure {
+	return e.failures
+}
+
+func (e *ValidationError) Error() string {
+	lst := make([]string, len(e.failures))
+	for i, f := range e.failures {
+		lst[i] = f.String()
+	}
+	return "invalid config: " + strings.Join(lst, "; ")
+}
+
+// validationRule checks a config item and returns a failure message if it is invalid.
+type validationRule struct {
+	name  string
+	check func(c *config, name string, field *structs.StructField) string
+}
+
+// validationRules are applied in order on every config item.
+var validationRules = []validationRule{
+	{"required", checkRequired},
+	{"choices", checkChoices},
+	{"url", checkURL},
+	{"valid", checkValidator},
+}
+
+// checkRequired makes sure that required config items are set.
+// A required config item is missing if it was not set by any source
+// and its value is the zero value.
+func checkRequired(c *config, name string, field *structs.StructField) string {
+	if _, ok := field.Flag("required"); !ok {
+		return ""
+	}
+	if _, ok := c.sources[name]; ok {
+		return ""
+	}
+	if !reflect.ValueOf(field.Interface()).IsZero() {
+		return ""
+	}
+	return "missing value"
+}
+
+// enumValues returns the values the config item is restricted to, if any,
+// from its choices tag flag or its type implementing Enumerator.
+func enumValues(field *structs.StructField) []string {
+	if choices, ok := field.Flag("choices"); ok {
+		return strings.Split(choices, "|")
+	}
+	if e, ok := field.Indirect().(Enumerator); ok {
+		return e.Enum()
+	}
+	if t := reflect.TypeOf(field.Indirect()); t != nil && t.Kind() == reflect.Slice {
+		// Enumerated items.
+		if e, ok := reflect.Zero(t.Elem()).Interface().(Enumerator); ok {
+			return e.Enum()
+		}
+	}
+	return nil
+}
+
+// checkChoices makes sure that the values of enum config items are valid.
+// Unset config items with a zero value are not checked.
+func checkChoices(c *config, name string, field *structs.StructField) string {
+	choices := enumValues(field)
+	if len(choices) == 0 {
+		return ""
+	}
+	v := reflect.ValueOf(field.Indirect())
+	if _, ok := c.sources[name]; !ok && v.IsZero() {
+		return ""
+	}
+	values := []interface{}{field.Indirect()}
+	if !implementsText(v) && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) {
+		values = values[:0]
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, v.Index(i).Interface())
+		}
+	}
+	for _, value := range values {
+		s, err := structs.MarshalValue(value, nil)
+		if err != nil {
+			return err.Error()
+		}
+		if !hasString(choices, fmt.Sprintf("%v", s)) {
+			return fmt.Sprintf("invalid value %q, expected one of %s", fmt.Sprintf("%v", s), strings.Join(choices, ", "))
+		}
+	}
+	return ""
+}
+
+// checkURL makes sure that URL config items satisfy the constraints of their
+// url tag flag, made of the allowed schemes and the following options:
+//  - host: the host is required
+//  - nouser: user information is not allowed
+// Unset config items with a zero value are not checked.
+func checkURL(c *config, name string, field *structs.StructField) string {
+	constraints, ok := field.Flag("url")
+	if !ok {
+		return ""
+	}
+	u := field.URL()
+	if _, ok := c.sources[name]; !ok && (u == nil || *u == (url.URL{})) {
+		return ""
+	}
+	if u == nil {
+		return "missing value"
+	}
+	var schemes, options []string
+	for _, constraint := range strings.Split(constraints, "|") {
+		switch constraint {
+		case "host", "nouser":
+			options = append(options, constraint)
+		default:
+			schemes = append(schemes, constraint)
+		}
+	}
+	switch {
+	case len(schemes) == 0, hasString(schemes, strings.ToLower(u.Scheme)):
+	case u.Scheme == "":
+		return fmt.Sprintf("missing scheme in %q, expected one of %s", u.Redacted(), strings.Join(schemes, ", "))
+	default:
+		return fmt.Sprintf("invalid scheme %q, expected one of %s", u.Scheme, strings.Join(schemes, ", "))
+	}
+	if hasString(options, "host") && u.Host == "" {
+		return fmt.Sprintf("missing host in %q", u.Redacted())
+	}
+	if hasString(options, "nouser") && u.User != nil {
+		return fmt.Sprintf("user information not allowed in %q", u.Redacted())
+	}
+	return ""
+}
+
+// implementsText returns whether or not v implements encoding.TextMarshaler.
+func implementsText(v reflect.Value) bool {
+	_, ok := v.Interface().(encoding.TextMarshaler)
+	return ok
+}
+
+// hasString returns whether or not lst contains s.
+func hasString(lst []string, s string) bool {
+	for _, item := range lst {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkValidator validates the values implementing Validator.
+func checkValidator(_ *config, _ string, field *structs.StructField) string {
+	if field.IsPointer() && reflect.ValueOf(field.Interface()).IsNil() {
+		// Unset value.
+		return ""
+	}
+	v, ok := field.Interface().(Validator)
+	if !ok {
+		if v, ok = field.PtrValue().(Validator); !ok {
+			return ""
+		}
+	}
+	return validateMessage(v)
+}
+
+// validateMessage returns the error message of the Validate method, if any.
+func validateMessage(v Validator) string {
+	if err := v.Validate(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// validate applies the validation rules on the config items and the Config
+// structs implementing Validator, and returns a ValidationError listing all
+// the failures, if any.
+//
+// The Validate method of an embedded Go struct is only invoked through its
+// embedding struct when the latter implements Validator, as it is then either
+// promoted to it or overridden by it.
+func (c *config) validate() error {
+	var failures []FieldFailure
+	var walk func(s *structs.StructStruct, keys []string, promoted bool)
+	walk = func(s *structs.StructStruct, keys []string, promoted bool) {
+		v, isValidator := s.Interface().(Validator)
+		for _, field := range s.Fields() {
+			if cmd, _ := getCommand(field); cmd != nil {
+				// Subcommands are validated when invoked.
+				continue
+			}
+			ks := append(keys[:len(keys):len(keys)], field.Name())
+			if emb := field.Embedded(); emb != nil {
+				if emb.Inlined() {
+					ks = keys
+				}
+				walk(emb, ks, isValidator && field.Anonymous())
+				continue
+			}
+			name := strings.Join(ks[len(c.cmds):], c.options.gsep)
+			for _, rule := range validationRules {
+				if msg := rule.check(c, name, field); msg != "" {
+					path := strings.Join(ks, ".")
+					failures = append(failures, FieldFailure{path, rule.name, msg})
+				}
+			}
+		}
+		if !isValidator || promoted {
+			return
+		}
+		if msg := validateMessage(v); msg != "" {
+			failures = append(failures, FieldFailure{strings.Join(keys, "."), "valid", msg})
+		}
+	}
+	walk(c.root, c.cmds, false)
+	if len(failures) == 0 {
+		return nil
+	}
+	return &ValidationError{failures}
+}
@@ -0,0 +1,22 @@
+package construct
+
+// Values returns the current values of the config items of config, keyed by
+// their name, i.e. grouped items names joined by the group separator as used for
+// flags, e.g. "DB-Port". Subcommands items are not included.
+//
+// Pointer fields values are returned as is, nil if unset.
+func Values(config Config, options ...Option) (map[string]interface{}, error) {
+	conf, err := newConfig(config, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := conf.buildKeys(conf.root.Fields(), "", false); err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{}, len(conf.trans))
+	for _, name := range conf.trans {
+		field := conf.root.Lookup(conf.fromNameAll(name, conf.options.gsep)...)
+		values[name] = field.Interface()
+	}
+	return values, nil
+}
@@ -0,0 +1,32 @@
+package construct_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pierrec/construct"
+)
+
+func TestValues(t *testing.T) {
+	c := cfgEnvPrefix{RemoteDB{"db", 5432}, "info"}
+	values, err := construct.Values(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"DB-Host": "db",
+		"DB-Port": 5432,
+		"Level":   "info",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v; expected %v", values, want)
+	}
+
+	values, err = construct.Values(&c, construct.OptionFlagsGroupSep('.'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := values["DB.Port"]; v != 5432 {
+		t.Errorf("got %v; expected 5432", v)
+	}
+}
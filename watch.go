@@ -0,0 +1,337 @@
+package construct
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ChangeNotifier is optionally implemented by Config structs monitored by Watch
+// to be notified of the reloaded config after their FromIO sources were updated.
+type ChangeNotifier interface {
+	// OnChange is invoked on the initially loaded config with the reloaded one
+	// and the names of its changed config items, with their groups separated
+	// by the flags group separator.
+	OnChange(config Config, keys []string)
+}
+
+// FromIONotifier is optionally implemented by FromIO sources notifying their changes.
+// Watch then checks them as soon as they are notified in addition to polling them.
+type FromIONotifier interface {
+	// Notify returns a channel receiving a value whenever the source changes,
+	// or nil if notifications are not supported.
+	// The channel is closed when the context is done.
+	Notify(ctx context.Context) (<-chan struct{}, error)
+}
+
+// FromRemoteNotifier is optionally implemented by FromRemote sources notifying
+// their changes, such as key/value stores. Watch then reloads the config as
+// soon as they are notified in addition to polling them.
+type FromRemoteNotifier interface {
+	// Notify returns a channel receiving a value whenever the source changes,
+	// or nil if notifications are not supported.
+	// The channel is closed when the context is done.
+	Notify(ctx context.Context) (<-chan struct{}, error)
+}
+
+// Watcher monitors the FromIO sources of a config and reloads it when they change.
+type Watcher struct {
+	config   Config
+	current  atomic.Value
+	template reflect.Value // Copy of the config before it was first loaded.
+	args     []string
+	options  []Option
+
+	// Only used by the watching goroutine.
+	src  Config   // Copy of the current config, from which the sources are read.
+	data [][]byte // Last data read from the sources.
+
+	mu   sync.Mutex
+	err  error
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// Watch loads config as Load does and keeps polling its FromIO or FromIOMulti
+// sources, also checking them whenever notified if they implement FromIONotifier.
+// If config implements FromRemote, it is also reloaded at every poll and whenever
+// notified if it implements FromRemoteNotifier, as the changes of the remote
+// values cannot be detected without loading them.
+//
+// Whenever the data of a source changes, a new instance of the config is loaded
+// from the initial values of config and all sources, and becomes the current
+// one (see Current). Its FlagsDone, PreRun and PostRun methods are not invoked
+// and its FromIO sources are not saved. If config implements ChangeNotifier,
+// its OnChange method is then invoked with the new instance, unless no config
+// item changed.
+//
+// config is not modified once loaded by Watch, so that it can be read while
+// the config is reloaded, in a separate goroutine. The reloaded instances are
+// also stored in the Holder set with OptionHolder, if any.
+// As with Holder.Reload, the slices and maps held by the unexported fields of
+// the initial config are shared with the reloaded instances.
+func Watch(config Config, options ...Option) (*Watcher, error) {
+	return WatchArgs(config, osArgs(), options...)
+}
+
+// WatchArgs is equivalent to Watch using the given arguments, which are also
+// used whenever the config is loaded again.
+func WatchArgs(config Config, args []string, options ...Option) (*Watcher, error) {
+	switch config.(type) {
+	case FromIOMulti, FromIO, FromRemote:
+	default:
+		return nil, errors.Errorf("%T does not implement FromIO, FromIOMulti nor FromRemote", config)
+	}
+	conf, err := newConfig(config, options)
+	if err != nil {
+		return nil, err
+	}
+	interval := conf.options.winterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	src := reflect.ValueOf(config).Elem()
+	w := &Watcher{
+		config:   config,
+		template: reflect.New(src.Type()).Elem(),
+		args:     args,
+		options:  options,
+		done:     make(chan struct{}),
+	}
+	deepCopy(w.template, src)
+	if err := LoadArgs(config, w.args, options...); err != nil {
+		return nil, err
+	}
+	w.current.Store(config)
+	w.src = copyConfig(config)
+	if w.data, err = w.read(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	notify, err := w.notify(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer cancel()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.done:
+				return
+			case _, ok := <-notify:
+				if !ok {
+					// Keep polling.
+					notify = nil
+					continue
+				}
+			case <-ticker.C:
+			}
+			if err := w.check(); err != nil {
+				w.mu.Lock()
+				w.err = err
+				w.mu.Unlock()
+			}
+		}
+	}()
+	return w, nil
+}
+
+// Current returns the latest loaded config.
+func (w *Watcher) Current() Config {
+	return w.current.Load().(Config)
+}
+
+// Err returns the last error encountered while reloading the config.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close stops monitoring the config sources.
+// It is safe to call it more than once.
+func (w *Watcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	w.wg.Wait()
+	return nil
+}
+
+// copyConfig returns a copy of config.
+func copyConfig(config Config) Config {
+	src := reflect.ValueOf(config).Elem()
+	dst := reflect.New(src.Type())
+	deepCopy(dst.Elem(), src)
+	return dst.Interface().(Config)
+}
+
+// sources returns the FromIO sources of the config.
+func (w *Watcher) sources() []FromIO {
+	switch from := w.src.(type) {
+	case FromIOMulti:
+		return from.IOSources()
+	case FromIO:
+		return []FromIO{from}
+	}
+	return nil
+}
+
+// notify returns a channel receiving a value whenever one of the sources
+// implementing FromIONotifier or FromRemoteNotifier changes, or nil if none does.
+// The channel is closed once all the sources channels are closed.
+func (w *Watcher) notify(ctx context.Context) (<-chan struct{}, error) {
+	var chans []<-chan struct{}
+	add := func(notify func(context.Context) (<-chan struct{}, error)) error {
+		ch, err := notify(ctx)
+		if ch != nil {
+			chans = append(chans, ch)
+		}
+		return err
+	}
+	for _, from := range w.sources() {
+		if n, ok := from.(FromIONotifier); ok {
+			if err := add(n.Notify); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, ok := w.src.(FromRemote); ok {
+		if n, ok := w.src.(FromRemoteNotifier); ok {
+			if err := add(n.Notify); err != nil {
+				return nil, err
+			}
+		}
+	}
+	switch len(chans) {
+	case 0:
+		return nil, nil
+	case 1:
+		return chans[0], nil
+	}
+	notify := make(chan struct{}, 1)
+	var wg sync.WaitGroup
+	for _, ch := range chans {
+		wg.Add(1)
+		go func(ch <-chan struct{}) {
+			defer wg.Done()
+			for range ch {
+				select {
+				case notify <- struct{}{}:
+				default:
+					// A notification is already pending.
+				}
+			}
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		close(notify)
+	}()
+	return notify, nil
+}
+
+// read returns the data of the sources.
+func (w *Watcher) read() ([][]byte, error) {
+	froms := w.sources()
+	data := make([][]byte, len(froms))
+	for i, from := range froms {
+		src, err := from.Load()
+		if err != nil {
+			return nil, err
+		}
+		if src == nil {
+			continue
+		}
+		data[i], err = ioutil.ReadAll(src)
+		src.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// check reloads the config if its sources changed or it has a remote source.
+func (w *Watcher) check() error {
+	data, err := w.read()
+	if err != nil {
+		return err
+	}
+	if _, remote := w.src.(FromRemote); !remote && equalData(data, w.data) {
+		return nil
+	}
+
+	// Load a new instance of the config from its initial values.
+	nc := reflect.New(w.template.Type())
+	deepCopy(nc.Elem(), w.template)
+	config := nc.Interface().(Config)
+	options := append(w.options[:len(w.options):len(w.options)], optionReload())
+	if err := LoadArgs(config, w.args, options...); err != nil {
+		// The sources are read again at the next check.
+		return err
+	}
+	w.src = copyConfig(config)
+	w.data = data
+
+	cur, err := newConfig(w.Current(), w.options)
+	if err != nil {
+		return err
+	}
+	if err := cur.buildKeys(cur.root.Fields(), "", false); err != nil {
+		return err
+	}
+	next, err := newConfig(config, w.options)
+	if err != nil {
+		return err
+	}
+	if err := next.buildKeys(next.root.Fields(), "", false); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(cur.trans))
+	for _, name := range cur.trans {
+		names = append(names, name)
+	}
+	diffs, err := diff(cur, next, names)
+	if err != nil {
+		return err
+	}
+	w.current.Store(config)
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(diffs))
+	for i, d := range diffs {
+		keys[i] = d.Key
+	}
+	if n, ok := w.config.(ChangeNotifier); ok {
+		n.OnChange(config, keys)
+	}
+	return nil
+}
+
+// equalData returns whether or not the data of the sources are identical.
+func equalData(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
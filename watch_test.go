@@ -0,0 +1,206 @@
+package construct_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pierrec/construct"
+	"github.com/pierrec/construct/constructs"
+)
+
+type cfgWatch struct {
+	constructs.ConfigFileJSON
+	Host    string
+	Port    int
+	Check   int
+	runs    *int32
+	changed chan *cfgWatch
+	keys    chan []string
+}
+
+func (*cfgWatch) Init() error              { return nil }
+func (*cfgWatch) Usage(name string) string { return "" }
+func (c *cfgWatch) FlagsDone(cmds []construct.Config, args []string) error {
+	atomic.AddInt32(c.runs, 1)
+	return nil
+}
+func (*cfgWatch) FlagsShort(name string) string { return "" }
+func (c *cfgWatch) OnChange(config construct.Config, keys []string) {
+	c.changed <- config.(*cfgWatch)
+	c.keys <- keys
+}
+
+func TestWatch(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	write := func(port int) string {
+		p := strconv.Itoa(port)
+		data := `{"Host": "a", "Port": ` + p + `, "Check": ` + p + `}`
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	write(1)
+
+	c := &cfgWatch{runs: new(int32), changed: make(chan *cfgWatch, 1), keys: make(chan []string, 1)}
+	c.Name = name
+	c.ToSave = true
+	w, err := construct.WatchArgs(c, nil, construct.OptionWatchInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if c.Host != "a" || c.Port != 1 || w.Current() != c {
+		t.Fatalf("config not loaded: %+v", c)
+	}
+
+	// Read the configs while they are reloaded.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				cur := w.Current().(*cfgWatch)
+				if cur.Port != cur.Check || c.Port != 1 || c.Path() != name {
+					t.Errorf("inconsistent config: %+v", cur)
+					return
+				}
+			}
+		}()
+	}
+	var data string
+	for port := 2; port < 5; port++ {
+		data = write(port)
+		select {
+		case next := <-c.changed:
+			if next.Port != port || w.Current() != next {
+				t.Errorf("got %d; expected %d", next.Port, port)
+			}
+			if keys, want := <-c.keys, []string{"Check", "Port"}; !reflect.DeepEqual(keys, want) {
+				t.Errorf("got %v; expected %v", keys, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("config change not detected: %v", w.Err())
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if c.Port != 1 {
+		t.Errorf("initial config modified: %+v", c)
+	}
+	// The commands are not run and the source not saved on reload.
+	if runs := atomic.LoadInt32(c.runs); runs != 1 {
+		t.Errorf("got %d runs; expected 1", runs)
+	}
+	if got, err := os.ReadFile(name); err != nil || string(got) != data {
+		t.Errorf("got %q (%v); expected %q", got, err, data)
+	}
+}
+
+func TestWatchMulti(t *testing.T) {
+	dir := t.TempDir()
+	system := filepath.Join(dir, "system.toml")
+	if err := os.WriteFile(system, []byte("Host = \"system\"\nPort = 80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	user := filepath.Join(dir, "user.json")
+	if err := os.WriteFile(user, []byte(`{"Port": 8080}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var c cfgMultiIO
+	c.System.Name = system
+	c.User.Name = user
+	w, err := construct.WatchArgs(&c, nil, construct.OptionWatchInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := os.WriteFile(system, []byte("Host = \"newsystem\"\nPort = 80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cur := w.Current().(*cfgMultiIO)
+		if cur.Host == "newsystem" && cur.Port == 8080 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("config change not detected: %+v %v", cur, w.Err())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if c.Host != "system" {
+		t.Errorf("initial config modified: %+v", c)
+	}
+}
+
+type cfgWatchRetry struct {
+	constructs.ConfigFileJSON
+	Port int
+	fail *int32
+}
+
+func (*cfgWatchRetry) Init() error              { return nil }
+func (*cfgWatchRetry) Usage(name string) string { return "" }
+func (c *cfgWatchRetry) Validate() error {
+	if atomic.LoadInt32(c.fail) != 0 {
+		return errors.New("invalid config")
+	}
+	return nil
+}
+
+func TestWatchRetry(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(name, []byte(`{"Port": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &cfgWatchRetry{fail: new(int32)}
+	c.Name = name
+	w, err := construct.WatchArgs(c, nil, construct.OptionWatchInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// The failed reload is retried with the same data.
+	atomic.StoreInt32(c.fail, 1)
+	if err := os.WriteFile(name, []byte(`{"Port": 2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Err() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("reload error not reported")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	atomic.StoreInt32(c.fail, 0)
+	for w.Current().(*cfgWatchRetry).Port != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("config change not reloaded: %v", w.Err())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}